@@ -2,8 +2,43 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"os"
 )
 
+const usage = `Usage: consensusforge <command> [arguments]
+
+Commands:
+  run <scenario.yaml>    execute a scenario and check its assertions
+  replay <artifact.json> re-execute a recorded run step by step
+  shrink <artifact.json> minimize a failed run to a small scenario
+
+Run 'consensusforge <command> -h' for the flags of a command.
+`
+
 func main() {
-	fmt.Println("Hello, World!")
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// Dispatches to a subcommand and returns the process exit code
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "run":
+		return runCommand(args[1:], stdout, stderr)
+	case "replay":
+		return replayCommand(args[1:], stdin, stdout, stderr)
+	case "shrink":
+		return shrinkCommand(args[1:], stdout, stderr)
+	case "help", "-h", "--help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", args[0], usage)
+		return exitUsage
+	}
 }
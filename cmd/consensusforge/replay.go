@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/scenario"
+)
+
+const replayHelp = `Commands at a pause:
+  <enter>, n    run the next event
+  c             continue to the next breakpoint
+  s             print every node's state
+  b <N>         add a breakpoint at event N
+  q             stop the replay
+`
+
+// A list of event numbers given by a repeatable flag
+type eventList map[int]bool
+
+func (l eventList) String() string {
+	return fmt.Sprint(map[int]bool(l))
+}
+
+func (l eventList) Set(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return fmt.Errorf("event number must be a positive integer")
+	}
+	l[n] = true
+	return nil
+}
+
+func replayCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: consensusforge replay [flags] <artifact.json>")
+		flags.PrintDefaults()
+		fmt.Fprint(stderr, "\n"+replayHelp)
+	}
+
+	breakpoints := eventList{}
+	dumps := eventList{}
+	flags.Var(breakpoints, "break", "pause at event `N` (repeatable)")
+	flags.Var(dumps, "dump", "print every node's state after event `N` (repeatable)")
+	step := flags.Bool("step", false, "pause after every event")
+	quiet := flags.Bool("quiet", false, "only print events at pauses and dumps")
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	a, err := scenario.LoadArtifact(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	input := bufio.NewScanner(stdin)
+	stepping := *step
+	last := 0
+	onStep := func(e scenario.Event, cluster scenario.Inspector) {
+		// The run stops at the next event boundary after a quit
+		if ctx.Err() != nil {
+			return
+		}
+		last = e.Seq
+		pause := stepping || breakpoints[e.Seq]
+		if !*quiet || pause || dumps[e.Seq] {
+			fmt.Fprintln(stdout, e)
+		}
+		if dumps[e.Seq] {
+			printNodes(stdout, cluster)
+		}
+
+		for pause {
+			fmt.Fprint(stdout, "(replay) ")
+			if !input.Scan() {
+				// No more input: run to the end
+				stepping = false
+				return
+			}
+			command := strings.Fields(input.Text())
+			switch {
+			case len(command) == 0 || command[0] == "n":
+				stepping = true
+				pause = false
+			case command[0] == "c":
+				stepping = false
+				pause = false
+			case command[0] == "s":
+				printNodes(stdout, cluster)
+			case command[0] == "b" && len(command) == 2:
+				if err := breakpoints.Set(command[1]); err != nil {
+					fmt.Fprintln(stdout, err)
+				}
+			case command[0] == "q":
+				cancel()
+				return
+			default:
+				fmt.Fprint(stdout, replayHelp)
+			}
+		}
+	}
+
+	fmt.Fprintf(stdout, "replaying seed %d, %d recorded events\n", a.Seed, len(a.Events))
+	started := time.Now()
+	result, err := scenario.Replay(ctx, a, scenario.Options{OnStep: onStep})
+	switch {
+	case ctx.Err() != nil:
+		fmt.Fprintf(stdout, "replay stopped at event %d\n", last)
+		return exitOK
+	case result == nil:
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	printSummary(stdout, result, time.Since(started))
+	if errors.Is(err, scenario.ErrDiverged) {
+		fmt.Fprintf(stderr, "warning: %v\n", err)
+		return exitUsage
+	}
+	if !result.Passed {
+		return exitViolation
+	}
+	return exitOK
+}
+
+func printNodes(w io.Writer, cluster scenario.Inspector) {
+	for _, node := range cluster.Nodes() {
+		fmt.Fprintf(w, "  %s\n", node)
+		if node.Status == nil {
+			continue
+		}
+
+		if members := node.Status.Members; members != nil {
+			fmt.Fprintf(w, "    voters %v, learners %v\n", members.Voters, members.Learners)
+		}
+		peers := make([]string, 0, len(node.Status.Peers))
+		for peer := range node.Status.Peers {
+			peers = append(peers, peer)
+		}
+		sort.Strings(peers)
+		for _, peer := range peers {
+			progress := node.Status.Peers[peer]
+			fmt.Fprintf(w, "    peer %s: match %d, next %d\n", peer, progress.MatchIndex, progress.NextIndex)
+		}
+		for _, entry := range node.Status.Entries {
+			fmt.Fprintf(w, "    entry %d term %d: %s\n", entry.Index, entry.Term, entry.Command)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Runs a failing scenario and returns the path of its artifact
+func failedRun(t *testing.T) string {
+	t.Helper()
+	path := writeScenario(t, `
+duration: 500ms
+workload: {kind: register}
+timeline:
+  - {at: 100ms, action: isolate, nodes: [node-1]}
+assertions:
+  - kind: min_completed
+    min: 100000
+`)
+	artifact := filepath.Join(t.TempDir(), "failure.json")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"run", "--artifact", artifact, path}, nil, &stdout, &stderr); code != exitViolation {
+		t.Fatalf("Expected the run to fail, got exit code %d\n%s", code, stderr.String())
+	}
+	return artifact
+}
+
+func TestReplayReproducesRun(t *testing.T) {
+	artifact := failedRun(t)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"replay", "--quiet", "--dump", "20", artifact}, nil, &stdout, &stderr)
+	if code != exitViolation {
+		t.Fatalf("Expected the replay to reproduce the failure, got exit code %d\n%s", code, stderr.String())
+	}
+	if stderr.Len() != 0 {
+		t.Errorf("Expected no divergence warning, got %s", stderr.String())
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "#20 ") || !strings.Contains(out, "node-3: ") || !strings.Contains(out, " in term ") {
+		t.Errorf("Expected event 20 and a state dump, got:\n%s", out)
+	}
+	if strings.Contains(out, "#21 ") {
+		t.Errorf("Quiet replay should only print dumped events, got:\n%s", out)
+	}
+}
+
+func TestReplayBreakpointAndStepping(t *testing.T) {
+	artifact := failedRun(t)
+
+	// Pause at event 5, step twice, dump state, then quit
+	stdin := strings.NewReader("n\n\ns\nq\n")
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"replay", "--quiet", "--break", "5", artifact}, stdin, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d\n%s", exitOK, code, stderr.String())
+	}
+
+	out := stdout.String()
+	for _, want := range []string{"#5 ", "#6 ", "#7 ", "node-1: ", "replay stopped at event 7"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in output:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "#8 ") {
+		t.Errorf("Replay should stop where it was quit:\n%s", out)
+	}
+}
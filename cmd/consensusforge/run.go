@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/scenario"
+)
+
+// Process exit codes
+const (
+	exitOK        = 0
+	exitViolation = 1 // the run found correctness violations
+	exitUsage     = 2 // bad arguments or a scenario that could not run
+)
+
+func runCommand(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: consensusforge run [flags] <scenario.yaml>")
+		flags.PrintDefaults()
+	}
+
+	seed := flags.Uint64("seed", 0, "override the scenario seed")
+	algorithm := flags.String("algorithm", "", "override the scenario algorithm")
+	nodes := flags.Int("nodes", 0, "override the cluster size")
+	duration := flags.Duration("duration", 0, "override how long the run lasts in virtual time")
+	output := flags.String("output", "", "write the result as JSON to this file")
+	artifact := flags.String("artifact", "", "where to write the replay artifact of a failed run (default <name>-seed<seed>.replay.json)")
+	verbose := flags.Bool("verbose", false, "also stream every client operation")
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	s, err := scenario.Load(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	// Flags only override the scenario when given explicitly
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "seed":
+			s.Seed = *seed
+		case "algorithm":
+			s.Algorithm = *algorithm
+		case "nodes":
+			s.Nodes = *nodes
+		case "duration":
+			s.Duration = *duration
+		}
+	})
+	if err := s.Validate(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Fprintf(stdout, "running %s: %s, %d nodes, seed %d, %v\n",
+		name(s), s.Algorithm, s.Nodes, s.Seed, s.Duration)
+	started := time.Now()
+	result, err := scenario.Run(ctx, s, scenario.Options{
+		TraceMessages: true,
+		OnEvent: func(e scenario.Event) {
+			if *verbose || isFault(e) {
+				fmt.Fprintln(stdout, e)
+			}
+		},
+	})
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	printSummary(stdout, result, time.Since(started))
+	if *output != "" {
+		if err := writeResult(*output, result); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+	}
+
+	if !result.Passed {
+		path := *artifact
+		if path == "" {
+			path = fmt.Sprintf("%s-seed%d.replay.json", name(s), s.Seed)
+		}
+		if err := writeArtifact(path, s, result); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+		fmt.Fprintf(stdout, "\nreplay with: consensusforge replay %s\n", path)
+		return exitViolation
+	}
+	return exitOK
+}
+
+// Reports whether an event is shown without --verbose
+func isFault(e scenario.Event) bool {
+	return e.Kind == scenario.EventAction || e.Kind == scenario.EventViolation || e.Kind == scenario.EventFault
+}
+
+func writeArtifact(path string, s *scenario.Scenario, result *scenario.Result) error {
+	a, err := scenario.NewArtifact(s, result)
+	if err != nil {
+		return err
+	}
+	return a.Write(path)
+}
+
+func name(s *scenario.Scenario) string {
+	if s.Name == "" {
+		return "scenario"
+	}
+	return s.Name
+}
+
+func printSummary(w io.Writer, result *scenario.Result, wall time.Duration) {
+	stats := result.Stats
+	fmt.Fprintf(w, "\n%d operations: %d ok, %d failed, %d unknown\n",
+		stats.Operations, stats.Completed, stats.Failed, stats.Unknown)
+	fmt.Fprintf(w, "%d messages sent, %d dropped, %d duplicated, %d corrupted, %d reordered\n",
+		stats.Network.MessagesSent, stats.Network.MessagesDropped, stats.Network.MessagesDuplicated,
+		stats.Network.MessagesCorrupted, stats.Network.MessagesReordered)
+	fmt.Fprintf(w, "%v of virtual time in %d events, %v wall clock\n",
+		result.Duration, stats.Events, wall.Round(time.Millisecond))
+
+	if result.Passed {
+		fmt.Fprintln(w, "PASS")
+		return
+	}
+	fmt.Fprintf(w, "FAIL: %d violations\n", len(result.Violations))
+	for _, v := range result.Violations {
+		fmt.Fprintf(w, "  %s\n", v)
+	}
+}
+
+func writeResult(path string, result *scenario.Result) error {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/scenario"
+)
+
+func writeScenario(t *testing.T, doc string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "scenario.yaml")
+	if err := os.WriteFile(path, []byte(doc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunCommandPasses(t *testing.T) {
+	path := writeScenario(t, `
+name: smoke
+duration: 2s
+workload: {kind: register}
+timeline:
+  - {at: 0s, action: wait_for_leader, timeout: 1s}
+assertions:
+  - kind: leader
+`)
+	output := filepath.Join(t.TempDir(), "result.json")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"run", "--seed", "9", "--nodes", "5", "--output", output, path}, nil, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d\n%s%s", exitOK, code, stdout.String(), stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "leader elected") || !strings.Contains(stdout.String(), "PASS") {
+		t.Errorf("Expected progress and a summary, got:\n%s", stdout.String())
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("Expected a result file: %v", err)
+	}
+	var result scenario.Result
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatalf("Result is not valid JSON: %v", err)
+	}
+	if result.Seed != 9 || result.Nodes != 5 || !result.Passed {
+		t.Errorf("Expected flags to override the scenario, got seed %d, %d nodes", result.Seed, result.Nodes)
+	}
+}
+
+func TestRunCommandViolation(t *testing.T) {
+	path := writeScenario(t, `
+duration: 1s
+timeline:
+  - {at: 0s, action: partition, groups: [[node-1], [node-2], [node-3]]}
+assertions:
+  - kind: leader
+`)
+
+	artifact := filepath.Join(t.TempDir(), "failure.json")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"run", "--artifact", artifact, path}, nil, &stdout, &stderr); code != exitViolation {
+		t.Fatalf("Expected exit code %d, got %d\n%s", exitViolation, code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "FAIL") {
+		t.Errorf("Expected a failing summary, got:\n%s", stdout.String())
+	}
+	if _, err := scenario.LoadArtifact(artifact); err != nil {
+		t.Errorf("Expected a replay artifact: %v", err)
+	}
+}
+
+func TestRunCommandUsageErrors(t *testing.T) {
+	path := writeScenario(t, "timeline: [{at: 0s, action: crash, nodes: [node-3]}]\n")
+
+	cases := [][]string{
+		{},
+		{"explode"},
+		{"run"},
+		{"run", "missing.yaml"},
+		{"run", "--nodes", "2", path},
+		{"run", "--algorithm", "zab", path},
+	}
+	for _, args := range cases {
+		var stdout, stderr bytes.Buffer
+		if code := run(args, nil, &stdout, &stderr); code != exitUsage {
+			t.Errorf("%v: expected exit code %d, got %d", args, exitUsage, code)
+		}
+	}
+}
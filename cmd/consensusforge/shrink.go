@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/scenario"
+)
+
+func shrinkCommand(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("shrink", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: consensusforge shrink [flags] <artifact.json>")
+		flags.PrintDefaults()
+	}
+
+	maxRuns := flags.Int("max-runs", scenario.DefaultShrinkRuns, "give up after `N` runs")
+	kind := flags.String("kind", "", "violation to preserve (default: the artifact's first)")
+	output := flags.String("output", "", "write the minimal scenario to `path` instead of stdout")
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 1 || *maxRuns < 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	a, err := scenario.LoadArtifact(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	s, err := a.ParseScenario()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	if *kind == "" {
+		if len(a.Violations) == 0 {
+			fmt.Fprintln(stderr, "artifact recorded no violation to shrink")
+			return exitUsage
+		}
+		*kind = a.Violations[0].Kind
+	}
+
+	fmt.Fprintf(stderr, "shrinking seed %d while it keeps a %s violation\n", a.Seed, *kind)
+	result, err := scenario.Shrink(context.Background(), s, *kind, scenario.ShrinkOptions{
+		MaxRuns: *maxRuns,
+		OnProgress: func(desc string) {
+			fmt.Fprintf(stderr, "  %s\n", desc)
+		},
+	})
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	fmt.Fprintf(stderr, "kept %d simplifications in %d runs: %v\n",
+		len(result.Applied), result.Runs, result.Violation)
+
+	data, err := result.Scenario.Marshal()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	if *output == "" {
+		stdout.Write(data)
+		return exitOK
+	}
+	if err := os.WriteFile(*output, data, 0o644); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	fmt.Fprintf(stderr, "minimal scenario written to %s\n", *output)
+	return exitOK
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/scenario"
+)
+
+func TestShrinkWritesMinimalScenario(t *testing.T) {
+	artifact := failedRun(t)
+	output := filepath.Join(t.TempDir(), "minimal.yaml")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"shrink", "--output", output, artifact}, nil, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d\n%s", exitOK, code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "remove timeline step") {
+		t.Errorf("Expected progress lines, got:\n%s", stderr.String())
+	}
+
+	s, err := scenario.Load(output)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(s.Timeline) != 0 || s.Workload.Kind != scenario.WorkloadNone {
+		t.Errorf("Expected the isolation and workload to be removed, got %+v", s)
+	}
+}
+
+func TestShrinkRejectsUnknownKind(t *testing.T) {
+	artifact := failedRun(t)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"shrink", "--kind", "converged", "--max-runs", "5", artifact}, nil, &stdout, &stderr)
+	if code != exitUsage {
+		t.Errorf("Expected exit code %d, got %d", exitUsage, code)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("Expected no scenario on stdout, got:\n%s", stdout.String())
+	}
+}
@@ -0,0 +1,10 @@
+// Package algorithms registers every built-in consensus algorithm with the
+// consensus registry. Import it for its side effects:
+//
+//	import _ "github.com/francisco-teixeirax86/consensusforge/pkg/algorithms"
+package algorithms
+
+import (
+	_ "github.com/francisco-teixeirax86/consensusforge/pkg/algorithms/paxos"
+	_ "github.com/francisco-teixeirax86/consensusforge/pkg/algorithms/raft"
+)
@@ -0,0 +1,34 @@
+// Package paxos implements Multi-Paxos on top of the consensus and config
+// packages.
+package paxos
+
+import (
+	"github.com/francisco-teixeirax86/consensusforge/pkg/config"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/consensus"
+)
+
+// Name under which the algorithm is known in config.Config.Algorithm
+const AlgorithmName = "paxos"
+
+func init() {
+	consensus.Register(New())
+}
+
+// Implements consensus.Algorithm for Multi-Paxos
+type Algorithm struct{}
+
+// Creates the Multi-Paxos algorithm
+func New() *Algorithm {
+	return &Algorithm{}
+}
+
+// Name Implements consensus.Algorithm
+func (a *Algorithm) Name() string {
+	return AlgorithmName
+}
+
+// CreateNode Implements consensus.Algorithm. The returned node must be
+// given a transport through consensus.Attachable before it is started.
+func (a *Algorithm) CreateNode(id string, cfg config.Config) (consensus.Node, error) {
+	return NewNode(id, cfg, consensus.Environment{})
+}
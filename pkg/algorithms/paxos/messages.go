@@ -0,0 +1,94 @@
+package paxos
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Identifies a proposer's attempt to lead. Ballots are totally ordered by
+// round, with the proposer ID breaking ties.
+type Ballot struct {
+	Round int64  `json:"round"`
+	Node  string `json:"node,omitempty"`
+}
+
+// Less reports whether b is ordered before other
+func (b Ballot) Less(other Ballot) bool {
+	if b.Round != other.Round {
+		return b.Round < other.Round
+	}
+	return b.Node < other.Node
+}
+
+func (b Ballot) IsZero() bool {
+	return b.Round == 0 && b.Node == ""
+}
+
+func (b Ballot) String() string {
+	return fmt.Sprintf("%d.%s", b.Round, b.Node)
+}
+
+// A value an acceptor has accepted for a slot
+type acceptedValue struct {
+	Slot   int64  `json:"slot"`
+	Ballot Ballot `json:"ballot"`
+	Value  []byte `json:"value,omitempty"`
+}
+
+// A value known to be chosen for a slot
+type chosenValue struct {
+	Slot  int64  `json:"slot"`
+	Value []byte `json:"value,omitempty"`
+}
+
+// Payload of a MessagePrepare (phase 1a)
+type prepareRequest struct {
+	Ballot   Ballot `json:"ballot"`
+	FromSlot int64  `json:"from_slot"`
+}
+
+// Payload of a MessagePromise (phase 1b)
+type promiseResponse struct {
+	Ballot   Ballot          `json:"ballot"`
+	OK       bool            `json:"ok"`
+	Promised Ballot          `json:"promised"`
+	Accepted []acceptedValue `json:"accepted,omitempty"`
+}
+
+// Payload of a MessageAccept (phase 2a)
+type acceptRequest struct {
+	Ballot Ballot `json:"ballot"`
+	Slot   int64  `json:"slot"`
+	Value  []byte `json:"value,omitempty"`
+}
+
+// Payload of a MessageAccepted (phase 2b)
+type acceptedResponse struct {
+	Ballot   Ballot `json:"ballot"`
+	Slot     int64  `json:"slot"`
+	OK       bool   `json:"ok"`
+	Promised Ballot `json:"promised"`
+}
+
+// Payload of a MessageHeartbeat. The leader uses it to assert leadership
+// and to notify learners of chosen values; learners reply with Ack set so
+// the leader knows how far each one has applied.
+type heartbeat struct {
+	Ballot  Ballot        `json:"ballot"`
+	Chosen  []chosenValue `json:"chosen,omitempty"`
+	Ack     bool          `json:"ack,omitempty"`
+	Applied int64         `json:"applied"`
+}
+
+func encode(payload interface{}) []byte {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		// Payloads are plain structs, marshalling cannot fail
+		panic(err)
+	}
+	return data
+}
+
+func decode(data []byte, payload interface{}) error {
+	return json.Unmarshal(data, payload)
+}
@@ -0,0 +1,916 @@
+package paxos
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/clock"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/config"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/consensus"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/logging"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/storage"
+)
+
+// Maximum number of chosen values sent to a learner in one heartbeat
+const maxChosenPerHeartbeat = 64
+
+// Maximum number of slots whose Accept is resent in one heartbeat
+const maxResentPerHeartbeat = 64
+
+// Minimum number of accept records logged before they are compacted into a
+// snapshot of every accepted value
+const minCompaction = 256
+
+// Implements consensus.Node using Multi-Paxos with a distinguished
+// proposer. Every node acts as proposer, acceptor and learner; the node
+// whose ballot completed phase 1 reports itself as leader and runs phase 2
+// for each new slot until a higher ballot preempts it.
+type Node struct {
+	id        string
+	config    config.Config
+	peers     []string // every cluster member except this node
+	transport consensus.Transport
+	sm        consensus.StateMachine
+	logger    logging.Logger
+	clock     clock.Clock
+	rng       *rand.Rand
+
+	mu       sync.Mutex
+	state    consensus.NodeState
+	leaderID string
+
+	// Acceptor state
+	promised Ballot
+	accepted map[int64]acceptedValue
+
+	// Learner state
+	chosen  map[int64][]byte
+	applied int64 // every slot up to applied is chosen and applied
+
+	// Proposer state
+	ballot      Ballot
+	promises    map[string]promiseResponse
+	nextSlot    int64
+	proposals   map[int64][]byte
+	acceptances map[int64]map[string]bool
+	peerApplied map[string]int64
+
+	electionTimer  clock.Timer
+	electionGen    uint64
+	heartbeatTimer clock.Timer
+	heartbeatGen   uint64
+
+	// Persistence. The promised ballot is stored as the hard state and
+	// every accepted value as a log record; chosen values are learned again
+	// from the leader after a restart.
+	storage       consensus.Storage
+	saved         consensus.HardState // hard state last written
+	unsaved       []acceptedValue     // accepted since the last write
+	logged        int64               // index of the last record written
+	snapshotIndex int64               // index of the last record compacted
+	syncedAt      time.Time           // when the last sync completes on slow storage
+
+	outbox  []consensus.Message
+	started bool
+	cancel  context.CancelFunc
+}
+
+// Creates a Multi-Paxos node. The environment may be left empty and
+// supplied later through Attach. Without storage in the environment, the
+// node keeps its promised ballot and accepted values in memory, so they
+// survive a Stop and Start of the same node but not a new one.
+func NewNode(id string, cfg config.Config, env consensus.Environment) (*Node, error) {
+	if id == "" {
+		return nil, fmt.Errorf("node id is required")
+	}
+	if cfg.ElectionTimeout <= 0 {
+		return nil, fmt.Errorf("election timeout must be positive, got %v", cfg.ElectionTimeout)
+	}
+	if cfg.HeartbeatInterval <= 0 {
+		return nil, fmt.Errorf("heartbeat interval must be positive, got %v", cfg.HeartbeatInterval)
+	}
+	if cfg.HeartbeatInterval >= cfg.ElectionTimeout {
+		return nil, fmt.Errorf("heartbeat interval %v must be shorter than election timeout %v",
+			cfg.HeartbeatInterval, cfg.ElectionTimeout)
+	}
+
+	seen := map[string]bool{id: true}
+	peers := make([]string, 0, len(cfg.Peers))
+	for _, peer := range cfg.Peers {
+		if !seen[peer] {
+			seen[peer] = true
+			peers = append(peers, peer)
+		}
+	}
+	sort.Strings(peers)
+
+	n := &Node{
+		id:       id,
+		config:   cfg,
+		peers:    peers,
+		state:    consensus.StateFollower,
+		accepted: make(map[int64]acceptedValue),
+		chosen:   make(map[int64][]byte),
+		logger:   logging.NewNoOpLogger(),
+		clock:    clock.Real(),
+		rng:      rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())),
+	}
+	if err := n.Attach(env); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// Attach Implements consensus.Attachable
+func (n *Node) Attach(env consensus.Environment) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.started {
+		return fmt.Errorf("node %s is already started", n.id)
+	}
+	if env.Transport != nil {
+		n.transport = env.Transport
+	}
+	if env.StateMachine != nil {
+		n.sm = env.StateMachine
+	}
+	if env.Logger != nil {
+		n.logger = env.Logger.With(logging.String("node_id", n.id))
+	}
+	if env.Clock != nil {
+		n.clock = env.Clock
+	}
+	if env.Rand != nil {
+		n.rng = env.Rand
+	}
+	if env.Storage != nil {
+		n.storage = env.Storage
+	}
+	return nil
+}
+
+// Start Implements consensus.Node
+func (n *Node) Start(ctx context.Context) error {
+	n.mu.Lock()
+	if n.started {
+		n.mu.Unlock()
+		return fmt.Errorf("node %s is already started", n.id)
+	}
+	if n.state == consensus.StateStopped {
+		n.mu.Unlock()
+		return consensus.ErrStopped
+	}
+	if n.transport == nil {
+		n.mu.Unlock()
+		return fmt.Errorf("node %s has no transport attached", n.id)
+	}
+	if err := n.recover(); err != nil {
+		n.mu.Unlock()
+		return fmt.Errorf("node %s: recover state: %w", n.id, err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	n.cancel = cancel
+	n.started = true
+	n.resetElectionTimer()
+	transport := n.transport
+	n.mu.Unlock()
+
+	if ct, ok := transport.(consensus.CallbackTransport); ok {
+		ct.OnReceive(func(msg consensus.Message) {
+			n.step(func() { n.handleMessage(msg) })
+		})
+		context.AfterFunc(ctx, func() { n.Stop() })
+		return nil
+	}
+
+	go n.run(ctx)
+	return nil
+}
+
+// Stop Implements consensus.Node. Pending writes are synced first.
+func (n *Node) Stop() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.halt(false)
+}
+
+// Crash Implements consensus.Crashable. Writes not yet synced are lost.
+func (n *Node) Crash() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.halt(true)
+}
+
+func (n *Node) halt(crash bool) error {
+	if n.state == consensus.StateStopped {
+		return nil
+	}
+	n.state = consensus.StateStopped
+	n.stopTimers()
+	n.outbox = nil
+	if n.cancel != nil {
+		n.cancel()
+	}
+
+	if n.storage == nil {
+		return nil
+	}
+	store := n.storage
+	n.storage = nil
+	if crashable, ok := store.(consensus.Crashable); ok && crash {
+		return crashable.Crash()
+	}
+	return store.Close()
+}
+
+// ID Implements consensus.Node
+func (n *Node) ID() string {
+	return n.id
+}
+
+// IsLeader Implements consensus.Node
+func (n *Node) IsLeader() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.state == consensus.StateLeader
+}
+
+// GetState Implements consensus.Node. A node is a Candidate while its
+// ballot is in phase 1 and a Leader once a majority has promised it.
+func (n *Node) GetState() consensus.NodeState {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.state
+}
+
+// Propose Implements consensus.Node. The command is assigned the next free
+// slot and applied once a majority of acceptors has accepted it.
+func (n *Node) Propose(data []byte) error {
+	var err error
+	n.step(func() {
+		switch n.state {
+		case consensus.StateStopped:
+			err = consensus.ErrStopped
+			return
+		case consensus.StateLeader:
+		default:
+			err = consensus.ErrNotLeader
+			return
+		}
+
+		slot := n.nextSlot
+		n.nextSlot++
+		n.propose(slot, data)
+	})
+	return err
+}
+
+// Term returns the round of the highest ballot this node has promised
+func (n *Node) Term() int64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.promised.Round
+}
+
+// Leader returns the ID of the proposer this node currently follows, if known
+func (n *Node) Leader() string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.leaderID
+}
+
+// CommitIndex returns the highest slot up to which every value is chosen
+func (n *Node) CommitIndex() int64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.applied
+}
+
+// Status Implements consensus.Inspectable. The log is the sequence of
+// chosen slots; the term of an entry is the round it was accepted in, if
+// this node accepted it.
+func (n *Node) Status(entries int) consensus.NodeStatus {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	status := consensus.NodeStatus{
+		ID:          n.id,
+		State:       n.state,
+		Term:        n.promised.Round,
+		VotedFor:    n.promised.Node,
+		Leader:      n.leaderID,
+		CommitIndex: n.applied,
+		LastApplied: n.applied,
+		LogLength:   int64(len(n.chosen)),
+	}
+	for slot := max(n.applied-int64(entries), 0) + 1; slot <= n.applied; slot++ {
+		status.Entries = append(status.Entries, consensus.Entry{
+			Index:   slot,
+			Term:    n.accepted[slot].Ballot.Round,
+			Command: n.chosen[slot],
+		})
+	}
+	if n.state == consensus.StateLeader {
+		status.Peers = make(map[string]consensus.PeerProgress, len(n.peers))
+		for _, peer := range n.peers {
+			status.Peers[peer] = consensus.PeerProgress{
+				MatchIndex: n.peerApplied[peer],
+				NextIndex:  n.peerApplied[peer] + 1,
+			}
+		}
+	}
+	return status
+}
+
+// Receives messages until the context is cancelled or the transport
+// closes. Used for transports that only offer a Receive channel.
+func (n *Node) run(ctx context.Context) {
+	inbox := n.transport.Receive()
+	for {
+		select {
+		case <-ctx.Done():
+			n.Stop()
+			return
+		case msg, ok := <-inbox:
+			if !ok {
+				n.Stop()
+				return
+			}
+			n.step(func() { n.handleMessage(msg) })
+		}
+	}
+}
+
+// Runs fn under the node lock, persists what it changed, then flushes
+// any messages it queued. On slow storage the messages leave once the
+// last sync would have completed, even those of a step that wrote nothing,
+// and they leave in order.
+func (n *Node) step(fn func()) {
+	n.mu.Lock()
+	fn()
+	now := n.clock.Now()
+	if delay := n.persist(); delay > 0 && now.Add(delay).After(n.syncedAt) {
+		n.syncedAt = now.Add(delay)
+	}
+	hold := n.syncedAt.Sub(now)
+	outbox := n.outbox
+	n.outbox = nil
+	transport := n.transport
+	n.mu.Unlock()
+
+	if hold > 0 && len(outbox) > 0 {
+		n.clock.AfterFunc(hold, func() {
+			if n.GetState() != consensus.StateStopped {
+				n.flush(transport, outbox)
+			}
+		})
+		return
+	}
+	n.flush(transport, outbox)
+}
+
+func (n *Node) flush(transport consensus.Transport, outbox []consensus.Message) {
+	for _, msg := range outbox {
+		if err := transport.Send(msg.To, msg); err != nil {
+			n.logger.Debug("send failed", logging.String("to", msg.To), logging.Error(err))
+		}
+	}
+}
+
+func (n *Node) handleMessage(msg consensus.Message) {
+	if n.state == consensus.StateStopped {
+		return
+	}
+
+	var err error
+	switch msg.Type {
+	case consensus.MessagePrepare:
+		var req prepareRequest
+		if err = decode(msg.Data, &req); err == nil {
+			n.handlePrepare(msg, req)
+		}
+	case consensus.MessagePromise:
+		var resp promiseResponse
+		if err = decode(msg.Data, &resp); err == nil {
+			n.handlePromise(msg, resp)
+		}
+	case consensus.MessageAccept:
+		var req acceptRequest
+		if err = decode(msg.Data, &req); err == nil {
+			n.handleAccept(msg, req)
+		}
+	case consensus.MessageAccepted:
+		var resp acceptedResponse
+		if err = decode(msg.Data, &resp); err == nil {
+			n.handleAccepted(msg, resp)
+		}
+	case consensus.MessageHeartbeat:
+		var hb heartbeat
+		if err = decode(msg.Data, &hb); err == nil {
+			n.handleHeartbeat(msg, hb)
+		}
+	default:
+		n.logger.Debug("ignoring message", logging.Int("type", int(msg.Type)), logging.String("from", msg.From))
+	}
+
+	if err != nil {
+		n.logger.Warn("dropping malformed message", logging.String("from", msg.From), logging.Error(err))
+	}
+}
+
+// Phase 1
+
+func (n *Node) startElection() {
+	round := max(n.promised.Round, n.ballot.Round) + 1
+	n.ballot = Ballot{Round: round, Node: n.id}
+	n.promised = n.ballot
+	n.state = consensus.StateCandidate
+	n.leaderID = ""
+	n.promises = map[string]promiseResponse{
+		n.id: {Ballot: n.ballot, OK: true, Accepted: n.acceptedFrom(n.applied + 1)},
+	}
+	n.resetElectionTimer()
+
+	n.logger.Debug("starting phase 1", logging.String("ballot", n.ballot.String()))
+
+	req := prepareRequest{Ballot: n.ballot, FromSlot: n.applied + 1}
+	for _, peer := range n.peers {
+		n.send(peer, consensus.MessagePrepare, req)
+	}
+
+	if n.hasQuorum(len(n.promises)) {
+		n.becomeLeader()
+	}
+}
+
+func (n *Node) handlePrepare(msg consensus.Message, req prepareRequest) {
+	if req.Ballot.Less(n.promised) {
+		n.send(msg.From, consensus.MessagePromise, promiseResponse{Ballot: req.Ballot, Promised: n.promised})
+		return
+	}
+
+	if n.promised.Less(req.Ballot) {
+		n.promised = req.Ballot
+		n.becomeFollower("")
+	}
+	n.resetElectionTimer()
+
+	n.send(msg.From, consensus.MessagePromise, promiseResponse{
+		Ballot:   req.Ballot,
+		OK:       true,
+		Promised: n.promised,
+		Accepted: n.acceptedFrom(req.FromSlot),
+	})
+}
+
+func (n *Node) handlePromise(msg consensus.Message, resp promiseResponse) {
+	if !resp.OK {
+		n.observeBallot(resp.Promised)
+		return
+	}
+	if n.state != consensus.StateCandidate || resp.Ballot != n.ballot {
+		return
+	}
+
+	n.promises[msg.From] = resp
+	if n.hasQuorum(len(n.promises)) {
+		n.becomeLeader()
+	}
+}
+
+func (n *Node) becomeLeader() {
+	n.state = consensus.StateLeader
+	n.leaderID = n.id
+	n.stopElectionTimer()
+
+	// Any slot a promising acceptor has accepted may already be chosen, so
+	// it must be re-proposed with the value from the highest ballot
+	recovered := make(map[int64]acceptedValue)
+	maxSlot := n.applied
+	for _, promise := range n.promises {
+		for _, av := range promise.Accepted {
+			if av.Slot <= n.applied {
+				continue
+			}
+			if current, ok := recovered[av.Slot]; !ok || current.Ballot.Less(av.Ballot) {
+				recovered[av.Slot] = av
+			}
+			maxSlot = max(maxSlot, av.Slot)
+		}
+	}
+	for slot := range n.chosen {
+		maxSlot = max(maxSlot, slot)
+	}
+	n.promises = nil
+
+	n.proposals = make(map[int64][]byte)
+	n.acceptances = make(map[int64]map[string]bool)
+	n.peerApplied = make(map[string]int64, len(n.peers))
+	n.nextSlot = maxSlot + 1
+
+	n.logger.Info("became leader",
+		logging.String("ballot", n.ballot.String()),
+		logging.Int("recovered", len(recovered)))
+
+	for slot := n.applied + 1; slot <= maxSlot; slot++ {
+		if _, ok := n.chosen[slot]; ok {
+			continue
+		}
+		// Slots nobody accepted are filled with a no-op
+		n.propose(slot, recovered[slot].Value)
+	}
+
+	n.broadcastHeartbeat()
+	n.resetHeartbeatTimer()
+}
+
+// Phase 2
+
+func (n *Node) propose(slot int64, value []byte) {
+	n.proposals[slot] = value
+	n.acceptances[slot] = map[string]bool{n.id: true}
+	n.accept(acceptedValue{Slot: slot, Ballot: n.ballot, Value: value})
+
+	req := acceptRequest{Ballot: n.ballot, Slot: slot, Value: value}
+	for _, peer := range n.peers {
+		n.send(peer, consensus.MessageAccept, req)
+	}
+	n.checkChosen(slot)
+}
+
+// Sends Accept again for the oldest slots not yet chosen, to the acceptors
+// that have not accepted them, in case the request or its answer was lost.
+// Slots are learned in order, so one lost message would otherwise hold
+// back every slot after it.
+func (n *Node) resendAccepts() {
+	slots := make([]int64, 0, len(n.proposals))
+	for slot := range n.proposals {
+		slots = append(slots, slot)
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i] < slots[j] })
+	if len(slots) > maxResentPerHeartbeat {
+		slots = slots[:maxResentPerHeartbeat]
+	}
+
+	for _, slot := range slots {
+		req := acceptRequest{Ballot: n.ballot, Slot: slot, Value: n.proposals[slot]}
+		for _, peer := range n.peers {
+			if !n.acceptances[slot][peer] {
+				n.send(peer, consensus.MessageAccept, req)
+			}
+		}
+	}
+}
+
+func (n *Node) handleAccept(msg consensus.Message, req acceptRequest) {
+	if req.Ballot.Less(n.promised) {
+		n.send(msg.From, consensus.MessageAccepted, acceptedResponse{
+			Ballot:   req.Ballot,
+			Slot:     req.Slot,
+			Promised: n.promised,
+		})
+		return
+	}
+
+	n.promised = req.Ballot
+	if n.state != consensus.StateFollower {
+		n.becomeFollower(req.Ballot.Node)
+	}
+	n.leaderID = req.Ballot.Node
+	n.resetElectionTimer()
+
+	n.accept(acceptedValue{Slot: req.Slot, Ballot: req.Ballot, Value: req.Value})
+	n.send(msg.From, consensus.MessageAccepted, acceptedResponse{
+		Ballot:   req.Ballot,
+		Slot:     req.Slot,
+		OK:       true,
+		Promised: n.promised,
+	})
+}
+
+func (n *Node) handleAccepted(msg consensus.Message, resp acceptedResponse) {
+	if !resp.OK {
+		n.observeBallot(resp.Promised)
+		return
+	}
+	if n.state != consensus.StateLeader || resp.Ballot != n.ballot {
+		return
+	}
+
+	acceptances, ok := n.acceptances[resp.Slot]
+	if !ok {
+		return
+	}
+	acceptances[msg.From] = true
+	n.checkChosen(resp.Slot)
+}
+
+func (n *Node) checkChosen(slot int64) {
+	if !n.hasQuorum(len(n.acceptances[slot])) {
+		return
+	}
+
+	value := n.proposals[slot]
+	delete(n.proposals, slot)
+	delete(n.acceptances, slot)
+	n.learn(slot, value)
+	n.broadcastHeartbeat()
+}
+
+func (n *Node) accept(av acceptedValue) {
+	n.accepted[av.Slot] = av
+	n.unsaved = append(n.unsaved, av)
+}
+
+// Learning
+
+func (n *Node) learn(slot int64, value []byte) {
+	if _, ok := n.chosen[slot]; ok || slot <= n.applied {
+		return
+	}
+	n.chosen[slot] = value
+
+	for {
+		value, ok := n.chosen[n.applied+1]
+		if !ok {
+			return
+		}
+		n.applied++
+		if len(value) == 0 || n.sm == nil {
+			continue
+		}
+		if _, err := n.sm.Apply(value); err != nil {
+			n.logger.Warn("state machine rejected value",
+				logging.Int64("slot", n.applied), logging.Error(err))
+		}
+	}
+}
+
+func (n *Node) broadcastHeartbeat() {
+	for _, peer := range n.peers {
+		n.sendHeartbeat(peer)
+	}
+}
+
+func (n *Node) sendHeartbeat(peer string) {
+	hb := heartbeat{Ballot: n.ballot, Applied: n.applied}
+	for slot := n.peerApplied[peer] + 1; slot <= n.applied && len(hb.Chosen) < maxChosenPerHeartbeat; slot++ {
+		hb.Chosen = append(hb.Chosen, chosenValue{Slot: slot, Value: n.chosen[slot]})
+	}
+	n.send(peer, consensus.MessageHeartbeat, hb)
+}
+
+func (n *Node) handleHeartbeat(msg consensus.Message, hb heartbeat) {
+	if hb.Ack {
+		// Acks carry the learner's promised ballot
+		if n.ballot.Less(hb.Ballot) {
+			n.observeBallot(hb.Ballot)
+			return
+		}
+		if n.state == consensus.StateLeader && hb.Ballot == n.ballot {
+			n.peerApplied[msg.From] = max(n.peerApplied[msg.From], hb.Applied)
+		}
+		return
+	}
+
+	if hb.Ballot.Less(n.promised) {
+		n.send(msg.From, consensus.MessageHeartbeat, heartbeat{Ballot: n.promised, Ack: true, Applied: n.applied})
+		return
+	}
+
+	n.promised = hb.Ballot
+	if n.state != consensus.StateFollower {
+		n.becomeFollower(hb.Ballot.Node)
+	}
+	n.leaderID = hb.Ballot.Node
+	n.resetElectionTimer()
+
+	for _, cv := range hb.Chosen {
+		n.learn(cv.Slot, cv.Value)
+	}
+	n.send(msg.From, consensus.MessageHeartbeat, heartbeat{Ballot: n.promised, Ack: true, Applied: n.applied})
+}
+
+// Steps down if another proposer holds a higher ballot
+func (n *Node) observeBallot(ballot Ballot) {
+	if !n.promised.Less(ballot) {
+		return
+	}
+	n.promised = ballot
+	n.becomeFollower("")
+}
+
+func (n *Node) becomeFollower(leaderID string) {
+	wasLeader := n.state == consensus.StateLeader
+	n.state = consensus.StateFollower
+	n.leaderID = leaderID
+	n.promises = nil
+	n.proposals = nil
+	n.acceptances = nil
+	n.peerApplied = nil
+	n.stopHeartbeatTimer()
+	n.resetElectionTimer()
+
+	if wasLeader {
+		n.logger.Info("preempted", logging.String("promised", n.promised.String()))
+	}
+}
+
+// Persistence
+
+// Opens in-memory storage if none was supplied and loads the promised
+// ballot and accepted values from it
+func (n *Node) recover() error {
+	if n.storage == nil {
+		n.storage = storage.NewMemory()
+	}
+
+	hard, err := n.storage.GetHardState()
+	if err != nil {
+		return err
+	}
+	snapshot, err := n.storage.GetSnapshot()
+	if err != nil {
+		return err
+	}
+	entries, err := n.storage.Entries(n.storage.FirstIndex(), n.storage.LastIndex()+1)
+	if err != nil {
+		return err
+	}
+
+	var values []acceptedValue
+	if snapshot.Index > 0 {
+		if err := decode(snapshot.Data, &values); err != nil {
+			return fmt.Errorf("decode snapshot: %w", err)
+		}
+	}
+	for _, entry := range entries {
+		var av acceptedValue
+		if err := decode(entry.Command, &av); err != nil {
+			n.logger.Warn("skipping unreadable accept record",
+				logging.Int64("index", entry.Index), logging.Error(err))
+			continue
+		}
+		values = append(values, av)
+	}
+
+	// Later records override earlier ones for the same slot
+	for _, av := range values {
+		n.accepted[av.Slot] = av
+	}
+	n.promised = Ballot{Round: hard.Term, Node: hard.VotedFor}
+	n.saved = hard
+	n.logged = n.storage.LastIndex()
+	n.snapshotIndex = snapshot.Index
+	return nil
+}
+
+// Writes the promised ballot and the values accepted since the last write
+// and returns how long the sync took. Once half as many records are logged
+// as there are accepted slots, they are compacted into a snapshot. A node
+// that cannot persist its state cannot safely go on, so it stops.
+func (n *Node) persist() time.Duration {
+	if n.storage == nil || n.state == consensus.StateStopped {
+		return 0
+	}
+	hard := consensus.HardState{Term: n.promised.Round, VotedFor: n.promised.Node}
+	if hard == n.saved && len(n.unsaved) == 0 {
+		return 0
+	}
+
+	var err error
+	if hard != n.saved {
+		err = n.storage.SetHardState(hard)
+	}
+	if err == nil && len(n.unsaved) > 0 {
+		entries := make([]consensus.Entry, len(n.unsaved))
+		for i, av := range n.unsaved {
+			entries[i] = consensus.Entry{Index: n.logged + int64(i) + 1, Term: av.Ballot.Round, Command: encode(av)}
+		}
+		if err = n.storage.Append(entries); err == nil {
+			n.logged += int64(len(entries))
+		}
+	}
+	if err == nil && n.logged-n.snapshotIndex >= max(int64(len(n.accepted))/2, minCompaction) {
+		err = n.storage.SetSnapshot(consensus.Snapshot{
+			Index: n.logged,
+			Term:  n.promised.Round,
+			Data:  encode(n.acceptedFrom(0)),
+		})
+		if err == nil {
+			n.snapshotIndex = n.logged
+		}
+	}
+	if err == nil {
+		err = n.storage.Sync()
+	}
+	if err != nil {
+		n.logger.Error("persisting state failed, stopping", logging.Error(err))
+		n.halt(true)
+		return 0
+	}
+	n.saved, n.unsaved = hard, nil
+
+	if slow, ok := n.storage.(consensus.SlowStorage); ok {
+		return slow.SyncDelay()
+	}
+	return 0
+}
+
+// Timers
+
+func (n *Node) randomElectionTimeout() time.Duration {
+	timeout := n.config.ElectionTimeout
+	return timeout + time.Duration(n.rng.Int64N(int64(timeout)))
+}
+
+func (n *Node) resetElectionTimer() {
+	n.stopElectionTimer()
+	gen := n.electionGen
+	n.electionTimer = n.clock.AfterFunc(n.randomElectionTimeout(), func() {
+		n.step(func() {
+			if gen != n.electionGen || n.state == consensus.StateStopped || n.state == consensus.StateLeader {
+				return
+			}
+			n.startElection()
+		})
+	})
+}
+
+func (n *Node) stopElectionTimer() {
+	n.electionGen++
+	if n.electionTimer != nil {
+		n.electionTimer.Stop()
+		n.electionTimer = nil
+	}
+}
+
+func (n *Node) resetHeartbeatTimer() {
+	n.stopHeartbeatTimer()
+	gen := n.heartbeatGen
+	n.heartbeatTimer = n.clock.AfterFunc(n.config.HeartbeatInterval, func() {
+		n.step(func() {
+			if gen != n.heartbeatGen || n.state != consensus.StateLeader {
+				return
+			}
+			n.resendAccepts()
+			n.broadcastHeartbeat()
+			n.resetHeartbeatTimer()
+		})
+	})
+}
+
+func (n *Node) stopHeartbeatTimer() {
+	n.heartbeatGen++
+	if n.heartbeatTimer != nil {
+		n.heartbeatTimer.Stop()
+		n.heartbeatTimer = nil
+	}
+}
+
+func (n *Node) stopTimers() {
+	n.stopElectionTimer()
+	n.stopHeartbeatTimer()
+}
+
+// Helpers
+
+func (n *Node) send(to string, msgType consensus.MessageType, payload interface{}) {
+	n.outbox = append(n.outbox, consensus.Message{
+		Type:      msgType,
+		From:      n.id,
+		To:        to,
+		Term:      n.promised.Round,
+		Data:      encode(payload),
+		Timestamp: n.clock.Now(),
+	})
+}
+
+func (n *Node) hasQuorum(count int) bool {
+	return count > (len(n.peers)+1)/2
+}
+
+// Returns the accepted values for every slot at or after from, in slot order
+func (n *Node) acceptedFrom(from int64) []acceptedValue {
+	values := make([]acceptedValue, 0)
+	for slot, av := range n.accepted {
+		if slot >= from {
+			values = append(values, av)
+		}
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].Slot < values[j].Slot })
+	return values
+}
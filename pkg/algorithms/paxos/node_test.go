@@ -0,0 +1,595 @@
+package paxos
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/config"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/consensus"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/network"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/storage"
+)
+
+// Records applied commands in order
+type recordingStateMachine struct {
+	mu      sync.Mutex
+	applied []string
+}
+
+func (r *recordingStateMachine) Apply(data []byte) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.applied = append(r.applied, string(data))
+	return nil, nil
+}
+
+func (r *recordingStateMachine) Snapshot() ([]byte, error)     { return nil, nil }
+func (r *recordingStateMachine) Restore(snapshot []byte) error { return nil }
+
+func (r *recordingStateMachine) GetState() interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.applied...)
+}
+
+type testCluster struct {
+	manager *network.NetworkManager
+	nodes   map[string]*Node
+	sms     map[string]*recordingStateMachine
+}
+
+func newTestCluster(t *testing.T, size int) *testCluster {
+	t.Helper()
+
+	ids := make([]string, size)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("node-%d", i+1)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Peers = ids
+	cfg.ElectionTimeout = 50 * time.Millisecond
+	cfg.HeartbeatInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &testCluster{
+		manager: network.NewNetworkManager(),
+		nodes:   make(map[string]*Node),
+		sms:     make(map[string]*recordingStateMachine),
+	}
+	t.Cleanup(func() {
+		cancel()
+		for _, node := range c.nodes {
+			node.Stop()
+		}
+		c.manager.Shutdown()
+	})
+
+	for _, id := range ids {
+		sm := &recordingStateMachine{}
+		node, err := NewNode(id, cfg, consensus.Environment{
+			Transport:    c.manager.CreateNode(id),
+			StateMachine: sm,
+		})
+		if err != nil {
+			t.Fatalf("NewNode failed: %v", err)
+		}
+		c.nodes[id] = node
+		c.sms[id] = sm
+	}
+	for _, node := range c.nodes {
+		if err := node.Start(ctx); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+	}
+	return c
+}
+
+func (c *testCluster) waitForLeader(t *testing.T, exclude string) *Node {
+	t.Helper()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		var leader *Node
+		leaders := 0
+		for id, node := range c.nodes {
+			if id != exclude && node.IsLeader() {
+				leader = node
+				leaders++
+			}
+		}
+		if leaders == 1 {
+			return leader
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("No single leader elected in time")
+	return nil
+}
+
+func (c *testCluster) waitForApplied(t *testing.T, want []string, exclude string) {
+	t.Helper()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		done := true
+		for id, sm := range c.sms {
+			if id == exclude {
+				continue
+			}
+			if len(sm.GetState().([]string)) < len(want) {
+				done = false
+			}
+		}
+		if done {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	for id, sm := range c.sms {
+		if id == exclude {
+			continue
+		}
+		applied := sm.GetState().([]string)
+		if len(applied) != len(want) {
+			t.Fatalf("Node %s applied %d entries, expected %d", id, len(applied), len(want))
+		}
+		for i := range want {
+			if applied[i] != want[i] {
+				t.Errorf("Node %s entry %d: expected %q, got %q", id, i, want[i], applied[i])
+			}
+		}
+	}
+}
+
+func TestNewNodeValidation(t *testing.T) {
+	cfg := config.DefaultConfig()
+
+	if _, err := NewNode("", cfg, consensus.Environment{}); err == nil {
+		t.Error("Expected error for empty node id")
+	}
+
+	bad := cfg
+	bad.HeartbeatInterval = bad.ElectionTimeout
+	if _, err := NewNode("node-1", bad, consensus.Environment{}); err == nil {
+		t.Error("Expected error when heartbeat interval is not shorter than election timeout")
+	}
+
+	node, err := NewNode("node-1", cfg, consensus.Environment{})
+	if err != nil {
+		t.Fatalf("NewNode failed: %v", err)
+	}
+	if node.GetState() != consensus.StateFollower {
+		t.Errorf("Expected Follower, got %v", node.GetState())
+	}
+	if err := node.Start(context.Background()); err == nil {
+		t.Error("Start should fail without a transport")
+	}
+}
+
+func TestAlgorithm(t *testing.T) {
+	algorithm := New()
+	if algorithm.Name() != "paxos" {
+		t.Errorf("Expected name 'paxos', got '%s'", algorithm.Name())
+	}
+
+	node, err := algorithm.CreateNode("node-1", config.DefaultConfig())
+	if err != nil {
+		t.Fatalf("CreateNode failed: %v", err)
+	}
+	if _, ok := node.(consensus.Attachable); !ok {
+		t.Error("Paxos nodes should be attachable")
+	}
+}
+
+func TestSingleNodeCommits(t *testing.T) {
+	cluster := newTestCluster(t, 1)
+	leader := cluster.waitForLeader(t, "")
+
+	if err := leader.Propose([]byte("x=1")); err != nil {
+		t.Fatalf("Propose failed: %v", err)
+	}
+	cluster.waitForApplied(t, []string{"x=1"}, "")
+}
+
+func TestLeaderElection(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	leader := cluster.waitForLeader(t, "")
+
+	for id, node := range cluster.nodes {
+		if id == leader.ID() {
+			continue
+		}
+		if err := node.Propose([]byte("x")); err != consensus.ErrNotLeader {
+			t.Errorf("Expected ErrNotLeader from follower %s, got %v", id, err)
+		}
+	}
+}
+
+func TestLogReplication(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	leader := cluster.waitForLeader(t, "")
+
+	want := []string{"a", "b", "c", "d", "e"}
+	for _, cmd := range want {
+		if err := leader.Propose([]byte(cmd)); err != nil {
+			t.Fatalf("Propose failed: %v", err)
+		}
+	}
+	cluster.waitForApplied(t, want, "")
+
+	for id, node := range cluster.nodes {
+		if node.CommitIndex() < int64(len(want)) {
+			t.Errorf("Node %s commit index %d, expected at least %d", id, node.CommitIndex(), len(want))
+		}
+	}
+}
+
+func TestStatus(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	leader := cluster.waitForLeader(t, "")
+
+	want := []string{"a", "b", "c"}
+	for _, cmd := range want {
+		if err := leader.Propose([]byte(cmd)); err != nil {
+			t.Fatalf("Propose failed: %v", err)
+		}
+	}
+	cluster.waitForApplied(t, want, "")
+
+	status := leader.Status(2)
+	if status.ID != leader.ID() || status.State != consensus.StateLeader || status.Leader != leader.ID() {
+		t.Errorf("Unexpected leader status %+v", status)
+	}
+	if status.Term != leader.Term() || status.CommitIndex != leader.CommitIndex() || status.LastApplied != status.CommitIndex {
+		t.Errorf("Status disagrees with the node: %+v", status)
+	}
+	if status.LogLength < int64(len(want)) {
+		t.Errorf("Expected at least %d entries, got %d", len(want), status.LogLength)
+	}
+	if len(status.Entries) != 2 || string(status.Entries[1].Command) != "c" || status.Entries[1].Index != status.CommitIndex {
+		t.Errorf("Expected the last 2 entries, got %+v", status.Entries)
+	}
+	if len(status.Peers) != 2 {
+		t.Errorf("Expected progress for 2 peers, got %+v", status.Peers)
+	}
+	for peer, progress := range status.Peers {
+		if progress.NextIndex <= progress.MatchIndex {
+			t.Errorf("Peer %s: next index %d should be past match index %d", peer, progress.NextIndex, progress.MatchIndex)
+		}
+	}
+
+	for id, node := range cluster.nodes {
+		if id == leader.ID() {
+			continue
+		}
+		status := node.Status(0)
+		if status.Leader != leader.ID() || status.Peers != nil || status.Entries != nil {
+			t.Errorf("Unexpected follower status %+v", status)
+		}
+	}
+}
+
+func TestLeaderFailover(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	leader := cluster.waitForLeader(t, "")
+	oldTerm := leader.Term()
+
+	if err := leader.Propose([]byte("before")); err != nil {
+		t.Fatalf("Propose failed: %v", err)
+	}
+	cluster.waitForApplied(t, []string{"before"}, "")
+
+	leader.Stop()
+	if leader.GetState() != consensus.StateStopped {
+		t.Errorf("Expected Stopped, got %v", leader.GetState())
+	}
+
+	newLeader := cluster.waitForLeader(t, leader.ID())
+	if newLeader.Term() <= oldTerm {
+		t.Errorf("Expected term greater than %d, got %d", oldTerm, newLeader.Term())
+	}
+
+	if err := newLeader.Propose([]byte("after")); err != nil {
+		t.Fatalf("Propose failed: %v", err)
+	}
+	cluster.waitForApplied(t, []string{"before", "after"}, leader.ID())
+}
+
+func TestBallotOrdering(t *testing.T) {
+	tests := []struct {
+		a, b     Ballot
+		expected bool
+	}{
+		{Ballot{1, "node-1"}, Ballot{2, "node-1"}, true},
+		{Ballot{2, "node-1"}, Ballot{1, "node-2"}, false},
+		{Ballot{1, "node-1"}, Ballot{1, "node-2"}, true},
+		{Ballot{1, "node-2"}, Ballot{1, "node-2"}, false},
+		{Ballot{}, Ballot{1, "node-1"}, true},
+	}
+
+	for _, test := range tests {
+		if result := test.a.Less(test.b); result != test.expected {
+			t.Errorf("%v.Less(%v): expected %v, got %v", test.a, test.b, test.expected, result)
+		}
+	}
+}
+
+func TestAcceptorRejectsLowerBallot(t *testing.T) {
+	node, err := NewNode("node-1", config.DefaultConfig(), consensus.Environment{})
+	if err != nil {
+		t.Fatalf("NewNode failed: %v", err)
+	}
+
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	node.handlePrepare(consensus.Message{From: "node-2"}, prepareRequest{Ballot: Ballot{5, "node-2"}, FromSlot: 1})
+	node.handleAccept(consensus.Message{From: "node-3"}, acceptRequest{Ballot: Ballot{4, "node-3"}, Slot: 1, Value: []byte("stale")})
+	node.stopTimers()
+
+	if _, ok := node.accepted[1]; ok {
+		t.Error("Acceptor should not accept a value below its promised ballot")
+	}
+	if node.promised != (Ballot{5, "node-2"}) {
+		t.Errorf("Expected promise for 5.node-2, got %v", node.promised)
+	}
+}
+
+func TestLossyLinksKeepChoosing(t *testing.T) {
+	ids := []string{"node-1", "node-2", "node-3"}
+	cfg := config.DefaultConfig()
+	cfg.Peers = ids
+	sim := network.NewSimulator(1)
+	manager := network.NewSimulatedNetworkManager(sim)
+	defer manager.Shutdown()
+	conditions := network.DefaultNetworkConditions()
+	conditions.PacketLoss = 0.1
+	manager.SetAllLinks(conditions)
+
+	nodes := make([]*Node, 0, len(ids))
+	sms := make([]*recordingStateMachine, 0, len(ids))
+	for _, id := range ids {
+		sm := &recordingStateMachine{}
+		node, err := NewNode(id, cfg, consensus.Environment{
+			Transport:    manager.CreateNode(id),
+			StateMachine: sm,
+			Clock:        sim,
+			Rand:         sim.NewRand("node/" + id),
+		})
+		if err != nil {
+			t.Fatalf("NewNode failed: %v", err)
+		}
+		if err := node.Start(context.Background()); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		defer node.Stop()
+		nodes, sms = append(nodes, node), append(sms, sm)
+	}
+
+	// Lost Accept and Accepted messages must not hold back later slots
+	proposed := 0
+	for i := 0; i < 200; i++ {
+		sim.RunFor(20 * time.Millisecond)
+		for _, node := range nodes {
+			if node.IsLeader() && node.Propose([]byte(fmt.Sprint(i))) == nil {
+				proposed++
+			}
+		}
+	}
+	sim.RunFor(time.Second)
+
+	for i, sm := range sms {
+		if applied := len(sm.GetState().([]string)); applied < proposed*9/10 {
+			t.Errorf("%s applied %d of %d proposed commands", ids[i], applied, proposed)
+		}
+	}
+}
+
+func startSimulatedNode(t *testing.T, sim *network.Simulator, manager *network.NetworkManager,
+	cfg config.Config, store consensus.Storage) (*Node, *recordingStateMachine) {
+	t.Helper()
+
+	sm := &recordingStateMachine{}
+	node, err := NewNode("node-1", cfg, consensus.Environment{
+		Transport:    manager.CreateNode("node-1"),
+		StateMachine: sm,
+		Clock:        sim,
+		Rand:         sim.NewRand("node/node-1"),
+		Storage:      store,
+	})
+	if err != nil {
+		t.Fatalf("NewNode failed: %v", err)
+	}
+	if err := node.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	return node, sm
+}
+
+func TestRestartRecoversAcceptorState(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Peers = []string{"node-1", "node-2", "node-3"}
+	store := storage.NewMemory()
+	sim := network.NewSimulator(1)
+	manager := network.NewSimulatedNetworkManager(sim)
+	defer manager.Shutdown()
+
+	node, _ := startSimulatedNode(t, sim, manager, cfg, store)
+	node.step(func() {
+		node.handlePrepare(consensus.Message{From: "node-2"}, prepareRequest{Ballot: Ballot{5, "node-2"}, FromSlot: 1})
+		node.handleAccept(consensus.Message{From: "node-2"}, acceptRequest{Ballot: Ballot{5, "node-2"}, Slot: 1, Value: []byte("a")})
+		node.handleAccept(consensus.Message{From: "node-2"}, acceptRequest{Ballot: Ballot{5, "node-2"}, Slot: 2, Value: []byte("b")})
+	})
+	if err := node.Crash(); err != nil {
+		t.Fatalf("Crash failed: %v", err)
+	}
+
+	// A restarted acceptor keeps its promise and what it accepted, so a
+	// value a majority accepted stays chosen
+	node, _ = startSimulatedNode(t, sim, manager, cfg, store)
+	defer node.Stop()
+	node.step(func() {
+		node.handleAccept(consensus.Message{From: "node-3"}, acceptRequest{Ballot: Ballot{4, "node-3"}, Slot: 1, Value: []byte("stale")})
+	})
+
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	if node.promised != (Ballot{5, "node-2"}) {
+		t.Errorf("Expected promise for 5.node-2 to survive, got %v", node.promised)
+	}
+	if accepted := node.acceptedFrom(1); fmt.Sprintf("%s %s", accepted[0].Value, accepted[1].Value) != "a b" {
+		t.Errorf("Expected accepted values a and b to survive, got %+v", accepted)
+	}
+}
+
+func TestRestartRecoversCompactedState(t *testing.T) {
+	cfg := config.DefaultConfig()
+	store := storage.NewMemory()
+	sim := network.NewSimulator(1)
+	manager := network.NewSimulatedNetworkManager(sim)
+	defer manager.Shutdown()
+
+	node, _ := startSimulatedNode(t, sim, manager, cfg, store)
+	sim.RunWhile(func() bool { return !node.IsLeader() }, time.Second)
+	want := make([]string, 3*minCompaction)
+	for i := range want {
+		want[i] = fmt.Sprint(i)
+		if err := node.Propose([]byte(want[i])); err != nil {
+			t.Fatalf("Propose failed: %v", err)
+		}
+	}
+	if err := node.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if records := store.LastIndex() - store.FirstIndex() + 1; records > int64(len(want))/2 {
+		t.Errorf("Expected accept records to be compacted, %d of %d remain", records, len(want))
+	}
+
+	// Once it leads again it chooses and applies the recovered values
+	node, sm := startSimulatedNode(t, sim, manager, cfg, store)
+	defer node.Stop()
+	sim.RunWhile(func() bool { return !node.IsLeader() }, time.Second)
+	if applied := sm.GetState().([]string); fmt.Sprint(applied) != fmt.Sprint(want) {
+		t.Errorf("Expected the recovered values to be applied, got %d of %d", len(applied), len(want))
+	}
+}
+
+func TestSlowStorageHoldsLaterMessages(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Peers = []string{"node-1", "node-2", "node-3"}
+	sim := network.NewSimulator(1)
+	manager := network.NewSimulatedNetworkManager(sim)
+	defer manager.Shutdown()
+	disk, err := storage.NewFaulty(storage.NewMemory(), sim.NewRand("disk"))
+	if err != nil {
+		t.Fatalf("NewFaulty failed: %v", err)
+	}
+	disk.SetConditions(storage.DiskConditions{Latency: 10 * time.Millisecond})
+
+	node, _ := startSimulatedNode(t, sim, manager, cfg, disk)
+	defer node.Stop()
+	leader := manager.CreateNode("node-2")
+	var types []consensus.MessageType
+	var arrivals []time.Duration
+	leader.(consensus.CallbackTransport).OnReceive(func(msg consensus.Message) {
+		types = append(types, msg.Type)
+		arrivals = append(arrivals, sim.Elapsed())
+	})
+	send := func(msgType consensus.MessageType, payload interface{}) {
+		leader.Send("node-1", consensus.Message{Type: msgType, From: "node-2", To: "node-1", Data: encode(payload)})
+	}
+
+	// The heartbeat needs no write, but its ack reports the slot whose
+	// accept is still being synced, so it must not leave before it
+	ballot := Ballot{1, "node-2"}
+	send(consensus.MessageAccept, acceptRequest{Ballot: ballot, Slot: 1, Value: []byte("a")})
+	sim.RunFor(3 * time.Millisecond)
+	send(consensus.MessageHeartbeat, heartbeat{Ballot: ballot, Chosen: []chosenValue{{Slot: 1, Value: []byte("a")}}})
+	sim.RunFor(20 * time.Millisecond)
+
+	if fmt.Sprint(types) != fmt.Sprint([]consensus.MessageType{consensus.MessageAccepted, consensus.MessageHeartbeat}) {
+		t.Fatalf("Expected Accepted then the heartbeat ack, got %v", types)
+	}
+	for i, at := range arrivals {
+		if at < 11*time.Millisecond {
+			t.Errorf("Reply %d arrived at %v, before the sync completed", i+1, at)
+		}
+	}
+}
+
+func TestRegistered(t *testing.T) {
+	algorithm, err := consensus.Lookup(AlgorithmName)
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	if algorithm.Name() != AlgorithmName {
+		t.Errorf("Expected %s, got %s", AlgorithmName, algorithm.Name())
+	}
+}
+
+// Runs a simulated cluster and returns a trace of observable state
+func simulatedRun(t *testing.T, seed uint64) []string {
+	t.Helper()
+
+	ids := []string{"node-1", "node-2", "node-3"}
+	cfg := config.DefaultConfig()
+	cfg.Peers = ids
+
+	sim := network.NewSimulator(seed)
+	manager := network.NewSimulatedNetworkManager(sim)
+	nodes := make([]*Node, 0, len(ids))
+	for _, id := range ids {
+		node, err := NewNode(id, cfg, consensus.Environment{
+			Transport:    manager.CreateNode(id),
+			StateMachine: &recordingStateMachine{},
+			Clock:        sim,
+			Rand:         sim.NewRand("node/" + id),
+		})
+		if err != nil {
+			t.Fatalf("NewNode failed: %v", err)
+		}
+		nodes = append(nodes, node)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for _, node := range nodes {
+		if err := node.Start(ctx); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+	}
+
+	var trace []string
+	for i := 0; i < 100; i++ {
+		sim.RunFor(20 * time.Millisecond)
+		for _, node := range nodes {
+			if node.IsLeader() {
+				node.Propose([]byte(fmt.Sprint(i)))
+			}
+			trace = append(trace, fmt.Sprintf("%v %s %v %d %d",
+				sim.Elapsed(), node.ID(), node.GetState(), node.Term(), node.CommitIndex()))
+		}
+	}
+	return trace
+}
+
+func TestSimulatedRunDeterministic(t *testing.T) {
+	start := time.Now()
+	first := simulatedRun(t, 11)
+	second := simulatedRun(t, 11)
+
+	if fmt.Sprint(first) != fmt.Sprint(second) {
+		t.Error("Runs with the same seed should be identical")
+	}
+	if last := first[len(first)-1]; strings.HasSuffix(last, " 0") {
+		t.Errorf("Expected entries to commit, last observation: %s", last)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Two simulated 2s runs took %v of wall-clock time", elapsed)
+	}
+}
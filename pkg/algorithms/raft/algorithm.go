@@ -0,0 +1,34 @@
+// Package raft is a reference implementation of the Raft consensus
+// algorithm built on the consensus and config packages.
+package raft
+
+import (
+	"github.com/francisco-teixeirax86/consensusforge/pkg/config"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/consensus"
+)
+
+// Name under which the algorithm is known in config.Config.Algorithm
+const AlgorithmName = "raft"
+
+func init() {
+	consensus.Register(New())
+}
+
+// Implements consensus.Algorithm for Raft
+type Algorithm struct{}
+
+// Creates the Raft algorithm
+func New() *Algorithm {
+	return &Algorithm{}
+}
+
+// Name Implements consensus.Algorithm
+func (a *Algorithm) Name() string {
+	return AlgorithmName
+}
+
+// CreateNode Implements consensus.Algorithm. The returned node must be
+// given a transport through consensus.Attachable before it is started.
+func (a *Algorithm) CreateNode(id string, cfg config.Config) (consensus.Node, error) {
+	return NewNode(id, cfg, consensus.Environment{})
+}
@@ -0,0 +1,76 @@
+package raft
+
+import (
+	"encoding/json"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/consensus"
+)
+
+// Payload of a MessageRequestVote
+type requestVoteRequest struct {
+	CandidateID  string `json:"candidate_id"`
+	LastLogIndex int64  `json:"last_log_index"`
+	LastLogTerm  int64  `json:"last_log_term"`
+}
+
+// Payload of a MessageRequestVoteResponse
+type requestVoteResponse struct {
+	VoteGranted bool `json:"vote_granted"`
+}
+
+// Payload of a MessageAppendEntries
+type appendEntriesRequest struct {
+	LeaderID     string            `json:"leader_id"`
+	PrevLogIndex int64             `json:"prev_log_index"`
+	PrevLogTerm  int64             `json:"prev_log_term"`
+	Entries      []consensus.Entry `json:"entries,omitempty"`
+	LeaderCommit int64             `json:"leader_commit"`
+}
+
+// Payload of a MessageAppendEntriesResponse
+type appendEntriesResponse struct {
+	Success bool `json:"success"`
+
+	// Highest index known to match the leader when Success is set
+	MatchIndex int64 `json:"match_index"`
+
+	// Index the leader should retry from when Success is not set
+	ConflictIndex int64 `json:"conflict_index"`
+}
+
+// Payload of a MessageInstallSnapshot, carrying one chunk of the leader's
+// snapshot
+type installSnapshotRequest struct {
+	LeaderID          string `json:"leader_id"`
+	LastIncludedIndex int64  `json:"last_included_index"`
+	LastIncludedTerm  int64  `json:"last_included_term"`
+	Config            []byte `json:"config,omitempty"` // membership at LastIncludedIndex
+	Offset            int64  `json:"offset"`           // of the chunk in the snapshot data
+	Data              []byte `json:"data,omitempty"`
+	Done              bool   `json:"done"` // the chunk is the last one
+}
+
+// Payload of a MessageInstallSnapshotResponse
+type installSnapshotResponse struct {
+	// Snapshot the follower is receiving or has installed
+	LastIncludedIndex int64 `json:"last_included_index"`
+
+	// Bytes of it received so far, where the next chunk should start
+	Offset int64 `json:"offset"`
+
+	// Set once the follower holds everything the snapshot covers
+	Done bool `json:"done"`
+}
+
+func encode(payload interface{}) []byte {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		// Payloads are plain structs, marshalling cannot fail
+		panic(err)
+	}
+	return data
+}
+
+func decode(data []byte, payload interface{}) error {
+	return json.Unmarshal(data, payload)
+}
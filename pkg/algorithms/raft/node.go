@@ -0,0 +1,1396 @@
+package raft
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/clock"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/config"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/consensus"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/logging"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/storage"
+)
+
+// Implements consensus.Node using the Raft protocol
+type Node struct {
+	id        string
+	config    config.Config
+	peers     []string // every cluster member except this node, learners too
+	transport consensus.Transport
+	sm        consensus.StateMachine
+	logger    logging.Logger
+	clock     clock.Clock
+	rng       *rand.Rand
+
+	mu          sync.Mutex
+	state       consensus.NodeState
+	currentTerm int64
+	votedFor    string
+	leaderID    string
+	commitIndex int64
+	lastApplied int64
+	lastContact time.Time // when the leader was last heard from
+
+	// Cluster membership. The latest configuration in the log takes
+	// effect as soon as it is appended, committed or not.
+	initial      consensus.Membership // in effect until the log holds one
+	members      consensus.Membership
+	membersIndex int64 // of the entry members comes from, 0 for initial
+
+	// log[0] is a sentinel standing for the last entry the snapshot
+	// covers, at index 0 without a snapshot
+	log               []consensus.Entry
+	snapshot          consensus.Snapshot
+	snapshotThreshold int64 // applied entries kept in the log, 0 keeps them all
+	chunkSize         int64
+
+	// Follower state: the snapshot being received, with the chunks so far
+	incoming *consensus.Snapshot
+
+	// Candidate state
+	votes map[string]bool
+
+	// Leader state
+	nextIndex  map[string]int64
+	matchIndex map[string]int64
+	sending    map[string]int64 // bytes of the snapshot each lagging peer has received
+
+	electionTimer  clock.Timer
+	electionGen    uint64
+	heartbeatTimer clock.Timer
+	heartbeatGen   uint64
+
+	// Durable state. The term, vote and log are written before any
+	// message that depends on them is sent.
+	storage      consensus.Storage
+	syncInterval time.Duration // 0 syncs every write
+	syncTimer    clock.Timer
+	syncedAt     time.Time // when the last sync completes on slow storage
+	saved        consensus.HardState
+	unsaved      int64 // lowest log index changed since the last write, 0 if none
+	newSnapshot  bool  // the snapshot changed since the last write
+
+	outbox  []consensus.Message
+	started bool
+	cancel  context.CancelFunc
+}
+
+// Settings read from config.Config.Settings
+const (
+	// How long writes to the log may wait to be synced to disk, as a
+	// duration string. Unsynced writes are lost if the node crashes.
+	SettingSyncInterval = "sync_interval"
+
+	// Number of applied entries after which the log is compacted into a
+	// snapshot of the state machine. The default, 0, never compacts.
+	SettingSnapshotThreshold = "snapshot_threshold"
+
+	// Most bytes of a snapshot sent to a lagging follower in one message
+	SettingSnapshotChunkSize = "snapshot_chunk_size"
+)
+
+// Snapshot chunk size used when SettingSnapshotChunkSize is not set
+const DefaultSnapshotChunkSize = 64 * 1024
+
+// Creates a Raft node. The environment may be left empty and supplied
+// later through Attach. Without storage in the environment, the node
+// keeps its term, vote and log in a write-ahead log in cfg.DataDir, or in
+// memory when that is empty, and recovers them when started. The node and
+// cfg.Peers make up the voters of a new cluster; with cfg.Join set the node
+// starts outside the cluster instead.
+func NewNode(id string, cfg config.Config, env consensus.Environment) (*Node, error) {
+	if id == "" {
+		return nil, fmt.Errorf("node id is required")
+	}
+	if cfg.ElectionTimeout <= 0 {
+		return nil, fmt.Errorf("election timeout must be positive, got %v", cfg.ElectionTimeout)
+	}
+	if cfg.HeartbeatInterval <= 0 {
+		return nil, fmt.Errorf("heartbeat interval must be positive, got %v", cfg.HeartbeatInterval)
+	}
+	if cfg.HeartbeatInterval >= cfg.ElectionTimeout {
+		return nil, fmt.Errorf("heartbeat interval %v must be shorter than election timeout %v",
+			cfg.HeartbeatInterval, cfg.ElectionTimeout)
+	}
+
+	seen := map[string]bool{id: true}
+	voters := []string{id}
+	for _, peer := range cfg.Peers {
+		if !seen[peer] {
+			seen[peer] = true
+			voters = append(voters, peer)
+		}
+	}
+	sort.Strings(voters)
+
+	syncInterval, err := durationSetting(cfg, SettingSyncInterval)
+	if err != nil {
+		return nil, err
+	}
+	threshold, err := intSetting(cfg, SettingSnapshotThreshold)
+	if err != nil {
+		return nil, err
+	}
+	chunkSize, err := intSetting(cfg, SettingSnapshotChunkSize)
+	if err != nil {
+		return nil, err
+	}
+	if chunkSize == 0 {
+		chunkSize = DefaultSnapshotChunkSize
+	}
+	initial := consensus.Membership{Voters: voters}
+	if cfg.Join {
+		initial = consensus.Membership{}
+	}
+
+	n := &Node{
+		id:     id,
+		config: cfg,
+		state:  consensus.StateFollower,
+		log:    []consensus.Entry{{Index: 0, Term: 0}},
+		logger: logging.NewNoOpLogger(),
+		clock:  clock.Real(),
+		rng:    rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())),
+
+		initial:           initial,
+		snapshotThreshold: threshold,
+		chunkSize:         chunkSize,
+		syncInterval:      syncInterval,
+	}
+	n.setMembers(initial, 0)
+	if err := n.Attach(env); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// Attach Implements consensus.Attachable
+func (n *Node) Attach(env consensus.Environment) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.started {
+		return fmt.Errorf("node %s is already started", n.id)
+	}
+	if env.Transport != nil {
+		n.transport = env.Transport
+	}
+	if env.StateMachine != nil {
+		n.sm = env.StateMachine
+	}
+	if env.Logger != nil {
+		n.logger = env.Logger.With(logging.String("node_id", n.id))
+	}
+	if env.Clock != nil {
+		n.clock = env.Clock
+	}
+	if env.Rand != nil {
+		n.rng = env.Rand
+	}
+	if env.Storage != nil {
+		n.storage = env.Storage
+	}
+	return nil
+}
+
+// Start Implements consensus.Node
+func (n *Node) Start(ctx context.Context) error {
+	n.mu.Lock()
+	if n.started {
+		n.mu.Unlock()
+		return fmt.Errorf("node %s is already started", n.id)
+	}
+	if n.state == consensus.StateStopped {
+		n.mu.Unlock()
+		return consensus.ErrStopped
+	}
+	if n.transport == nil {
+		n.mu.Unlock()
+		return fmt.Errorf("node %s has no transport attached", n.id)
+	}
+	if err := n.recover(); err != nil {
+		n.mu.Unlock()
+		return fmt.Errorf("node %s: recover state: %w", n.id, err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	n.cancel = cancel
+	n.started = true
+	n.resetElectionTimer()
+	transport := n.transport
+	n.mu.Unlock()
+
+	if ct, ok := transport.(consensus.CallbackTransport); ok {
+		ct.OnReceive(func(msg consensus.Message) {
+			n.step(func() { n.handleMessage(msg) })
+		})
+		context.AfterFunc(ctx, func() { n.Stop() })
+		return nil
+	}
+
+	go n.run(ctx)
+	return nil
+}
+
+// Stop Implements consensus.Node. Pending writes are synced first.
+func (n *Node) Stop() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.halt(false)
+}
+
+// Crash Implements consensus.Crashable. Writes not yet synced are lost.
+func (n *Node) Crash() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.halt(true)
+}
+
+func (n *Node) halt(crash bool) error {
+	if n.state == consensus.StateStopped {
+		return nil
+	}
+	n.state = consensus.StateStopped
+	n.stopTimers()
+	n.outbox = nil
+	if n.cancel != nil {
+		n.cancel()
+	}
+
+	if n.storage == nil {
+		return nil
+	}
+	store := n.storage
+	n.storage = nil
+	if n.syncTimer != nil {
+		n.syncTimer.Stop()
+		n.syncTimer = nil
+	}
+	if crashable, ok := store.(consensus.Crashable); ok && crash {
+		return crashable.Crash()
+	}
+	return store.Close()
+}
+
+// ID Implements consensus.Node
+func (n *Node) ID() string {
+	return n.id
+}
+
+// IsLeader Implements consensus.Node
+func (n *Node) IsLeader() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.state == consensus.StateLeader
+}
+
+// GetState Implements consensus.Node
+func (n *Node) GetState() consensus.NodeState {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.state
+}
+
+// Propose Implements consensus.Node. The command is appended to the
+// leader's log and applied once a majority has replicated it.
+func (n *Node) Propose(data []byte) error {
+	var err error
+	n.step(func() {
+		switch n.state {
+		case consensus.StateStopped:
+			err = consensus.ErrStopped
+			return
+		case consensus.StateLeader:
+		default:
+			err = consensus.ErrNotLeader
+			return
+		}
+
+		n.appendEntry(consensus.EntryCommand, data)
+		n.broadcastAppendEntries()
+		n.advanceCommitIndex()
+	})
+	return err
+}
+
+// ChangeMembership Implements consensus.Reconfigurable. Changes go one
+// at a time: the previous one, and an entry of the leader's own term, must
+// be committed before the next is accepted.
+func (n *Node) ChangeMembership(change consensus.MembershipChange) error {
+	var err error
+	n.step(func() {
+		switch n.state {
+		case consensus.StateStopped:
+			err = consensus.ErrStopped
+			return
+		case consensus.StateLeader:
+		default:
+			err = consensus.ErrNotLeader
+			return
+		}
+		if n.membersIndex > n.commitIndex || n.termAt(n.commitIndex) != n.currentTerm {
+			err = consensus.ErrChangeInProgress
+			return
+		}
+		members, applyErr := n.members.Apply(change)
+		if applyErr != nil {
+			err = applyErr
+			return
+		}
+
+		n.appendEntry(consensus.EntryConfig, encode(members))
+		n.setMembers(members, n.lastLogIndex())
+		n.logger.Info("changing membership", logging.String("change", change.String()))
+		n.broadcastAppendEntries()
+		n.advanceCommitIndex()
+	})
+	return err
+}
+
+// Membership Implements consensus.Reconfigurable
+func (n *Node) Membership() (consensus.Membership, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.copyMembers(), n.membersIndex <= n.commitIndex
+}
+
+// Term returns the node's current term
+func (n *Node) Term() int64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.currentTerm
+}
+
+// Leader returns the ID of the leader this node currently follows, if known
+func (n *Node) Leader() string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.leaderID
+}
+
+// CommitIndex returns the highest log index known to be committed
+func (n *Node) CommitIndex() int64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.commitIndex
+}
+
+// Observe Implements consensus.Observable. Entries are never modified in
+// place, so the log is shared rather than copied.
+func (n *Node) Observe() consensus.Observation {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return consensus.Observation{
+		ID:            n.id,
+		State:         n.state,
+		Term:          n.currentTerm,
+		SnapshotIndex: n.log[0].Index,
+		SnapshotTerm:  n.log[0].Term,
+		Log:           n.log[1:len(n.log):len(n.log)],
+		CommitIndex:   n.commitIndex,
+		LastApplied:   n.lastApplied,
+	}
+}
+
+// Status Implements consensus.Inspectable
+func (n *Node) Status(entries int) consensus.NodeStatus {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	status := consensus.NodeStatus{
+		ID:            n.id,
+		State:         n.state,
+		Term:          n.currentTerm,
+		VotedFor:      n.votedFor,
+		Leader:        n.leaderID,
+		CommitIndex:   n.commitIndex,
+		LastApplied:   n.lastApplied,
+		LogLength:     n.lastLogIndex(),
+		SnapshotIndex: n.log[0].Index,
+	}
+	members := n.copyMembers()
+	status.Members = &members
+	if first := len(n.log) - entries; entries > 0 {
+		status.Entries = append([]consensus.Entry(nil), n.log[max(first, 1):]...)
+	}
+	if n.state == consensus.StateLeader {
+		status.Peers = make(map[string]consensus.PeerProgress, len(n.peers))
+		for _, peer := range n.peers {
+			status.Peers[peer] = consensus.PeerProgress{
+				MatchIndex: n.matchIndex[peer],
+				NextIndex:  n.nextIndex[peer],
+			}
+		}
+	}
+	return status
+}
+
+// Receives messages until the context is cancelled or the transport
+// closes. Used for transports that only offer a Receive channel.
+func (n *Node) run(ctx context.Context) {
+	inbox := n.transport.Receive()
+	for {
+		select {
+		case <-ctx.Done():
+			n.Stop()
+			return
+		case msg, ok := <-inbox:
+			if !ok {
+				n.Stop()
+				return
+			}
+			n.step(func() { n.handleMessage(msg) })
+		}
+	}
+}
+
+// Runs fn under the node lock, persists what it changed, then flushes
+// any messages it queued. On slow storage the messages leave once the
+// last sync would have completed, even those of a step that wrote nothing,
+// since they may report state that sync covers. They leave in order.
+func (n *Node) step(fn func()) {
+	n.mu.Lock()
+	fn()
+	now := n.clock.Now()
+	if delay := n.persist(); delay > 0 && now.Add(delay).After(n.syncedAt) {
+		n.syncedAt = now.Add(delay)
+	}
+	hold := n.syncedAt.Sub(now)
+	outbox := n.outbox
+	n.outbox = nil
+	transport := n.transport
+	n.mu.Unlock()
+
+	if hold > 0 && len(outbox) > 0 {
+		n.clock.AfterFunc(hold, func() {
+			if n.GetState() != consensus.StateStopped {
+				n.flush(transport, outbox)
+			}
+		})
+		return
+	}
+	n.flush(transport, outbox)
+}
+
+func (n *Node) flush(transport consensus.Transport, outbox []consensus.Message) {
+	for _, msg := range outbox {
+		if err := transport.Send(msg.To, msg); err != nil {
+			n.logger.Debug("send failed", logging.String("to", msg.To), logging.Error(err))
+		}
+	}
+}
+
+func (n *Node) handleMessage(msg consensus.Message) {
+	if n.state == consensus.StateStopped {
+		return
+	}
+
+	var err error
+	switch msg.Type {
+	case consensus.MessageRequestVote:
+		var req requestVoteRequest
+		if err = decode(msg.Data, &req); err == nil {
+			n.handleRequestVote(msg, req)
+		}
+	case consensus.MessageRequestVoteResponse:
+		var resp requestVoteResponse
+		if err = decode(msg.Data, &resp); err == nil {
+			n.handleRequestVoteResponse(msg, resp)
+		}
+	case consensus.MessageAppendEntries:
+		var req appendEntriesRequest
+		if err = decode(msg.Data, &req); err == nil {
+			n.handleAppendEntries(msg, req)
+		}
+	case consensus.MessageAppendEntriesResponse:
+		var resp appendEntriesResponse
+		if err = decode(msg.Data, &resp); err == nil {
+			n.handleAppendEntriesResponse(msg, resp)
+		}
+	case consensus.MessageInstallSnapshot:
+		var req installSnapshotRequest
+		if err = decode(msg.Data, &req); err == nil {
+			n.handleInstallSnapshot(msg, req)
+		}
+	case consensus.MessageInstallSnapshotResponse:
+		var resp installSnapshotResponse
+		if err = decode(msg.Data, &resp); err == nil {
+			n.handleInstallSnapshotResponse(msg, resp)
+		}
+	default:
+		n.logger.Debug("ignoring message", logging.Int("type", int(msg.Type)), logging.String("from", msg.From))
+	}
+
+	if err != nil {
+		n.logger.Warn("dropping malformed message", logging.String("from", msg.From), logging.Error(err))
+	}
+}
+
+// Election
+
+func (n *Node) startElection() {
+	n.state = consensus.StateCandidate
+	n.currentTerm++
+	n.votedFor = n.id
+	n.leaderID = ""
+	n.votes = map[string]bool{n.id: true}
+	n.resetElectionTimer()
+
+	n.logger.Debug("starting election", logging.Int64("term", n.currentTerm))
+
+	req := requestVoteRequest{
+		CandidateID:  n.id,
+		LastLogIndex: n.lastLogIndex(),
+		LastLogTerm:  n.lastLogTerm(),
+	}
+	for _, peer := range n.members.Voters {
+		if peer != n.id {
+			n.send(peer, consensus.MessageRequestVote, req)
+		}
+	}
+
+	if n.hasQuorum(len(n.votes)) {
+		n.becomeLeader()
+	}
+}
+
+func (n *Node) handleRequestVote(msg consensus.Message, req requestVoteRequest) {
+	if msg.Term > n.currentTerm && n.hasLeader() {
+		// A node removed from the cluster no longer hears from the leader
+		// and would otherwise disrupt it with ever higher terms
+		return
+	}
+	if msg.Term > n.currentTerm {
+		n.becomeFollower(msg.Term, "")
+	}
+
+	granted := false
+	if msg.Term == n.currentTerm &&
+		(n.votedFor == "" || n.votedFor == req.CandidateID) &&
+		n.isUpToDate(req.LastLogIndex, req.LastLogTerm) {
+		granted = true
+		n.votedFor = req.CandidateID
+		n.resetElectionTimer()
+	}
+
+	n.send(msg.From, consensus.MessageRequestVoteResponse, requestVoteResponse{VoteGranted: granted})
+}
+
+func (n *Node) handleRequestVoteResponse(msg consensus.Message, resp requestVoteResponse) {
+	if msg.Term > n.currentTerm {
+		n.becomeFollower(msg.Term, "")
+		return
+	}
+	if n.state != consensus.StateCandidate || msg.Term != n.currentTerm || !resp.VoteGranted {
+		return
+	}
+	if !n.members.IsVoter(msg.From) {
+		return
+	}
+
+	n.votes[msg.From] = true
+	if n.hasQuorum(len(n.votes)) {
+		n.becomeLeader()
+	}
+}
+
+// Reports whether a candidate's log is at least as up-to-date as ours
+func (n *Node) isUpToDate(lastIndex, lastTerm int64) bool {
+	ourTerm := n.lastLogTerm()
+	if lastTerm != ourTerm {
+		return lastTerm > ourTerm
+	}
+	return lastIndex >= n.lastLogIndex()
+}
+
+func (n *Node) becomeFollower(term int64, leaderID string) {
+	wasLeader := n.state == consensus.StateLeader
+	if term > n.currentTerm {
+		n.currentTerm = term
+		n.votedFor = ""
+	}
+	n.state = consensus.StateFollower
+	n.leaderID = leaderID
+	n.votes = nil
+	n.nextIndex = nil
+	n.matchIndex = nil
+	n.sending = nil
+	n.stopHeartbeatTimer()
+	n.resetElectionTimer()
+
+	if wasLeader {
+		n.logger.Info("stepping down", logging.Int64("term", n.currentTerm))
+	}
+}
+
+func (n *Node) becomeLeader() {
+	n.state = consensus.StateLeader
+	n.leaderID = n.id
+	n.votes = nil
+	n.stopElectionTimer()
+
+	n.nextIndex = make(map[string]int64, len(n.peers))
+	n.matchIndex = make(map[string]int64, len(n.peers))
+	n.sending = make(map[string]int64)
+	for _, peer := range n.peers {
+		n.nextIndex[peer] = n.lastLogIndex() + 1
+		n.matchIndex[peer] = 0
+	}
+
+	n.logger.Info("became leader", logging.Int64("term", n.currentTerm))
+
+	// A no-op entry from the new term lets entries from earlier terms
+	// commit. The first leader of a cluster writes its membership instead,
+	// so nodes that join later learn it from the log.
+	if n.membersIndex == 0 && n.snapshot.Config == nil {
+		n.appendEntry(consensus.EntryConfig, encode(n.members))
+		n.membersIndex = n.lastLogIndex()
+	} else {
+		n.appendEntry(consensus.EntryCommand, nil)
+	}
+	n.broadcastAppendEntries()
+	n.advanceCommitIndex()
+	n.resetHeartbeatTimer()
+}
+
+// Replication
+
+func (n *Node) appendEntry(entryType consensus.EntryType, command []byte) {
+	n.markUnsaved(n.lastLogIndex() + 1)
+	n.log = append(n.log, consensus.Entry{
+		Index:   n.lastLogIndex() + 1,
+		Term:    n.currentTerm,
+		Command: command,
+		Type:    entryType,
+	})
+}
+
+func (n *Node) broadcastAppendEntries() {
+	for _, peer := range n.peers {
+		n.sendAppendEntries(peer)
+	}
+}
+
+func (n *Node) sendAppendEntries(peer string) {
+	next := n.nextIndex[peer]
+	if next < 1 {
+		next = 1
+	}
+	if next <= n.log[0].Index {
+		// The entries the peer needs next were compacted away
+		n.sendSnapshot(peer)
+		return
+	}
+	prev := next - 1
+
+	entries := make([]consensus.Entry, len(n.log[n.offset(next):]))
+	copy(entries, n.log[n.offset(next):])
+
+	n.send(peer, consensus.MessageAppendEntries, appendEntriesRequest{
+		LeaderID:     n.id,
+		PrevLogIndex: prev,
+		PrevLogTerm:  n.termAt(prev),
+		Entries:      entries,
+		LeaderCommit: n.commitIndex,
+	})
+}
+
+func (n *Node) handleAppendEntries(msg consensus.Message, req appendEntriesRequest) {
+	if msg.Term < n.currentTerm {
+		n.send(msg.From, consensus.MessageAppendEntriesResponse, appendEntriesResponse{Success: false})
+		return
+	}
+	if msg.Term > n.currentTerm || n.state != consensus.StateFollower {
+		n.becomeFollower(msg.Term, req.LeaderID)
+	}
+	n.leaderID = req.LeaderID
+	n.lastContact = n.clock.Now()
+	n.resetElectionTimer()
+
+	if base := n.log[0].Index; req.PrevLogIndex < base {
+		// Entries up to the snapshot are committed, so they match the
+		// leader's and only those after it are considered
+		skip := min(base-req.PrevLogIndex, int64(len(req.Entries)))
+		if skip > 0 {
+			req.PrevLogTerm = req.Entries[skip-1].Term
+			req.Entries = req.Entries[skip:]
+		}
+		req.PrevLogIndex += skip
+		if req.PrevLogIndex < base {
+			n.send(msg.From, consensus.MessageAppendEntriesResponse, appendEntriesResponse{
+				Success:    true,
+				MatchIndex: base,
+			})
+			return
+		}
+	}
+	if req.PrevLogIndex > n.lastLogIndex() {
+		n.send(msg.From, consensus.MessageAppendEntriesResponse, appendEntriesResponse{
+			ConflictIndex: n.lastLogIndex() + 1,
+		})
+		return
+	}
+	if term := n.termAt(req.PrevLogIndex); term != req.PrevLogTerm {
+		// Skip back over the whole conflicting term in one round trip
+		conflict := req.PrevLogIndex
+		for conflict > n.commitIndex+1 && n.termAt(conflict-1) == term {
+			conflict--
+		}
+		n.send(msg.From, consensus.MessageAppendEntriesResponse, appendEntriesResponse{
+			ConflictIndex: conflict,
+		})
+		return
+	}
+
+	reload := false
+	for i, entry := range req.Entries {
+		if entry.Index <= n.lastLogIndex() {
+			if n.termAt(entry.Index) == entry.Term {
+				continue
+			}
+			// Cap the truncated log so the append copies it, leaving
+			// slices handed out by Observe untouched
+			keep := n.offset(entry.Index)
+			n.log = n.log[:keep:keep]
+			reload = n.membersIndex >= entry.Index
+		}
+		n.markUnsaved(entry.Index)
+		n.log = append(n.log, req.Entries[i:]...)
+		for _, added := range req.Entries[i:] {
+			reload = reload || added.Type == consensus.EntryConfig
+		}
+		break
+	}
+	if reload {
+		n.reloadMembers()
+	}
+
+	lastNew := req.PrevLogIndex + int64(len(req.Entries))
+	if req.LeaderCommit > n.commitIndex {
+		n.commitIndex = min(req.LeaderCommit, lastNew)
+		n.applyCommitted()
+	}
+
+	n.send(msg.From, consensus.MessageAppendEntriesResponse, appendEntriesResponse{
+		Success:    true,
+		MatchIndex: lastNew,
+	})
+}
+
+func (n *Node) handleAppendEntriesResponse(msg consensus.Message, resp appendEntriesResponse) {
+	if msg.Term > n.currentTerm {
+		n.becomeFollower(msg.Term, "")
+		return
+	}
+	if n.state != consensus.StateLeader || msg.Term != n.currentTerm {
+		return
+	}
+	if _, ok := n.nextIndex[msg.From]; !ok {
+		return
+	}
+
+	if resp.Success {
+		if resp.MatchIndex > n.matchIndex[msg.From] {
+			n.matchIndex[msg.From] = resp.MatchIndex
+		}
+		n.nextIndex[msg.From] = n.matchIndex[msg.From] + 1
+		n.advanceCommitIndex()
+		if n.nextIndex[msg.From] <= n.lastLogIndex() {
+			n.sendAppendEntries(msg.From)
+		}
+		return
+	}
+
+	next := n.nextIndex[msg.From] - 1
+	if resp.ConflictIndex > 0 && resp.ConflictIndex < next {
+		next = resp.ConflictIndex
+	}
+	n.nextIndex[msg.From] = max(next, 1)
+	n.sendAppendEntries(msg.From)
+}
+
+// Commits the highest current-term index replicated on a majority
+func (n *Node) advanceCommitIndex() {
+	for index := n.lastLogIndex(); index > n.commitIndex; index-- {
+		if n.termAt(index) != n.currentTerm {
+			break
+		}
+		replicas := 0
+		for _, voter := range n.members.Voters {
+			if voter == n.id || n.matchIndex[voter] >= index {
+				replicas++
+			}
+		}
+		if n.hasQuorum(replicas) {
+			n.commitIndex = index
+			n.applyCommitted()
+			break
+		}
+	}
+
+	// A leader that removed itself keeps replicating until the change
+	// commits, then leaves the cluster to the remaining voters
+	if n.membersIndex <= n.commitIndex && !n.members.IsVoter(n.id) {
+		n.logger.Info("removed from the cluster")
+		n.becomeFollower(n.currentTerm, "")
+	}
+}
+
+func (n *Node) applyCommitted() {
+	for n.lastApplied < n.commitIndex {
+		n.lastApplied++
+		entry := n.log[n.offset(n.lastApplied)]
+		if entry.Type != consensus.EntryCommand || len(entry.Command) == 0 || n.sm == nil {
+			continue
+		}
+		if _, err := n.sm.Apply(entry.Command); err != nil {
+			n.logger.Warn("state machine rejected entry",
+				logging.Int64("index", entry.Index), logging.Error(err))
+		}
+	}
+	n.compact()
+}
+
+// Snapshots
+
+// Replaces the applied entries with a snapshot of the state machine once
+// enough of them have piled up in the log
+func (n *Node) compact() {
+	if n.snapshotThreshold == 0 || n.sm == nil || n.lastApplied-n.log[0].Index < n.snapshotThreshold {
+		return
+	}
+	data, err := n.sm.Snapshot()
+	if err != nil {
+		n.logger.Warn("snapshotting state machine failed", logging.Error(err))
+		return
+	}
+
+	snapshot := consensus.Snapshot{
+		Index:  n.lastApplied,
+		Term:   n.termAt(n.lastApplied),
+		Data:   data,
+		Config: n.membersAt(n.lastApplied),
+	}
+	n.setSnapshot(snapshot, n.log[n.offset(snapshot.Index)+1:])
+	// Transfers in progress restart with the new snapshot
+	clear(n.sending)
+	n.logger.Debug("compacted log", logging.Int64("index", snapshot.Index))
+}
+
+// Makes snapshot the node's latest, followed in the log by entries
+func (n *Node) setSnapshot(snapshot consensus.Snapshot, entries []consensus.Entry) {
+	log := make([]consensus.Entry, 1, len(entries)+1)
+	log[0] = consensus.Entry{Index: snapshot.Index, Term: snapshot.Term}
+	n.log = append(log, entries...)
+	n.snapshot = snapshot
+	n.newSnapshot = true
+}
+
+// Sends a lagging peer the chunk of the snapshot it needs next. Chunks
+// go one at a time: the next leaves when the peer acknowledges this one,
+// and heartbeats resend it if either message is lost.
+func (n *Node) sendSnapshot(peer string) {
+	data := n.snapshot.Data
+	offset := n.sending[peer]
+	if offset > int64(len(data)) {
+		offset = 0
+	}
+	end := min(offset+n.chunkSize, int64(len(data)))
+
+	n.send(peer, consensus.MessageInstallSnapshot, installSnapshotRequest{
+		LeaderID:          n.id,
+		LastIncludedIndex: n.snapshot.Index,
+		LastIncludedTerm:  n.snapshot.Term,
+		Config:            n.snapshot.Config,
+		Offset:            offset,
+		Data:              data[offset:end],
+		Done:              end == int64(len(data)),
+	})
+}
+
+func (n *Node) handleInstallSnapshot(msg consensus.Message, req installSnapshotRequest) {
+	if msg.Term < n.currentTerm {
+		n.send(msg.From, consensus.MessageInstallSnapshotResponse, installSnapshotResponse{})
+		return
+	}
+	if msg.Term > n.currentTerm || n.state != consensus.StateFollower {
+		n.becomeFollower(msg.Term, req.LeaderID)
+	}
+	n.leaderID = req.LeaderID
+	n.lastContact = n.clock.Now()
+	n.resetElectionTimer()
+
+	reply := func(offset int64, done bool) {
+		n.send(msg.From, consensus.MessageInstallSnapshotResponse, installSnapshotResponse{
+			LastIncludedIndex: req.LastIncludedIndex,
+			Offset:            offset,
+			Done:              done,
+		})
+	}
+
+	if req.LastIncludedIndex <= n.commitIndex {
+		// Everything the snapshot covers is committed here already
+		n.incoming = nil
+		reply(req.Offset+int64(len(req.Data)), true)
+		return
+	}
+
+	incoming := n.incoming
+	if req.Offset == 0 {
+		incoming = &consensus.Snapshot{Index: req.LastIncludedIndex, Term: req.LastIncludedTerm, Config: req.Config}
+	}
+	if incoming == nil || incoming.Index != req.LastIncludedIndex || incoming.Term != req.LastIncludedTerm {
+		// A chunk of a snapshot whose start was missed
+		n.incoming = nil
+		reply(0, false)
+		return
+	}
+	if req.Offset != int64(len(incoming.Data)) {
+		// A duplicate, or a chunk after one that was lost
+		reply(int64(len(incoming.Data)), false)
+		return
+	}
+	incoming.Data = append(incoming.Data, req.Data...)
+	n.incoming = incoming
+	if !req.Done {
+		reply(int64(len(incoming.Data)), false)
+		return
+	}
+
+	n.incoming = nil
+	if err := n.installSnapshot(*incoming); err != nil {
+		n.logger.Warn("installing snapshot failed", logging.Int64("index", incoming.Index), logging.Error(err))
+		reply(0, false)
+		return
+	}
+	reply(int64(len(incoming.Data)), true)
+}
+
+// Replaces the state machine with a snapshot from the leader. Entries
+// after it are kept if the log agrees with the snapshot's last entry,
+// otherwise the whole log is discarded.
+func (n *Node) installSnapshot(snapshot consensus.Snapshot) error {
+	if n.sm != nil {
+		if err := n.sm.Restore(snapshot.Data); err != nil {
+			return err
+		}
+	}
+
+	var entries []consensus.Entry
+	if n.termAt(snapshot.Index) == snapshot.Term {
+		entries = n.log[n.offset(snapshot.Index)+1:]
+	} else {
+		n.markUnsaved(snapshot.Index + 1)
+	}
+	n.setSnapshot(snapshot, entries)
+	n.reloadMembers()
+	n.commitIndex = snapshot.Index
+	n.lastApplied = snapshot.Index
+
+	n.logger.Info("installed snapshot", logging.Int64("index", snapshot.Index), logging.Int64("term", snapshot.Term))
+	return nil
+}
+
+func (n *Node) handleInstallSnapshotResponse(msg consensus.Message, resp installSnapshotResponse) {
+	if msg.Term > n.currentTerm {
+		n.becomeFollower(msg.Term, "")
+		return
+	}
+	if n.state != consensus.StateLeader || msg.Term != n.currentTerm {
+		return
+	}
+	if _, ok := n.nextIndex[msg.From]; !ok {
+		return
+	}
+
+	switch {
+	case resp.Done:
+		delete(n.sending, msg.From)
+		if resp.LastIncludedIndex > n.matchIndex[msg.From] {
+			n.matchIndex[msg.From] = resp.LastIncludedIndex
+		}
+		n.nextIndex[msg.From] = n.matchIndex[msg.From] + 1
+		n.advanceCommitIndex()
+		if n.nextIndex[msg.From] <= n.lastLogIndex() {
+			n.sendAppendEntries(msg.From)
+		}
+	case resp.LastIncludedIndex != n.snapshot.Index:
+		// About a snapshot since replaced
+	case resp.Offset > n.sending[msg.From]:
+		n.sending[msg.From] = resp.Offset
+		n.sendSnapshot(msg.From)
+	default:
+		// A duplicate answer, or the peer lost what it had; the next
+		// heartbeat sends the chunk it asks for, so answers to duplicate
+		// chunks cannot multiply
+		n.sending[msg.From] = resp.Offset
+	}
+}
+
+// Membership
+
+// Puts members into effect, taken from the entry at index
+func (n *Node) setMembers(members consensus.Membership, index int64) {
+	n.members, n.membersIndex = members, index
+
+	peers := make([]string, 0, len(members.Voters)+len(members.Learners))
+	for _, ids := range [][]string{members.Voters, members.Learners} {
+		for _, id := range ids {
+			if id != n.id {
+				peers = append(peers, id)
+			}
+		}
+	}
+	sort.Strings(peers)
+	n.peers = peers
+
+	if n.state != consensus.StateLeader {
+		return
+	}
+	for _, peer := range peers {
+		if _, ok := n.nextIndex[peer]; !ok {
+			n.nextIndex[peer] = n.lastLogIndex() + 1
+			n.matchIndex[peer] = 0
+		}
+	}
+	for peer := range n.nextIndex {
+		if !members.Contains(peer) {
+			delete(n.nextIndex, peer)
+			delete(n.matchIndex, peer)
+			delete(n.sending, peer)
+		}
+	}
+}
+
+// Puts the latest membership in the log into effect, after the log changed
+// in a way that may have added or removed one
+func (n *Node) reloadMembers() {
+	for i := len(n.log) - 1; i > 0; i-- {
+		entry := n.log[i]
+		if entry.Type != consensus.EntryConfig {
+			continue
+		}
+		var members consensus.Membership
+		if err := decode(entry.Command, &members); err != nil {
+			n.logger.Warn("ignoring malformed membership", logging.Int64("index", entry.Index), logging.Error(err))
+			continue
+		}
+		n.setMembers(members, entry.Index)
+		return
+	}
+
+	members, index := n.initial, int64(0)
+	if n.snapshot.Config != nil {
+		if err := decode(n.snapshot.Config, &members); err != nil {
+			n.logger.Warn("ignoring malformed membership in snapshot", logging.Error(err))
+			members = n.initial
+		} else {
+			index = n.snapshot.Index
+		}
+	}
+	n.setMembers(members, index)
+}
+
+// Returns the membership in effect at index, encoded as in its entry, or
+// nil while the initial one is
+func (n *Node) membersAt(index int64) []byte {
+	for i := n.offset(index); i > 0; i-- {
+		if n.log[i].Type == consensus.EntryConfig {
+			return n.log[i].Command
+		}
+	}
+	return n.snapshot.Config
+}
+
+// Returns a copy of members that later changes leave untouched
+func (n *Node) copyMembers() consensus.Membership {
+	return consensus.Membership{
+		Voters:   append([]string(nil), n.members.Voters...),
+		Learners: append([]string(nil), n.members.Learners...),
+	}
+}
+
+// Persistence
+
+// Records that the log changed from index on
+func (n *Node) markUnsaved(index int64) {
+	if n.unsaved == 0 || index < n.unsaved {
+		n.unsaved = index
+	}
+}
+
+// Opens the node's storage if none was supplied and loads the term, vote,
+// snapshot and log from it
+func (n *Node) recover() error {
+	if n.storage == nil {
+		if n.config.DataDir == "" {
+			n.storage = storage.NewMemory()
+		} else {
+			wal, err := storage.OpenWAL(n.config.DataDir)
+			if err != nil {
+				return err
+			}
+			n.storage = wal
+		}
+	}
+
+	hard, err := n.storage.GetHardState()
+	if err != nil {
+		return err
+	}
+	snapshot, err := n.storage.GetSnapshot()
+	if err != nil {
+		return err
+	}
+	first, last := n.storage.FirstIndex(), n.storage.LastIndex()
+	if first != snapshot.Index+1 {
+		return fmt.Errorf("log starts at index %d, expected %d", first, snapshot.Index+1)
+	}
+	entries, err := n.storage.Entries(first, last+1)
+	if err != nil {
+		return err
+	}
+	if snapshot.Index > 0 && n.sm != nil {
+		if err := n.sm.Restore(snapshot.Data); err != nil {
+			return fmt.Errorf("restore snapshot: %w", err)
+		}
+	}
+
+	n.currentTerm, n.votedFor, n.saved = hard.Term, hard.VotedFor, hard
+	n.setSnapshot(snapshot, entries)
+	n.newSnapshot = false
+	n.reloadMembers()
+	// What a snapshot covers was committed and applied
+	n.commitIndex, n.lastApplied = snapshot.Index, snapshot.Index
+	return nil
+}
+
+// Writes the term, vote, snapshot and log entries changed since the last
+// write and returns how long the sync took. A node that cannot persist its
+// state cannot safely go on, so it stops.
+func (n *Node) persist() time.Duration {
+	if n.storage == nil || n.state == consensus.StateStopped {
+		return 0
+	}
+	hard := consensus.HardState{Term: n.currentTerm, VotedFor: n.votedFor}
+	if hard == n.saved && n.unsaved == 0 && !n.newSnapshot {
+		return 0
+	}
+
+	var err error
+	if hard != n.saved {
+		err = n.storage.SetHardState(hard)
+	}
+	if err == nil && n.newSnapshot {
+		err = n.storage.SetSnapshot(n.snapshot)
+	}
+	if err == nil && n.unsaved > 0 {
+		// Entries the snapshot covers need not be written
+		from := max(n.unsaved, n.log[0].Index+1)
+		if err = n.storage.Truncate(from); err == nil {
+			err = n.storage.Append(n.log[n.offset(from):])
+		}
+	}
+	if err == nil && n.syncInterval == 0 {
+		err = n.storage.Sync()
+	}
+	if err != nil {
+		n.logger.Error("persisting state failed, stopping", logging.Error(err))
+		n.halt(true)
+		return 0
+	}
+	n.saved, n.unsaved, n.newSnapshot = hard, 0, false
+
+	if n.syncInterval > 0 && n.syncTimer == nil {
+		n.syncTimer = n.clock.AfterFunc(n.syncInterval, func() {
+			n.mu.Lock()
+			defer n.mu.Unlock()
+
+			n.syncTimer = nil
+			if n.storage == nil {
+				return
+			}
+			if err := n.storage.Sync(); err != nil {
+				n.logger.Error("syncing log failed, stopping", logging.Error(err))
+				n.halt(true)
+			}
+		})
+	}
+
+	if slow, ok := n.storage.(consensus.SlowStorage); ok && n.syncInterval == 0 {
+		return slow.SyncDelay()
+	}
+	return 0
+}
+
+// Timers
+
+func (n *Node) randomElectionTimeout() time.Duration {
+	timeout := n.config.ElectionTimeout
+	return timeout + time.Duration(n.rng.Int64N(int64(timeout)))
+}
+
+func (n *Node) resetElectionTimer() {
+	n.stopElectionTimer()
+	gen := n.electionGen
+	n.electionTimer = n.clock.AfterFunc(n.randomElectionTimeout(), func() {
+		n.step(func() {
+			if gen != n.electionGen || n.state == consensus.StateStopped || n.state == consensus.StateLeader {
+				return
+			}
+			if !n.members.IsVoter(n.id) {
+				// Learners and removed nodes never stand for election,
+				// but may become voters later
+				n.resetElectionTimer()
+				return
+			}
+			n.startElection()
+		})
+	})
+}
+
+func (n *Node) stopElectionTimer() {
+	n.electionGen++
+	if n.electionTimer != nil {
+		n.electionTimer.Stop()
+		n.electionTimer = nil
+	}
+}
+
+func (n *Node) resetHeartbeatTimer() {
+	n.stopHeartbeatTimer()
+	gen := n.heartbeatGen
+	n.heartbeatTimer = n.clock.AfterFunc(n.config.HeartbeatInterval, func() {
+		n.step(func() {
+			if gen != n.heartbeatGen || n.state != consensus.StateLeader {
+				return
+			}
+			n.broadcastAppendEntries()
+			n.resetHeartbeatTimer()
+		})
+	})
+}
+
+func (n *Node) stopHeartbeatTimer() {
+	n.heartbeatGen++
+	if n.heartbeatTimer != nil {
+		n.heartbeatTimer.Stop()
+		n.heartbeatTimer = nil
+	}
+}
+
+func (n *Node) stopTimers() {
+	n.stopElectionTimer()
+	n.stopHeartbeatTimer()
+}
+
+// Helpers
+
+func (n *Node) send(to string, msgType consensus.MessageType, payload interface{}) {
+	n.outbox = append(n.outbox, consensus.Message{
+		Type:      msgType,
+		From:      n.id,
+		To:        to,
+		Term:      n.currentTerm,
+		Data:      encode(payload),
+		Timestamp: n.clock.Now(),
+	})
+}
+
+// Reports whether count voters make a majority
+func (n *Node) hasQuorum(count int) bool {
+	return count > len(n.members.Voters)/2
+}
+
+// Reports whether the node leads, or has heard from its leader within an
+// election timeout
+func (n *Node) hasLeader() bool {
+	if n.state == consensus.StateLeader {
+		return true
+	}
+	return n.leaderID != "" && n.clock.Now().Sub(n.lastContact) < n.config.ElectionTimeout
+}
+
+func (n *Node) lastLogIndex() int64 {
+	return n.log[len(n.log)-1].Index
+}
+
+func (n *Node) lastLogTerm() int64 {
+	return n.log[len(n.log)-1].Term
+}
+
+// Reads a non-negative integer setting. A missing setting is zero.
+func intSetting(cfg config.Config, key string) (int64, error) {
+	var v int64
+	switch value := cfg.Settings[key].(type) {
+	case nil:
+	case int:
+		v = int64(value)
+	case int64:
+		v = value
+	case float64:
+		if value != math.Trunc(value) {
+			return 0, fmt.Errorf("setting %s: expected an integer, got %v", key, value)
+		}
+		v = int64(value)
+	default:
+		return 0, fmt.Errorf("setting %s: expected an integer, got %v", key, value)
+	}
+	if v < 0 {
+		return 0, fmt.Errorf("setting %s must not be negative, got %d", key, v)
+	}
+	return v, nil
+}
+
+// Reads a duration setting, given as a duration string or a number of
+// nanoseconds. A missing setting is zero.
+func durationSetting(cfg config.Config, key string) (time.Duration, error) {
+	var d time.Duration
+	switch value := cfg.Settings[key].(type) {
+	case nil:
+	case time.Duration:
+		d = value
+	case int:
+		d = time.Duration(value)
+	case string:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("setting %s: %w", key, err)
+		}
+		d = parsed
+	default:
+		return 0, fmt.Errorf("setting %s: expected a duration, got %v", key, value)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("setting %s must not be negative, got %v", key, d)
+	}
+	return d, nil
+}
+
+// Returns the term of the entry at index, or -1 if the log does not hold
+// it. The last entry the snapshot covers is still known.
+func (n *Node) termAt(index int64) int64 {
+	if index < n.log[0].Index || index > n.lastLogIndex() {
+		return -1
+	}
+	return n.log[n.offset(index)].Term
+}
+
+// Returns the position of the entry at index in n.log
+func (n *Node) offset(index int64) int64 {
+	return index - n.log[0].Index
+}
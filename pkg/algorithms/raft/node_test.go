@@ -0,0 +1,875 @@
+package raft
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/config"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/consensus"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/network"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/storage"
+)
+
+// Records applied commands in order
+type recordingStateMachine struct {
+	mu      sync.Mutex
+	applied []string
+}
+
+func (r *recordingStateMachine) Apply(data []byte) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.applied = append(r.applied, string(data))
+	return nil, nil
+}
+
+func (r *recordingStateMachine) Snapshot() ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return json.Marshal(r.applied)
+}
+
+func (r *recordingStateMachine) Restore(snapshot []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.applied = nil
+	return json.Unmarshal(snapshot, &r.applied)
+}
+
+func (r *recordingStateMachine) GetState() interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.applied...)
+}
+
+type testCluster struct {
+	manager *network.NetworkManager
+	nodes   map[string]*Node
+	sms     map[string]*recordingStateMachine
+}
+
+func newTestCluster(t *testing.T, size int) *testCluster {
+	t.Helper()
+
+	ids := make([]string, size)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("node-%d", i+1)
+	}
+
+	dataDir := t.TempDir()
+	cfg := config.DefaultConfig()
+	cfg.Peers = ids
+	cfg.ElectionTimeout = 50 * time.Millisecond
+	cfg.HeartbeatInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &testCluster{
+		manager: network.NewNetworkManager(),
+		nodes:   make(map[string]*Node),
+		sms:     make(map[string]*recordingStateMachine),
+	}
+	t.Cleanup(func() {
+		cancel()
+		for _, node := range c.nodes {
+			node.Stop()
+		}
+		c.manager.Shutdown()
+	})
+
+	for _, id := range ids {
+		sm := &recordingStateMachine{}
+		cfg.DataDir = filepath.Join(dataDir, id)
+		node, err := NewNode(id, cfg, consensus.Environment{
+			Transport:    c.manager.CreateNode(id),
+			StateMachine: sm,
+		})
+		if err != nil {
+			t.Fatalf("NewNode failed: %v", err)
+		}
+		c.nodes[id] = node
+		c.sms[id] = sm
+	}
+	for _, node := range c.nodes {
+		if err := node.Start(ctx); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+	}
+	return c
+}
+
+func (c *testCluster) waitForLeader(t *testing.T, exclude string) *Node {
+	t.Helper()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		var leader *Node
+		leaders := 0
+		for id, node := range c.nodes {
+			if id != exclude && node.IsLeader() {
+				leader = node
+				leaders++
+			}
+		}
+		if leaders == 1 {
+			return leader
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("No single leader elected in time")
+	return nil
+}
+
+func (c *testCluster) waitForApplied(t *testing.T, want []string, exclude string) {
+	t.Helper()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		done := true
+		for id, sm := range c.sms {
+			if id == exclude {
+				continue
+			}
+			if len(sm.GetState().([]string)) < len(want) {
+				done = false
+			}
+		}
+		if done {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	for id, sm := range c.sms {
+		if id == exclude {
+			continue
+		}
+		applied := sm.GetState().([]string)
+		if len(applied) != len(want) {
+			t.Fatalf("Node %s applied %d entries, expected %d", id, len(applied), len(want))
+		}
+		for i := range want {
+			if applied[i] != want[i] {
+				t.Errorf("Node %s entry %d: expected %q, got %q", id, i, want[i], applied[i])
+			}
+		}
+	}
+}
+
+func TestNewNodeValidation(t *testing.T) {
+	cfg := config.DefaultConfig()
+
+	if _, err := NewNode("", cfg, consensus.Environment{}); err == nil {
+		t.Error("Expected error for empty node id")
+	}
+
+	bad := cfg
+	bad.HeartbeatInterval = bad.ElectionTimeout
+	if _, err := NewNode("node-1", bad, consensus.Environment{}); err == nil {
+		t.Error("Expected error when heartbeat interval is not shorter than election timeout")
+	}
+
+	bad = cfg
+	bad.Settings = map[string]interface{}{SettingSyncInterval: "soon"}
+	if _, err := NewNode("node-1", bad, consensus.Environment{}); err == nil {
+		t.Error("Expected error for an invalid sync interval")
+	}
+
+	bad = cfg
+	bad.Settings = map[string]interface{}{SettingSnapshotThreshold: 2.5}
+	if _, err := NewNode("node-1", bad, consensus.Environment{}); err == nil {
+		t.Error("Expected error for a fractional snapshot threshold")
+	}
+
+	node, err := NewNode("node-1", cfg, consensus.Environment{})
+	if err != nil {
+		t.Fatalf("NewNode failed: %v", err)
+	}
+	if node.GetState() != consensus.StateFollower {
+		t.Errorf("Expected Follower, got %v", node.GetState())
+	}
+	if err := node.Start(context.Background()); err == nil {
+		t.Error("Start should fail without a transport")
+	}
+}
+
+func TestAlgorithm(t *testing.T) {
+	algorithm := New()
+	if algorithm.Name() != "raft" {
+		t.Errorf("Expected name 'raft', got '%s'", algorithm.Name())
+	}
+
+	node, err := algorithm.CreateNode("node-1", config.DefaultConfig())
+	if err != nil {
+		t.Fatalf("CreateNode failed: %v", err)
+	}
+	if _, ok := node.(consensus.Attachable); !ok {
+		t.Error("Raft nodes should be attachable")
+	}
+}
+
+func TestSingleNodeCommits(t *testing.T) {
+	cluster := newTestCluster(t, 1)
+	leader := cluster.waitForLeader(t, "")
+
+	if err := leader.Propose([]byte("x=1")); err != nil {
+		t.Fatalf("Propose failed: %v", err)
+	}
+	cluster.waitForApplied(t, []string{"x=1"}, "")
+}
+
+func TestLeaderElection(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	leader := cluster.waitForLeader(t, "")
+
+	for id, node := range cluster.nodes {
+		if id == leader.ID() {
+			continue
+		}
+		if err := node.Propose([]byte("x")); err != consensus.ErrNotLeader {
+			t.Errorf("Expected ErrNotLeader from follower %s, got %v", id, err)
+		}
+	}
+}
+
+func TestLogReplication(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	leader := cluster.waitForLeader(t, "")
+
+	want := []string{"a", "b", "c", "d", "e"}
+	for _, cmd := range want {
+		if err := leader.Propose([]byte(cmd)); err != nil {
+			t.Fatalf("Propose failed: %v", err)
+		}
+	}
+	cluster.waitForApplied(t, want, "")
+
+	for id, node := range cluster.nodes {
+		if node.CommitIndex() < int64(len(want)) {
+			t.Errorf("Node %s commit index %d, expected at least %d", id, node.CommitIndex(), len(want))
+		}
+	}
+}
+
+func TestObserve(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	leader := cluster.waitForLeader(t, "")
+
+	want := []string{"a", "b"}
+	for _, cmd := range want {
+		if err := leader.Propose([]byte(cmd)); err != nil {
+			t.Fatalf("Propose failed: %v", err)
+		}
+	}
+	cluster.waitForApplied(t, want, "")
+
+	observation := leader.Observe()
+	if observation.ID != leader.ID() || observation.State != consensus.StateLeader || observation.Term != leader.Term() {
+		t.Errorf("Unexpected observation %+v", observation)
+	}
+	log := observation.Log
+	if len(log) < len(want) || log[0].Index != 1 {
+		t.Fatalf("Expected the log from index 1, got %+v", log)
+	}
+	for i, cmd := range want {
+		if entry := log[len(log)-len(want)+i]; string(entry.Command) != cmd {
+			t.Errorf("Expected entry %q, got %+v", cmd, entry)
+		}
+	}
+	if observation.LastApplied != int64(len(log)) || observation.CommitIndex != int64(len(log)) {
+		t.Errorf("Expected every entry committed and applied, got %+v", observation)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	leader := cluster.waitForLeader(t, "")
+
+	want := []string{"a", "b", "c"}
+	for _, cmd := range want {
+		if err := leader.Propose([]byte(cmd)); err != nil {
+			t.Fatalf("Propose failed: %v", err)
+		}
+	}
+	cluster.waitForApplied(t, want, "")
+
+	status := leader.Status(2)
+	if status.ID != leader.ID() || status.State != consensus.StateLeader || status.Leader != leader.ID() {
+		t.Errorf("Unexpected leader status %+v", status)
+	}
+	if status.Term != leader.Term() || status.CommitIndex != leader.CommitIndex() || status.LastApplied != status.CommitIndex {
+		t.Errorf("Status disagrees with the node: %+v", status)
+	}
+	if status.LogLength < int64(len(want)) {
+		t.Errorf("Expected at least %d entries, got %d", len(want), status.LogLength)
+	}
+	if len(status.Entries) != 2 || string(status.Entries[1].Command) != "c" || status.Entries[1].Index != status.CommitIndex {
+		t.Errorf("Expected the last 2 entries, got %+v", status.Entries)
+	}
+	if len(status.Peers) != 2 {
+		t.Errorf("Expected progress for 2 peers, got %+v", status.Peers)
+	}
+	for peer, progress := range status.Peers {
+		if progress.NextIndex <= progress.MatchIndex {
+			t.Errorf("Peer %s: next index %d should be past match index %d", peer, progress.NextIndex, progress.MatchIndex)
+		}
+	}
+
+	for id, node := range cluster.nodes {
+		if id == leader.ID() {
+			continue
+		}
+		status := node.Status(0)
+		if status.Leader != leader.ID() || status.Peers != nil || status.Entries != nil {
+			t.Errorf("Unexpected follower status %+v", status)
+		}
+	}
+}
+
+func TestLeaderFailover(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	leader := cluster.waitForLeader(t, "")
+	oldTerm := leader.Term()
+
+	if err := leader.Propose([]byte("before")); err != nil {
+		t.Fatalf("Propose failed: %v", err)
+	}
+	cluster.waitForApplied(t, []string{"before"}, "")
+
+	leader.Stop()
+	if leader.GetState() != consensus.StateStopped {
+		t.Errorf("Expected Stopped, got %v", leader.GetState())
+	}
+
+	newLeader := cluster.waitForLeader(t, leader.ID())
+	if newLeader.Term() <= oldTerm {
+		t.Errorf("Expected term greater than %d, got %d", oldTerm, newLeader.Term())
+	}
+
+	if err := newLeader.Propose([]byte("after")); err != nil {
+		t.Fatalf("Propose failed: %v", err)
+	}
+	cluster.waitForApplied(t, []string{"before", "after"}, leader.ID())
+}
+
+// Creates node-1 alone on a simulated network and starts it, with a new
+// transport and state machine each time
+// Starts a node on the simulator. Without store it keeps its state as
+// cfg.DataDir says. The node is stopped when the test ends.
+func startSimulatedNode(t *testing.T, sim *network.Simulator, manager *network.NetworkManager,
+	cfg config.Config, id string, store consensus.Storage) (*Node, *recordingStateMachine) {
+	t.Helper()
+
+	sm := &recordingStateMachine{}
+	node, err := NewNode(id, cfg, consensus.Environment{
+		Transport:    manager.CreateNode(id),
+		StateMachine: sm,
+		Clock:        sim,
+		Rand:         sim.NewRand("node/" + id),
+		Storage:      store,
+	})
+	if err != nil {
+		t.Fatalf("NewNode failed: %v", err)
+	}
+	if err := node.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	t.Cleanup(func() { node.Stop() })
+	return node, sm
+}
+
+func lead(t *testing.T, sim *network.Simulator, node *Node, proposals ...string) {
+	t.Helper()
+
+	sim.RunWhile(func() bool { return !node.IsLeader() }, time.Second)
+	for _, cmd := range proposals {
+		if err := node.Propose([]byte(cmd)); err != nil {
+			t.Fatalf("Propose failed: %v", err)
+		}
+	}
+}
+
+func TestRestartRecoversDurableState(t *testing.T) {
+	stores := map[string]func() consensus.Storage{
+		"wal":    func() consensus.Storage { return nil },
+		"memory": func() consensus.Storage { return storage.NewMemory() },
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			cfg.DataDir = t.TempDir()
+			store := newStore()
+			sim := network.NewSimulator(1)
+			manager := network.NewSimulatedNetworkManager(sim)
+			defer manager.Shutdown()
+
+			node, _ := startSimulatedNode(t, sim, manager, cfg, "node-1", store)
+			lead(t, sim, node, "a", "b")
+			before := node.Status(10)
+			if err := node.Stop(); err != nil {
+				t.Fatalf("Stop failed: %v", err)
+			}
+
+			node, sm := startSimulatedNode(t, sim, manager, cfg, "node-1", store)
+			after := node.Status(10)
+			if after.Term != before.Term || after.VotedFor != "node-1" {
+				t.Errorf("Expected term %d and own vote to survive, got term %d vote %q", before.Term, after.Term, after.VotedFor)
+			}
+			if fmt.Sprint(after.Entries) != fmt.Sprint(before.Entries) {
+				t.Errorf("Expected log %v to survive, got %v", before.Entries, after.Entries)
+			}
+			if after.CommitIndex != 0 || after.LastApplied != 0 || after.Leader != "" {
+				t.Errorf("Volatile state should not survive: %+v", after)
+			}
+
+			// Once it leads again it commits and applies the recovered entries
+			lead(t, sim, node)
+			if node.Term() != before.Term+1 {
+				t.Errorf("Expected term %d, got %d", before.Term+1, node.Term())
+			}
+			if applied := sm.GetState().([]string); fmt.Sprint(applied) != "[a b]" {
+				t.Errorf("Expected the recovered entries to be applied, got %v", applied)
+			}
+		})
+	}
+}
+
+func TestCrashLosesUnsyncedWrites(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.Settings = map[string]interface{}{SettingSyncInterval: "50ms"}
+	sim := network.NewSimulator(1)
+	manager := network.NewSimulatedNetworkManager(sim)
+	defer manager.Shutdown()
+
+	node, _ := startSimulatedNode(t, sim, manager, cfg, "node-1", nil)
+	lead(t, sim, node, "a")
+	sim.RunFor(100 * time.Millisecond)
+	node.Propose([]byte("b"))
+	if err := node.Crash(); err != nil {
+		t.Fatalf("Crash failed: %v", err)
+	}
+
+	node, _ = startSimulatedNode(t, sim, manager, cfg, "node-1", nil)
+	status := node.Status(10)
+	if status.Term != 1 || status.LogLength != 2 || string(status.Entries[1].Command) != "a" {
+		t.Errorf("Expected only the synced entries to survive, got %+v", status)
+	}
+}
+
+func TestSlowStorageHoldsLaterMessages(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Peers = []string{"node-1", "node-2"}
+	sim := network.NewSimulator(1)
+	manager := network.NewSimulatedNetworkManager(sim)
+	defer manager.Shutdown()
+	disk, err := storage.NewFaulty(storage.NewMemory(), sim.NewRand("disk"))
+	if err != nil {
+		t.Fatalf("NewFaulty failed: %v", err)
+	}
+	disk.SetConditions(storage.DiskConditions{Latency: 10 * time.Millisecond})
+
+	startSimulatedNode(t, sim, manager, cfg, "node-1", disk)
+	leader := manager.CreateNode("node-2")
+	var replies []appendEntriesResponse
+	var arrivals []time.Duration
+	leader.(consensus.CallbackTransport).OnReceive(func(msg consensus.Message) {
+		var resp appendEntriesResponse
+		json.Unmarshal(msg.Data, &resp)
+		replies = append(replies, resp)
+		arrivals = append(arrivals, sim.Elapsed())
+	})
+	appendEntries := func(req appendEntriesRequest) {
+		leader.Send("node-1", consensus.Message{
+			Type: consensus.MessageAppendEntries, From: "node-2", To: "node-1", Term: 1, Data: encode(req),
+		})
+	}
+
+	// The heartbeat needs no write, but its answer reports the entry whose
+	// sync is still under way, so it must not leave before that sync ends
+	appendEntries(appendEntriesRequest{LeaderID: "node-2", Entries: []consensus.Entry{{Index: 1, Term: 1, Command: []byte("a")}}})
+	sim.RunFor(3 * time.Millisecond)
+	appendEntries(appendEntriesRequest{LeaderID: "node-2", PrevLogIndex: 1, PrevLogTerm: 1})
+	sim.RunFor(20 * time.Millisecond)
+
+	if len(replies) != 2 || !replies[0].Success || !replies[1].Success || replies[1].MatchIndex != 1 {
+		t.Fatalf("Expected two successful replies, got %+v", replies)
+	}
+	for i, at := range arrivals {
+		if at < 11*time.Millisecond {
+			t.Errorf("Reply %d arrived at %v, before the sync completed", i+1, at)
+		}
+	}
+}
+
+func TestSnapshotCompactsLog(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.Settings = map[string]interface{}{SettingSnapshotThreshold: 3}
+	sim := network.NewSimulator(1)
+	manager := network.NewSimulatedNetworkManager(sim)
+	defer manager.Shutdown()
+
+	// The no-op and a, b reach the threshold at index 3; c, d, e at 6
+	node, _ := startSimulatedNode(t, sim, manager, cfg, "node-1", nil)
+	lead(t, sim, node, "a", "b", "c", "d", "e", "f")
+	status := node.Status(10)
+	if status.SnapshotIndex != 6 || status.LogLength != 7 || len(status.Entries) != 1 {
+		t.Errorf("Expected a snapshot at index 6 followed by one entry, got %+v", status)
+	}
+	if observed := node.Observe(); observed.SnapshotIndex != 6 || observed.SnapshotTerm != 1 || len(observed.Log) != 1 {
+		t.Errorf("Expected the observed log to start after the snapshot, got %+v", observed)
+	}
+	node.Stop()
+
+	// A restarted node restores the snapshot and keeps the log after it
+	node, sm := startSimulatedNode(t, sim, manager, cfg, "node-1", nil)
+	if applied := sm.GetState().([]string); fmt.Sprint(applied) != "[a b c d e]" {
+		t.Errorf("Expected the snapshot to be restored, got %v", applied)
+	}
+	if status := node.Status(10); status.CommitIndex != 6 || status.LastApplied != 6 || status.LogLength != 7 {
+		t.Errorf("Expected the snapshot to count as committed and applied, got %+v", status)
+	}
+	lead(t, sim, node)
+	if applied := sm.GetState().([]string); fmt.Sprint(applied) != "[a b c d e f]" {
+		t.Errorf("Expected the entry after the snapshot to be applied, got %v", applied)
+	}
+}
+
+func TestInstallSnapshot(t *testing.T) {
+	ids := []string{"node-1", "node-2", "node-3"}
+	dataDir := t.TempDir()
+	cfg := config.DefaultConfig()
+	cfg.Peers = ids
+	cfg.Settings = map[string]interface{}{
+		SettingSnapshotThreshold: 10,
+		SettingSnapshotChunkSize: 64,
+	}
+	sim := network.NewSimulator(7)
+	manager := network.NewSimulatedNetworkManager(sim)
+	defer manager.Shutdown()
+
+	nodes := make(map[string]*Node)
+	sms := make(map[string]*recordingStateMachine)
+	start := func(id string) {
+		cfg.DataDir = filepath.Join(dataDir, id)
+		nodes[id], sms[id] = startSimulatedNode(t, sim, manager, cfg, id, nil)
+	}
+
+	// node-3 misses everything the other two commit and compact
+	start("node-1")
+	start("node-2")
+	var leader *Node
+	sim.RunWhile(func() bool {
+		for _, node := range nodes {
+			if node.IsLeader() {
+				leader = node
+			}
+		}
+		return leader == nil
+	}, 5*time.Second)
+	if leader == nil {
+		t.Fatal("No leader elected")
+	}
+	var want []string
+	for i := 0; i < 40; i++ {
+		want = append(want, fmt.Sprintf("command-%d", i))
+		if err := leader.Propose([]byte(want[i])); err != nil {
+			t.Fatalf("Propose failed: %v", err)
+		}
+		sim.RunFor(5 * time.Millisecond)
+	}
+	sim.RunFor(100 * time.Millisecond)
+	if status := leader.Status(0); status.SnapshotIndex == 0 {
+		t.Fatalf("Expected the leader to compact its log, got %+v", status)
+	}
+
+	// It catches up through a chunked snapshot over a lossy, slow link
+	conditions := network.DefaultNetworkConditions()
+	conditions.PacketLoss = 0.2
+	conditions.Bandwidth = 4096
+	conditions.QueueLimit = 1024
+	transport, _ := manager.GetNode("node-1")
+	for _, id := range ids[:2] {
+		transport.SetConditions(id, "node-3", conditions)
+		transport.SetConditions("node-3", id, conditions)
+	}
+	chunks := 0
+	manager.SetObserver(func(msg consensus.Message) {
+		if msg.Type == consensus.MessageInstallSnapshot {
+			chunks++
+		}
+	})
+	start("node-3")
+
+	caughtUp := func() bool { return len(sms["node-3"].GetState().([]string)) >= len(want) }
+	sim.RunWhile(func() bool { return !caughtUp() }, 30*time.Second)
+	if applied := sms["node-3"].GetState().([]string); fmt.Sprint(applied) != fmt.Sprint(want) {
+		t.Fatalf("Expected node-3 to catch up with %d commands, got %v", len(want), applied)
+	}
+	if status := nodes["node-3"].Status(0); status.SnapshotIndex == 0 || chunks < 2 {
+		t.Errorf("Expected node-3 to install a snapshot sent in chunks, got %d chunks and %+v", chunks, status)
+	}
+}
+
+// Starts a node of a cluster on a simulated network, keeping its state in
+// memory
+// Returns the leader of the highest term among nodes, waiting up to a
+// few seconds for one
+func awaitLeader(t *testing.T, sim *network.Simulator, nodes map[string]*Node) *Node {
+	t.Helper()
+
+	var leader *Node
+	sim.RunWhile(func() bool {
+		leader = nil
+		for _, node := range nodes {
+			if node.IsLeader() && (leader == nil || node.Term() > leader.Term()) {
+				leader = node
+			}
+		}
+		return leader == nil
+	}, 5*time.Second)
+	if leader == nil {
+		t.Fatal("No leader elected")
+	}
+	return leader
+}
+
+func TestMembershipChanges(t *testing.T) {
+	ids := []string{"node-1", "node-2", "node-3"}
+	cfg := config.DefaultConfig()
+	cfg.Peers = ids
+	sim := network.NewSimulator(3)
+	manager := network.NewSimulatedNetworkManager(sim)
+	defer manager.Shutdown()
+
+	nodes := make(map[string]*Node)
+	sms := make(map[string]*recordingStateMachine)
+	for _, id := range ids {
+		nodes[id], sms[id] = startSimulatedNode(t, sim, manager, cfg, id, storage.NewMemory())
+	}
+	leader := awaitLeader(t, sim, nodes)
+	if err := leader.Propose([]byte("a")); err != nil {
+		t.Fatalf("Propose failed: %v", err)
+	}
+
+	joining := cfg
+	joining.Join = true
+	nodes["node-4"], sms["node-4"] = startSimulatedNode(t, sim, manager, joining, "node-4", storage.NewMemory())
+	sim.RunFor(time.Second)
+	if members, _ := nodes["node-4"].Membership(); members.Contains("node-4") || nodes["node-4"].Term() != 0 {
+		t.Fatalf("A joining node should wait to be added, got %+v in term %d", members, nodes["node-4"].Term())
+	}
+
+	change := func(node *Node, change consensus.MembershipChange) {
+		t.Helper()
+		if err := node.ChangeMembership(change); err != nil {
+			t.Fatalf("%s failed: %v", change, err)
+		}
+		if err := node.ChangeMembership(change); !errors.Is(err, consensus.ErrChangeInProgress) {
+			t.Fatalf("A second change should wait for %s, got %v", change, err)
+		}
+		sim.RunFor(500 * time.Millisecond)
+		if _, committed := node.Membership(); !committed {
+			t.Fatalf("%s did not commit", change)
+		}
+	}
+
+	// node-4 catches up as a learner, then votes
+	change(leader, consensus.MembershipChange{Type: consensus.ChangeAddLearner, Node: "node-4"})
+	if applied := sms["node-4"].GetState().([]string); fmt.Sprint(applied) != "[a]" {
+		t.Fatalf("Expected the learner to apply [a], got %v", applied)
+	}
+	if members, _ := nodes["node-4"].Membership(); !members.Contains("node-4") || members.IsVoter("node-4") {
+		t.Fatalf("Expected node-4 to be a learner, got %+v", members)
+	}
+	change(leader, consensus.MembershipChange{Type: consensus.ChangePromote, Node: "node-4"})
+	if members, _ := leader.Membership(); len(members.Voters) != 4 {
+		t.Fatalf("Expected 4 voters, got %+v", members)
+	}
+
+	// A leader that removes itself hands over to the remaining voters
+	removed := leader
+	if err := removed.ChangeMembership(consensus.MembershipChange{Type: consensus.ChangeRemove, Node: removed.ID()}); err != nil {
+		t.Fatalf("Removing the leader failed: %v", err)
+	}
+	delete(nodes, removed.ID())
+	leader = awaitLeader(t, sim, nodes)
+	if removed.IsLeader() {
+		t.Fatal("The removed leader should step down")
+	}
+	if members, _ := leader.Membership(); members.Contains(removed.ID()) || len(members.Voters) != 3 {
+		t.Fatalf("Expected %s to be removed, got %+v", removed.ID(), members)
+	}
+	if err := removed.ChangeMembership(consensus.MembershipChange{Type: consensus.ChangeAddVoter, Node: "node-5"}); !errors.Is(err, consensus.ErrNotLeader) {
+		t.Errorf("Expected ErrNotLeader from a removed node, got %v", err)
+	}
+
+	if err := leader.Propose([]byte("b")); err != nil {
+		t.Fatalf("Propose failed: %v", err)
+	}
+	sim.RunFor(time.Second)
+	for id := range nodes {
+		if applied := sms[id].GetState().([]string); fmt.Sprint(applied) != "[a b]" {
+			t.Errorf("Expected %s to apply [a b], got %v", id, applied)
+		}
+	}
+	if removed.IsLeader() || removed.GetState() == consensus.StateCandidate {
+		t.Errorf("The removed node should not stand for election, got %v", removed.GetState())
+	}
+}
+
+func TestSnapshotCarriesMembership(t *testing.T) {
+	ids := []string{"node-1", "node-2", "node-3"}
+	cfg := config.DefaultConfig()
+	cfg.Peers = ids
+	cfg.Settings = map[string]interface{}{SettingSnapshotThreshold: 5}
+	sim := network.NewSimulator(9)
+	manager := network.NewSimulatedNetworkManager(sim)
+	defer manager.Shutdown()
+
+	nodes := make(map[string]*Node)
+	for _, id := range ids {
+		nodes[id], _ = startSimulatedNode(t, sim, manager, cfg, id, storage.NewMemory())
+	}
+	leader := awaitLeader(t, sim, nodes)
+	var want []string
+	for i := 0; i < 20; i++ {
+		want = append(want, fmt.Sprintf("command-%d", i))
+		if err := leader.Propose([]byte(want[i])); err != nil {
+			t.Fatalf("Propose failed: %v", err)
+		}
+		sim.RunFor(5 * time.Millisecond)
+	}
+
+	// The entry holding the initial membership is compacted away, so
+	// node-4 learns it from the snapshot
+	joining := cfg
+	joining.Join = true
+	node, sm := startSimulatedNode(t, sim, manager, joining, "node-4", storage.NewMemory())
+	if err := leader.ChangeMembership(consensus.MembershipChange{Type: consensus.ChangeAddLearner, Node: "node-4"}); err != nil {
+		t.Fatalf("Adding node-4 failed: %v", err)
+	}
+	sim.RunFor(time.Second)
+
+	if applied := sm.GetState().([]string); fmt.Sprint(applied) != fmt.Sprint(want) {
+		t.Fatalf("Expected node-4 to catch up with %d commands, got %v", len(want), applied)
+	}
+	status := node.Status(0)
+	if status.SnapshotIndex == 0 {
+		t.Fatalf("Expected node-4 to install a snapshot, got %+v", status)
+	}
+	if members := status.Members; len(members.Voters) != 3 || !members.Contains("node-4") || members.IsVoter("node-4") {
+		t.Errorf("Expected 3 voters and node-4 as a learner, got %+v", members)
+	}
+}
+
+func TestRemovedNodeDoesNotDisrupt(t *testing.T) {
+	ids := []string{"node-1", "node-2", "node-3"}
+	cfg := config.DefaultConfig()
+	cfg.Peers = ids
+	sim := network.NewSimulator(5)
+	manager := network.NewSimulatedNetworkManager(sim)
+	defer manager.Shutdown()
+
+	nodes := make(map[string]*Node)
+	for _, id := range ids {
+		nodes[id], _ = startSimulatedNode(t, sim, manager, cfg, id, storage.NewMemory())
+	}
+	leader := awaitLeader(t, sim, nodes)
+	sim.RunFor(200 * time.Millisecond)
+
+	// The node never learns it was removed and keeps starting elections
+	var outcast string
+	for _, id := range ids {
+		if id != leader.ID() {
+			outcast = id
+			break
+		}
+	}
+	if err := manager.Isolate(outcast); err != nil {
+		t.Fatal(err)
+	}
+	if err := leader.ChangeMembership(consensus.MembershipChange{Type: consensus.ChangeRemove, Node: outcast}); err != nil {
+		t.Fatalf("Removing %s failed: %v", outcast, err)
+	}
+	sim.RunFor(2 * time.Second)
+	if _, committed := leader.Membership(); !committed {
+		t.Fatal("The removal did not commit")
+	}
+	if nodes[outcast].Term() <= leader.Term() {
+		t.Fatalf("Expected %s to reach a higher term while isolated", outcast)
+	}
+
+	term := leader.Term()
+	manager.Heal()
+	sim.RunFor(2 * time.Second)
+	if !leader.IsLeader() || leader.Term() != term {
+		t.Errorf("Expected %s to stay leader in term %d, got %v in term %d",
+			leader.ID(), term, leader.GetState(), leader.Term())
+	}
+}
+
+func TestRegistered(t *testing.T) {
+	algorithm, err := consensus.Lookup(config.DefaultConfig().Algorithm)
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	if algorithm.Name() != AlgorithmName {
+		t.Errorf("Expected %s, got %s", AlgorithmName, algorithm.Name())
+	}
+}
+
+// Runs a simulated cluster and returns a trace of observable state
+func simulatedRun(t *testing.T, seed uint64) []string {
+	t.Helper()
+
+	ids := []string{"node-1", "node-2", "node-3"}
+	dataDir := t.TempDir()
+	cfg := config.DefaultConfig()
+	cfg.Peers = ids
+
+	sim := network.NewSimulator(seed)
+	manager := network.NewSimulatedNetworkManager(sim)
+	nodes := make([]*Node, 0, len(ids))
+	for _, id := range ids {
+		cfg.DataDir = filepath.Join(dataDir, id)
+		node, _ := startSimulatedNode(t, sim, manager, cfg, id, nil)
+		nodes = append(nodes, node)
+	}
+
+	var trace []string
+	for i := 0; i < 100; i++ {
+		sim.RunFor(20 * time.Millisecond)
+		for _, node := range nodes {
+			if node.IsLeader() {
+				node.Propose([]byte(fmt.Sprint(i)))
+			}
+			trace = append(trace, fmt.Sprintf("%v %s %v %d %d",
+				sim.Elapsed(), node.ID(), node.GetState(), node.Term(), node.CommitIndex()))
+		}
+	}
+	return trace
+}
+
+func TestSimulatedRunDeterministic(t *testing.T) {
+	start := time.Now()
+	first := simulatedRun(t, 11)
+	second := simulatedRun(t, 11)
+
+	if fmt.Sprint(first) != fmt.Sprint(second) {
+		t.Error("Runs with the same seed should be identical")
+	}
+	if last := first[len(first)-1]; strings.HasSuffix(last, " 0") {
+		t.Errorf("Expected entries to commit, last observation: %s", last)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Two simulated 2s runs took %v of wall-clock time", elapsed)
+	}
+}
@@ -0,0 +1,65 @@
+// Package checker verifies that the client history of a run is
+// linearizable: that every operation appears to take effect at a single
+// instant between its invocation and its return, in an order a
+// sequential model of the data accepts.
+package checker
+
+import (
+	"fmt"
+	"time"
+)
+
+// Defines what an operation does
+type OpKind string
+
+const (
+	OpRead  OpKind = "read"
+	OpWrite OpKind = "write"
+	OpAdd   OpKind = "add"
+)
+
+// Defines how an operation ended
+type Status string
+
+const (
+	// The operation took effect and Output holds its result
+	StatusOK Status = "ok"
+	// The operation certainly did not take effect
+	StatusFail Status = "fail"
+	// The outcome is unknown: the operation may take effect at any point
+	// after its invocation, even after the history ends
+	StatusInfo Status = "info"
+)
+
+// A client operation. Invoke and Return are offsets from the start of
+// the history; the Return of an info operation is when the client stopped
+// waiting, and is otherwise ignored.
+type Operation struct {
+	ID     int64         `json:"id"`
+	Client int           `json:"client"`
+	Node   string        `json:"node,omitempty"` // node the operation was proposed to
+	Kind   OpKind        `json:"kind"`
+	Key    string        `json:"key,omitempty"`
+	Input  int64         `json:"input,omitempty"`  // value written or added
+	Output *int64        `json:"output,omitempty"` // value read, nil if never written
+	Status Status        `json:"status"`
+	Error  string        `json:"error,omitempty"` // why the operation failed or is unknown
+	Invoke time.Duration `json:"invoke"`
+	Return time.Duration `json:"return"`
+}
+
+func (op Operation) String() string {
+	return fmt.Sprintf("client %d: %s [%s]", op.Client, op.call(), op.Status)
+}
+
+// Describes the operation as a call with its result
+func (op Operation) call() string {
+	if op.Kind == OpRead {
+		output := "nil"
+		if op.Output != nil {
+			output = fmt.Sprint(*op.Output)
+		}
+		return fmt.Sprintf("read(%s) -> %s", op.Key, output)
+	}
+	return fmt.Sprintf("%s(%s, %d)", op.Kind, op.Key, op.Input)
+}
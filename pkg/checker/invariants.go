@@ -0,0 +1,273 @@
+package checker
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/consensus"
+)
+
+// Safety invariants of a replicated log checked by Monitor
+const (
+	// At most one leader is elected in a term
+	ElectionSafety = "election_safety"
+	// Entries with the same index and term hold the same command and
+	// follow the same log
+	LogMatching = "log_matching"
+	// A leader holds every entry committed before its term
+	LeaderCompleteness = "leader_completeness"
+	// No two nodes apply different commands at the same index
+	StateMachineSafety = "state_machine_safety"
+)
+
+// A broken invariant
+type InvariantViolation struct {
+	Invariant string
+	Message   string
+}
+
+func (v InvariantViolation) String() string {
+	return fmt.Sprintf("%s: %s", v.Invariant, v.Message)
+}
+
+// Identifies the entry a leader created at an index in its term
+type entryID struct {
+	index int64
+	term  int64
+}
+
+// What the first node seen holding an entry had at and before it
+type entryRecord struct {
+	node     string
+	command  string
+	prevTerm int64
+}
+
+// An entry as first seen committed or applied
+type settledEntry struct {
+	node    string
+	term    int64
+	command string
+	at      int64 // term of the node when it was seen
+}
+
+// Checks the safety invariants of Raft against successive observations
+// of a cluster. Feed it every running node after each step and it
+// reports a violation as soon as an observation breaks an invariant. Each
+// broken invariant is reported once per term or index.
+type Monitor struct {
+	leaders   map[int64]string // term -> leader
+	entries   map[entryID]entryRecord
+	committed map[int64]settledEntry // index -> entry
+	applied   map[int64]settledEntry // index -> entry
+	reported  map[string]bool
+
+	// The log of each node as last observed
+	logs map[string][]consensus.Entry
+
+	// Per node, the highest index already checked for each property and
+	// the last term it was checked as leader
+	committedUpTo map[string]int64
+	appliedUpTo   map[string]int64
+	leaderChecked map[string]int64
+}
+
+func NewMonitor() *Monitor {
+	return &Monitor{
+		leaders:       make(map[int64]string),
+		entries:       make(map[entryID]entryRecord),
+		committed:     make(map[int64]settledEntry),
+		applied:       make(map[int64]settledEntry),
+		reported:      make(map[string]bool),
+		logs:          make(map[string][]consensus.Entry),
+		committedUpTo: make(map[string]int64),
+		appliedUpTo:   make(map[string]int64),
+		leaderChecked: make(map[string]int64),
+	}
+}
+
+// Observe checks one observation of every running node and returns the
+// violations it reveals
+func (m *Monitor) Observe(nodes []consensus.Observation) []InvariantViolation {
+	var violations []InvariantViolation
+	report := func(invariant string, key int64, format string, args ...interface{}) {
+		id := fmt.Sprintf("%s/%d", invariant, key)
+		if m.reported[id] {
+			return
+		}
+		m.reported[id] = true
+		violations = append(violations, InvariantViolation{
+			Invariant: invariant,
+			Message:   fmt.Sprintf(format, args...),
+		})
+	}
+
+	for _, node := range nodes {
+		m.checkLeader(node, report)
+		m.checkLog(node, report)
+		m.checkApplied(node, report)
+	}
+	for _, node := range nodes {
+		m.recordCommitted(node)
+	}
+	for _, node := range nodes {
+		m.checkCompleteness(node, report)
+	}
+	return violations
+}
+
+type reportFunc func(invariant string, key int64, format string, args ...interface{})
+
+func (m *Monitor) checkLeader(node consensus.Observation, report reportFunc) {
+	if node.State != consensus.StateLeader {
+		return
+	}
+	leader, exists := m.leaders[node.Term]
+	if !exists {
+		m.leaders[node.Term] = node.ID
+		return
+	}
+	if leader != node.ID {
+		report(ElectionSafety, node.Term, "%s and %s are both leader in term %d", leader, node.ID, node.Term)
+	}
+}
+
+// Logs only change at their tail, so only the entries that differ from
+// the node's previous observation are checked. A log that still holds the
+// previous last entry has only grown, or been compacted at its head.
+func (m *Monitor) checkLog(node consensus.Observation, report reportFunc) {
+	previous := m.logs[node.ID]
+	log := node.Log
+	m.logs[node.ID] = log
+
+	// Position in log of the first entry not in the previous observation
+	unchanged := 0
+	if len(previous) > 0 && len(log) > 0 {
+		start := log[0].Index
+		at := func(index int64) (int, bool) {
+			pos := index - start
+			return int(pos), pos >= 0 && pos < int64(len(log))
+		}
+		if pos, ok := at(previous[len(previous)-1].Index); ok && sameEntry(previous[len(previous)-1], log[pos]) {
+			unchanged = pos + 1
+		} else {
+			for _, entry := range previous {
+				if entry.Index < start {
+					continue
+				}
+				pos, ok := at(entry.Index)
+				if !ok || !sameEntry(entry, log[pos]) {
+					break
+				}
+				unchanged = pos + 1
+			}
+		}
+	}
+
+	prevTerm := node.SnapshotTerm
+	if unchanged > 0 {
+		prevTerm = log[unchanged-1].Term
+	}
+	for _, entry := range log[unchanged:] {
+		id := entryID{index: entry.Index, term: entry.Term}
+		record := entryRecord{node: node.ID, command: string(entry.Command), prevTerm: prevTerm}
+		prevTerm = entry.Term
+
+		seen, exists := m.entries[id]
+		switch {
+		case !exists:
+			m.entries[id] = record
+		case seen.command != record.command:
+			report(LogMatching, entry.Index, "%s and %s hold different commands at index %d in term %d",
+				seen.node, node.ID, entry.Index, entry.Term)
+		case seen.prevTerm != record.prevTerm:
+			report(LogMatching, entry.Index, "%s and %s agree on index %d in term %d but not on the entry before it (terms %d and %d)",
+				seen.node, node.ID, entry.Index, entry.Term, seen.prevTerm, record.prevTerm)
+		}
+	}
+}
+
+func (m *Monitor) checkApplied(node consensus.Observation, report reportFunc) {
+	from := m.appliedUpTo[node.ID]
+	if node.LastApplied < from {
+		// The node restarted with an empty state machine
+		from = 0
+	}
+	for _, entry := range between(node.Log, from, node.LastApplied) {
+		seen, exists := m.applied[entry.Index]
+		if !exists {
+			m.applied[entry.Index] = settledEntry{node: node.ID, term: entry.Term, command: string(entry.Command)}
+			continue
+		}
+		if seen.term != entry.Term || seen.command != string(entry.Command) {
+			report(StateMachineSafety, entry.Index, "%s applied the entry of term %d at index %d, %s applied the entry of term %d",
+				seen.node, seen.term, entry.Index, node.ID, entry.Term)
+		}
+	}
+	m.appliedUpTo[node.ID] = node.LastApplied
+}
+
+func (m *Monitor) recordCommitted(node consensus.Observation) {
+	from := m.committedUpTo[node.ID]
+	if node.CommitIndex < from {
+		from = 0
+	}
+	for _, entry := range between(node.Log, from, node.CommitIndex) {
+		if _, exists := m.committed[entry.Index]; !exists {
+			m.committed[entry.Index] = settledEntry{
+				node:    node.ID,
+				term:    entry.Term,
+				command: string(entry.Command),
+				at:      node.Term,
+			}
+		}
+	}
+	m.committedUpTo[node.ID] = node.CommitIndex
+}
+
+// A leader never removes entries from its log, only compacts them into a
+// snapshot, so it is enough to check it once per term
+func (m *Monitor) checkCompleteness(node consensus.Observation, report reportFunc) {
+	if node.State != consensus.StateLeader || m.leaderChecked[node.ID] == node.Term {
+		return
+	}
+	m.leaderChecked[node.ID] = node.Term
+
+	held := make(map[int64]consensus.Entry, len(node.Log))
+	for _, entry := range node.Log {
+		held[entry.Index] = entry
+	}
+	indexes := make([]int64, 0, len(m.committed))
+	for index := range m.committed {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+
+	for _, index := range indexes {
+		committed := m.committed[index]
+		if committed.at >= node.Term || index <= node.SnapshotIndex {
+			continue
+		}
+		entry, exists := held[index]
+		if !exists || entry.Term != committed.term || string(entry.Command) != committed.command {
+			report(LeaderCompleteness, node.Term, "%s leads term %d without the entry committed at index %d in term %d",
+				node.ID, node.Term, index, committed.at)
+			return
+		}
+	}
+}
+
+// Returns the entries of log after index from, up to and including index to
+func between(log []consensus.Entry, from, to int64) []consensus.Entry {
+	start := sort.Search(len(log), func(i int) bool { return log[i].Index > from })
+	end := sort.Search(len(log), func(i int) bool { return log[i].Index > to })
+	if end < start {
+		return nil
+	}
+	return log[start:end]
+}
+
+func sameEntry(a, b consensus.Entry) bool {
+	return a.Index == b.Index && a.Term == b.Term && a.Type == b.Type && bytes.Equal(a.Command, b.Command)
+}
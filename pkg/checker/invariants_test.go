@@ -0,0 +1,158 @@
+package checker
+
+import (
+	"testing"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/consensus"
+)
+
+func entries(terms ...int64) []consensus.Entry {
+	log := make([]consensus.Entry, len(terms))
+	for i, term := range terms {
+		log[i] = consensus.Entry{Index: int64(i + 1), Term: term, Command: []byte{byte(term), byte(i)}}
+	}
+	return log
+}
+
+func node(id string, state consensus.NodeState, term int64, log []consensus.Entry, commit, applied int64) consensus.Observation {
+	return consensus.Observation{ID: id, State: state, Term: term, Log: log, CommitIndex: commit, LastApplied: applied}
+}
+
+func invariants(violations []InvariantViolation) []string {
+	names := make([]string, len(violations))
+	for i, v := range violations {
+		names[i] = v.Invariant
+	}
+	return names
+}
+
+func TestMonitorHealthyCluster(t *testing.T) {
+	m := NewMonitor()
+	steps := [][]consensus.Observation{
+		{
+			node("a", consensus.StateLeader, 1, entries(1, 1), 2, 2),
+			node("b", consensus.StateFollower, 1, entries(1), 0, 0),
+		},
+		{
+			node("a", consensus.StateFollower, 2, entries(1, 1), 2, 2),
+			node("b", consensus.StateLeader, 2, entries(1, 1, 2), 2, 1),
+		},
+		{
+			node("a", consensus.StateFollower, 2, entries(1, 1, 2), 3, 3),
+			node("b", consensus.StateLeader, 2, entries(1, 1, 2), 3, 3),
+		},
+	}
+	for i, step := range steps {
+		if violations := m.Observe(step); len(violations) != 0 {
+			t.Errorf("Step %d: unexpected violations %v", i, violations)
+		}
+	}
+}
+
+func TestMonitorElectionSafety(t *testing.T) {
+	m := NewMonitor()
+	m.Observe([]consensus.Observation{node("a", consensus.StateLeader, 3, nil, 0, 0)})
+
+	violations := m.Observe([]consensus.Observation{node("b", consensus.StateLeader, 3, nil, 0, 0)})
+	if len(violations) != 1 || violations[0].Invariant != ElectionSafety {
+		t.Fatalf("Expected an election safety violation, got %v", violations)
+	}
+
+	// Reported once per term
+	if violations := m.Observe([]consensus.Observation{node("b", consensus.StateLeader, 3, nil, 0, 0)}); len(violations) != 0 {
+		t.Errorf("Expected the violation to be reported once, got %v", violations)
+	}
+}
+
+func TestMonitorLogMatching(t *testing.T) {
+	m := NewMonitor()
+	a := entries(1, 1, 2)
+	b := entries(1, 1, 2)
+	b[2].Command = []byte("other")
+	violations := m.Observe([]consensus.Observation{
+		node("a", consensus.StateFollower, 2, a, 0, 0),
+		node("b", consensus.StateFollower, 2, b, 0, 0),
+	})
+	if got := invariants(violations); len(got) != 1 || got[0] != LogMatching {
+		t.Errorf("Expected a log matching violation, got %v", violations)
+	}
+
+	// Same entry, but preceded by a different one
+	m = NewMonitor()
+	c := entries(1, 2, 3)
+	d := entries(1, 1, 3)
+	d[2].Command = c[2].Command
+	violations = m.Observe([]consensus.Observation{
+		node("c", consensus.StateFollower, 3, c, 0, 0),
+		node("d", consensus.StateFollower, 3, d, 0, 0),
+	})
+	if got := invariants(violations); len(got) != 1 || got[0] != LogMatching {
+		t.Errorf("Expected a log matching violation, got %v", violations)
+	}
+}
+
+func TestMonitorLeaderCompleteness(t *testing.T) {
+	m := NewMonitor()
+	m.Observe([]consensus.Observation{
+		node("a", consensus.StateLeader, 1, entries(1, 1), 2, 2),
+		node("b", consensus.StateFollower, 1, entries(1, 1), 2, 2),
+		node("c", consensus.StateFollower, 1, entries(1), 1, 1),
+	})
+
+	// c wins term 2 without the entry committed at index 2
+	violations := m.Observe([]consensus.Observation{
+		node("a", consensus.StateFollower, 2, entries(1, 1), 2, 2),
+		node("c", consensus.StateLeader, 2, entries(1), 1, 1),
+	})
+	if got := invariants(violations); len(got) != 1 || got[0] != LeaderCompleteness {
+		t.Errorf("Expected a leader completeness violation, got %v", violations)
+	}
+}
+
+func TestMonitorStateMachineSafety(t *testing.T) {
+	m := NewMonitor()
+	m.Observe([]consensus.Observation{node("a", consensus.StateFollower, 1, entries(1, 1), 2, 2)})
+
+	// b applies a different entry at index 2. It was never seen in a
+	// log together with a's, so only the applied entries disagree.
+	other := entries(1, 2)
+	violations := m.Observe([]consensus.Observation{node("b", consensus.StateFollower, 2, other, 2, 2)})
+	if got := invariants(violations); len(got) != 1 || got[0] != StateMachineSafety {
+		t.Errorf("Expected a state machine safety violation, got %v", violations)
+	}
+
+	// A restarted node applies from the start again
+	m = NewMonitor()
+	m.Observe([]consensus.Observation{node("a", consensus.StateFollower, 1, entries(1, 1), 2, 2)})
+	m.Observe([]consensus.Observation{node("a", consensus.StateFollower, 1, nil, 0, 0)})
+	violations = m.Observe([]consensus.Observation{node("a", consensus.StateFollower, 2, other, 2, 2)})
+	if got := invariants(violations); len(got) != 1 || got[0] != StateMachineSafety {
+		t.Errorf("Expected the restarted node to be checked again, got %v", violations)
+	}
+}
+
+func TestMonitorCompactedLogs(t *testing.T) {
+	m := NewMonitor()
+	full := entries(1, 1, 1, 1)
+	m.Observe([]consensus.Observation{
+		node("a", consensus.StateLeader, 1, full, 4, 4),
+		node("b", consensus.StateFollower, 1, full, 4, 4),
+	})
+
+	// a compacts the first two entries and leads term 2; what its
+	// snapshot covers still counts as held
+	compacted := node("a", consensus.StateLeader, 2, full[2:], 4, 4)
+	compacted.SnapshotIndex, compacted.SnapshotTerm = 2, 1
+	compacted.Log = append(compacted.Log, consensus.Entry{Index: 5, Term: 2})
+	if violations := m.Observe([]consensus.Observation{compacted, node("b", consensus.StateFollower, 2, full, 4, 4)}); len(violations) != 0 {
+		t.Errorf("Unexpected violations for a compacted log: %v", violations)
+	}
+
+	// The entry after a snapshot still follows the snapshot's last entry
+	c := node("c", consensus.StateFollower, 2, full[2:], 0, 0)
+	c.SnapshotIndex, c.SnapshotTerm = 2, 2
+	violations := m.Observe([]consensus.Observation{c})
+	if got := invariants(violations); len(got) != 1 || got[0] != LogMatching {
+		t.Errorf("Expected a log matching violation, got %v", violations)
+	}
+}
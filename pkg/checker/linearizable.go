@@ -0,0 +1,300 @@
+package checker
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// The outcome of a linearizability check
+type Result struct {
+	Model        string
+	Linearizable bool
+	Operations   int // operations checked, failed ones excluded
+
+	// The smallest sub-history found that is still not linearizable,
+	// ordered by invocation. Removing any one of its operations, other
+	// than a write that one of its reads depends on, makes it
+	// linearizable.
+	// Empty when the history is linearizable.
+	Counterexample []Operation
+}
+
+func (r *Result) String() string {
+	if r.Linearizable {
+		return fmt.Sprintf("%d operations are linearizable as a %s", r.Operations, r.Model)
+	}
+	return fmt.Sprintf("history is not linearizable as a %s; smallest failing sub-history of %d operations:\n%s",
+		r.Model, len(r.Counterexample), Timeline(r.Counterexample))
+}
+
+// Check reports whether ops is linearizable with respect to model. Failed
+// operations are dropped, since they did not take effect, and so are
+// info reads, whose output is unknown. Info writes may take effect at any
+// point after their invocation.
+//
+// The search is the Wing-Gong algorithm with the state caching of Lowe,
+// as used by Porcupine, run on each partition of the model in turn.
+func Check(model Model, ops []Operation) *Result {
+	ops = relevant(ops)
+	result := &Result{Model: model.Name(), Linearizable: true, Operations: len(ops)}
+
+	for _, partition := range model.Partition(ops) {
+		if linearizable(model, partition) {
+			continue
+		}
+		result.Linearizable = false
+		result.Counterexample = minimize(model, partition)
+		break
+	}
+	return result
+}
+
+// Drops the operations that cannot affect the check
+func relevant(ops []Operation) []Operation {
+	kept := make([]Operation, 0, len(ops))
+	for _, op := range ops {
+		switch {
+		case op.Status == StatusFail:
+		case op.Status == StatusInfo && op.Kind == OpRead:
+		default:
+			kept = append(kept, op)
+		}
+	}
+	return kept
+}
+
+// Shrinks a non-linearizable history by removing ever smaller chunks of
+// operations for as long as what remains is still not linearizable
+func minimize(model Model, ops []Operation) []Operation {
+	observer, _ := model.(Observer)
+	observed := func(remaining, removed []Operation) bool {
+		if observer == nil {
+			return false
+		}
+		for _, read := range remaining {
+			for _, write := range removed {
+				if observer.Observes(read, write) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	explainer, _ := model.(Explainer)
+	unexplained := func(remaining, current []Operation) bool {
+		if explainer == nil {
+			return false
+		}
+		for _, read := range remaining {
+			if !explainer.Explains(read, remaining) && explainer.Explains(read, current) {
+				return true
+			}
+		}
+		return false
+	}
+
+	current := append([]Operation(nil), ops...)
+	size := len(current) / 2
+	for size >= 1 {
+		removed := false
+		for start := 0; start < len(current); {
+			end := start + size
+			if end > len(current) {
+				end = len(current)
+			}
+			candidate := append(append([]Operation(nil), current[:start]...), current[end:]...)
+			if len(candidate) > 0 && !observed(candidate, current[start:end]) &&
+				!unexplained(candidate, current) && !linearizable(model, candidate) {
+				current = candidate
+				removed = true
+				continue
+			}
+			start = end
+		}
+		// Single removals repeat until none helps, since dropping one
+		// operation can make another removable
+		if size > 1 || !removed {
+			size /= 2
+		}
+	}
+
+	sort.SliceStable(current, func(i, j int) bool { return current[i].Invoke < current[j].Invoke })
+	return current
+}
+
+// A call or return in the history, linked in time order
+type entry struct {
+	op         int // index of the operation
+	call       bool
+	match      *entry // the return of a call
+	prev, next *entry
+}
+
+// Builds the list of calls and returns in time order behind a sentinel.
+// Calls come before returns at the same instant, treating the operations
+// as concurrent. Info operations never return.
+func buildEntries(ops []Operation) *entry {
+	type event struct {
+		at   time.Duration
+		call bool
+		e    *entry
+	}
+
+	events := make([]event, 0, 2*len(ops))
+	for i, op := range ops {
+		call := &entry{op: i, call: true}
+		events = append(events, event{at: op.Invoke, call: true, e: call})
+
+		ret := &entry{op: i}
+		call.match = ret
+		at := op.Return
+		if op.Status == StatusInfo {
+			at = math.MaxInt64
+		}
+		events = append(events, event{at: at, e: ret})
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].at != events[j].at {
+			return events[i].at < events[j].at
+		}
+		return events[i].call && !events[j].call
+	})
+
+	head := &entry{}
+	last := head
+	for _, ev := range events {
+		ev.e.prev = last
+		last.next = ev.e
+		last = ev.e
+	}
+	return head
+}
+
+// Removes a call and its return from the list
+func lift(call *entry) {
+	call.prev.next = call.next
+	call.next.prev = call.prev
+	ret := call.match
+	ret.prev.next = ret.next
+	if ret.next != nil {
+		ret.next.prev = ret.prev
+	}
+}
+
+// Puts back a call and its return removed by lift
+func unlift(call *entry) {
+	ret := call.match
+	ret.prev.next = ret
+	if ret.next != nil {
+		ret.next.prev = ret
+	}
+	call.prev.next = call
+	call.next.prev = call
+}
+
+// A set of linearized operations
+type bitset []uint64
+
+func newBitset(n int) bitset {
+	return make(bitset, (n+63)/64)
+}
+
+func (b bitset) set(i int) bitset {
+	b[i/64] |= 1 << (uint(i) % 64)
+	return b
+}
+
+func (b bitset) clear(i int) bitset {
+	b[i/64] &^= 1 << (uint(i) % 64)
+	return b
+}
+
+func (b bitset) has(i int) bool {
+	return b[i/64]&(1<<(uint(i)%64)) != 0
+}
+
+func (b bitset) clone() bitset {
+	return append(bitset(nil), b...)
+}
+
+func (b bitset) equals(other bitset) bool {
+	for i := range b {
+		if b[i] != other[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (b bitset) hash() uint64 {
+	hash := uint64(len(b))
+	for _, word := range b {
+		hash = hash*31 + word
+	}
+	return hash
+}
+
+// A configuration already explored: the operations linearized so far
+// and the state they led to
+type visited struct {
+	linearized bitset
+	state      interface{}
+}
+
+// Reports whether ops has a linearization accepted by model
+func linearizable(model Model, ops []Operation) bool {
+	type frame struct {
+		call  *entry
+		state interface{}
+	}
+
+	head := buildEntries(ops)
+	linearized := newBitset(len(ops))
+	cache := make(map[uint64][]visited)
+	var stack []frame
+
+	state := model.Init()
+	e := head.next
+	for head.next != nil {
+		if !e.call {
+			// Every operation pending at this return was tried; undo the
+			// latest choice and try the next one
+			if len(stack) == 0 {
+				return false
+			}
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			state = top.state
+			linearized.clear(top.call.op)
+			unlift(top.call)
+			e = top.call.next
+			continue
+		}
+
+		next, ok := model.Step(state, ops[e.op])
+		if ok {
+			candidate := linearized.clone().set(e.op)
+			hash := candidate.hash()
+			seen := false
+			for _, v := range cache[hash] {
+				if v.state == next && v.linearized.equals(candidate) {
+					seen = true
+					break
+				}
+			}
+			if !seen {
+				cache[hash] = append(cache[hash], visited{linearized: candidate, state: next})
+				stack = append(stack, frame{call: e, state: state})
+				state = next
+				linearized.set(e.op)
+				lift(e)
+				e = head.next
+				continue
+			}
+		}
+		e = e.next
+	}
+	return true
+}
@@ -0,0 +1,271 @@
+package checker
+
+import (
+	"math/rand/v2"
+	"strings"
+	"testing"
+	"time"
+)
+
+func value(v int64) *int64 { return &v }
+
+func write(client int, key string, v int64, invoke, ret int) Operation {
+	return Operation{Client: client, Kind: OpWrite, Key: key, Input: v, Status: StatusOK,
+		Invoke: time.Duration(invoke) * time.Millisecond, Return: time.Duration(ret) * time.Millisecond}
+}
+
+func read(client int, key string, output *int64, invoke, ret int) Operation {
+	return Operation{Client: client, Kind: OpRead, Key: key, Output: output, Status: StatusOK,
+		Invoke: time.Duration(invoke) * time.Millisecond, Return: time.Duration(ret) * time.Millisecond}
+}
+
+func TestCheckRegister(t *testing.T) {
+	tests := []struct {
+		name         string
+		ops          []Operation
+		linearizable bool
+	}{
+		{"empty", nil, true},
+		{"sequential", []Operation{
+			write(0, "x", 1, 0, 10), read(1, "x", value(1), 20, 30),
+		}, true},
+		{"read before any write", []Operation{
+			read(0, "x", nil, 0, 10), write(1, "x", 1, 20, 30),
+		}, true},
+		{"concurrent read sees either value", []Operation{
+			write(0, "x", 1, 0, 10), write(1, "x", 2, 20, 50), read(2, "x", value(1), 30, 40),
+		}, true},
+		{"stale read", []Operation{
+			write(0, "x", 1, 0, 10), write(0, "x", 2, 20, 30), read(1, "x", value(1), 40, 50),
+		}, false},
+		{"read of a value never written", []Operation{
+			write(0, "x", 1, 0, 10), read(1, "x", value(3), 20, 30),
+		}, false},
+		{"reads go back in time", []Operation{
+			write(0, "x", 1, 0, 10), write(1, "x", 2, 5, 100),
+			read(2, "x", value(2), 20, 30), read(3, "x", value(1), 40, 50),
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Check(Register{}, tt.ops)
+			if result.Linearizable != tt.linearizable {
+				t.Errorf("Expected linearizable=%v, got %v", tt.linearizable, result)
+			}
+		})
+	}
+}
+
+func TestCheckStatuses(t *testing.T) {
+	// A failed write did not happen, so reading its value is an error
+	failed := write(0, "x", 1, 0, 10)
+	failed.Status = StatusFail
+	result := Check(Register{}, []Operation{failed, read(1, "x", value(1), 20, 30)})
+	if result.Linearizable {
+		t.Error("Failed writes should not be visible")
+	}
+	if result.Operations != 1 {
+		t.Errorf("Expected failed operations to be dropped, got %d", result.Operations)
+	}
+
+	// An info write may take effect long after the client gave up
+	info := write(0, "x", 1, 0, 10)
+	info.Status = StatusInfo
+	ops := []Operation{info, read(1, "x", nil, 20, 30), read(1, "x", value(1), 40, 50)}
+	if result := Check(Register{}, ops); !result.Linearizable {
+		t.Errorf("Info writes may take effect late, got %v", result)
+	}
+
+	// An info read tells nothing
+	unknown := read(1, "x", value(7), 20, 30)
+	unknown.Status = StatusInfo
+	if result := Check(Register{}, []Operation{unknown}); !result.Linearizable {
+		t.Errorf("Info reads should be ignored, got %v", result)
+	}
+}
+
+func TestCheckKVChecksKeysIndependently(t *testing.T) {
+	ops := []Operation{
+		write(0, "x", 1, 0, 10), write(1, "y", 2, 0, 10),
+		read(0, "x", value(1), 20, 30), read(1, "y", value(2), 20, 30),
+	}
+	if result := Check(KV{}, ops); !result.Linearizable {
+		t.Errorf("Expected linearizable, got %v", result)
+	}
+
+	ops = append(ops, read(2, "y", value(1), 40, 50))
+	result := Check(KV{}, ops)
+	if result.Linearizable {
+		t.Fatal("Reading another key's value should not be linearizable")
+	}
+	for _, op := range result.Counterexample {
+		if op.Key != "y" {
+			t.Errorf("Counterexample should only hold key y, got %v", op)
+		}
+	}
+}
+
+func TestCheckCounter(t *testing.T) {
+	ops := []Operation{
+		read(0, "c", nil, 0, 5),
+		add(0, 2, 10, 20), add(1, 3, 10, 40),
+		read(2, "c", value(2), 15, 25), read(2, "c", value(5), 50, 60),
+	}
+	if result := Check(Counter{}, ops); !result.Linearizable {
+		t.Errorf("Expected linearizable, got %v", result)
+	}
+
+	ops = append(ops, read(3, "c", value(3), 70, 80))
+	if result := Check(Counter{}, ops); result.Linearizable {
+		t.Error("A counter should not go backwards")
+	}
+}
+
+func TestCounterexampleIsMinimal(t *testing.T) {
+	// A long valid history with one stale read in the middle
+	var ops []Operation
+	for i := 0; i < 40; i++ {
+		at := i * 20
+		ops = append(ops, write(0, "x", int64(i), at, at+5), read(1, "x", value(int64(i)), at+10, at+15))
+	}
+	ops[41] = read(1, "x", value(3), 410, 415)
+
+	result := Check(Register{}, ops)
+	if result.Linearizable {
+		t.Fatal("Expected a violation")
+	}
+	// The stale read, the write it observed and one later write are enough
+	if len(result.Counterexample) != 3 {
+		t.Fatalf("Expected a counterexample of 3 operations, got:\n%s", Timeline(result.Counterexample))
+	}
+	report := result.String()
+	for _, want := range []string{"write(x, 3)", "read(x) -> 3"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("Expected %q in the report, got:\n%s", want, report)
+		}
+	}
+	for i, op := range result.Counterexample {
+		if op.Kind != OpWrite || op.Input == 3 {
+			continue
+		}
+		rest := append(append([]Operation(nil), result.Counterexample[:i]...), result.Counterexample[i+1:]...)
+		if !Check(Register{}, rest).Linearizable {
+			t.Errorf("Removing %v should make the counterexample linearizable", op)
+		}
+	}
+}
+
+func add(client int, v int64, invoke, ret int) Operation {
+	op := write(client, "c", v, invoke, ret)
+	op.Kind = OpAdd
+	return op
+}
+
+func TestCounterExplains(t *testing.T) {
+	ops := []Operation{add(0, 2, 0, 10), add(0, 3, 20, 30), add(1, 70, 0, 10), add(1, 100, 40, 50)}
+	tests := []struct {
+		read      Operation
+		explained bool
+	}{
+		{read(2, "c", value(5), 35, 38), true},
+		{read(2, "c", value(75), 35, 38), true},
+		{read(2, "c", value(4), 35, 38), false},
+		{read(2, "c", value(5), 12, 15), false}, // the add of 3 came later
+		{read(2, "c", value(175), 45, 60), true},
+		{read(2, "c", value(500), 45, 60), false},
+		{read(2, "c", nil, 0, 1), true},
+		{read(2, "c", value(1<<40), 45, 60), true}, // too large to pin
+	}
+	for _, tt := range tests {
+		if got := (Counter{}).Explains(tt.read, ops); got != tt.explained {
+			t.Errorf("Explains(%v) = %v, expected %v", tt.read, got, tt.explained)
+		}
+	}
+}
+
+func TestCheckCounterLargeValues(t *testing.T) {
+	ops := []Operation{
+		add(0, 1<<40, 0, 10),
+		read(1, "c", value(1<<40), 20, 30),
+		read(1, "c", value(5), 40, 50),
+	}
+	result := Check(Counter{}, ops)
+	if result.Linearizable || len(result.Counterexample) == 0 {
+		t.Errorf("Expected a violation with a counterexample, got %+v", result)
+	}
+}
+
+func TestCounterCounterexampleIsSmall(t *testing.T) {
+	// A long valid history with one stale read near the end
+	var ops []Operation
+	total := int64(0)
+	for i := 0; i < 100; i++ {
+		at := i * 20
+		delta := int64(1 + i%5)
+		total += delta
+		ops = append(ops, add(0, delta, at, at+5), read(1, "c", value(total), at+10, at+15))
+	}
+	ops[181] = read(1, "c", value(7), 1810, 1815)
+
+	result := Check(Counter{}, ops)
+	if result.Linearizable {
+		t.Fatal("Expected a violation")
+	}
+	if len(result.Counterexample) > 6 {
+		t.Fatalf("Expected a handful of operations, got %d:\n%s", len(result.Counterexample), Timeline(result.Counterexample))
+	}
+	if !strings.Contains(result.String(), "read(c) -> 7") {
+		t.Errorf("Expected the stale read in the counterexample, got:\n%s", result)
+	}
+	for _, op := range result.Counterexample {
+		if op.Kind == OpRead && !(Counter{}).Explains(op, result.Counterexample) {
+			t.Errorf("Nothing in the counterexample explains %v", op)
+		}
+	}
+}
+
+func TestCheckManyConcurrentClients(t *testing.T) {
+	// Simulate an atomic register: each operation takes effect at a random
+	// point inside its interval
+	rng := rand.New(rand.NewPCG(1, 2))
+	var ops []Operation
+	var current *int64
+	type pending struct {
+		op     Operation
+		effect int
+	}
+	var points []pending
+	for client := 0; client < 8; client++ {
+		at := 0
+		for i := 0; i < 25; i++ {
+			invoke := at + rng.IntN(10)
+			ret := invoke + 1 + rng.IntN(50)
+			op := write(client, "x", int64(client*100+i), invoke, ret)
+			if rng.IntN(2) == 0 {
+				op.Kind, op.Input = OpRead, 0
+			}
+			points = append(points, pending{op: op, effect: invoke + rng.IntN(ret-invoke)})
+			at = ret
+		}
+	}
+	for t := 0; t < 2000; t++ {
+		for i := range points {
+			if points[i].effect != t {
+				continue
+			}
+			if points[i].op.Kind == OpWrite {
+				v := points[i].op.Input
+				current = &v
+			} else {
+				points[i].op.Output = current
+			}
+		}
+	}
+	for _, p := range points {
+		ops = append(ops, p.op)
+	}
+
+	if result := Check(Register{}, ops); !result.Linearizable {
+		t.Errorf("Expected an atomic register to be linearizable, got %v", result)
+	}
+}
@@ -0,0 +1,198 @@
+package checker
+
+// A sequential specification of the data operations act on. States must
+// be comparable with ==, since the checker caches the states it visits.
+type Model interface {
+	Name() string
+
+	// Partition splits a history into sub-histories that can be checked
+	// independently, such as one per key
+	Partition(ops []Operation) [][]Operation
+
+	// Init returns the state before any operation
+	Init() interface{}
+
+	// Step applies op to state. It reports false if op could not have
+	// produced its output from state.
+	Step(state interface{}, op Operation) (interface{}, bool)
+}
+
+// Implemented by models whose reads reveal which writes they observed.
+// When shrinking a counterexample the checker keeps every write a
+// remaining read observed, so the counterexample never blames a read for
+// a value that nobody wrote.
+type Observer interface {
+	Observes(read, write Operation) bool
+}
+
+// Implemented by models whose reads are explained by several writes taken
+// together, such as the increments that add up to a counter's value. When
+// shrinking a counterexample the checker keeps a history only if every
+// read that its writes explained still is.
+type Explainer interface {
+	// Explains reports whether writes among ops account for read's output
+	Explains(read Operation, ops []Operation) bool
+}
+
+// The value of a register, or of one key of a map
+type registerState struct {
+	value   int64
+	written bool
+}
+
+// A single register read and written as a whole
+type Register struct{}
+
+// Name Implements Model
+func (Register) Name() string { return "register" }
+
+// Partition Implements Model
+func (Register) Partition(ops []Operation) [][]Operation {
+	return [][]Operation{ops}
+}
+
+// Init Implements Model
+func (Register) Init() interface{} { return registerState{} }
+
+// Step Implements Model
+func (Register) Step(state interface{}, op Operation) (interface{}, bool) {
+	s := state.(registerState)
+	switch op.Kind {
+	case OpRead:
+		if op.Output == nil {
+			return s, !s.written
+		}
+		return s, s.written && *op.Output == s.value
+	case OpWrite:
+		return registerState{value: op.Input, written: true}, true
+	default:
+		return s, false
+	}
+}
+
+// Observes Implements Observer
+func (Register) Observes(read, write Operation) bool {
+	return read.Kind == OpRead && write.Kind == OpWrite &&
+		read.Output != nil && *read.Output == write.Input
+}
+
+// A map of independent registers. Each key is checked on its own, which
+// keeps the search small however many keys the history touches.
+type KV struct{}
+
+// Name Implements Model
+func (KV) Name() string { return "kv" }
+
+// Partition Implements Model
+func (KV) Partition(ops []Operation) [][]Operation {
+	var keys []string
+	byKey := make(map[string][]Operation)
+	for _, op := range ops {
+		if _, exists := byKey[op.Key]; !exists {
+			keys = append(keys, op.Key)
+		}
+		byKey[op.Key] = append(byKey[op.Key], op)
+	}
+
+	partitions := make([][]Operation, 0, len(keys))
+	for _, key := range keys {
+		partitions = append(partitions, byKey[key])
+	}
+	return partitions
+}
+
+// Init Implements Model
+func (KV) Init() interface{} { return registerState{} }
+
+// Step Implements Model
+func (KV) Step(state interface{}, op Operation) (interface{}, bool) {
+	return Register{}.Step(state, op)
+}
+
+// Observes Implements Observer
+func (KV) Observes(read, write Operation) bool {
+	return read.Key == write.Key && Register{}.Observes(read, write)
+}
+
+// A counter that is read and incremented. Reading a counter that was
+// never incremented returns nil or 0.
+type Counter struct{}
+
+// Name Implements Model
+func (Counter) Name() string { return "counter" }
+
+// Partition Implements Model
+func (Counter) Partition(ops []Operation) [][]Operation {
+	return [][]Operation{ops}
+}
+
+// Init Implements Model
+func (Counter) Init() interface{} { return int64(0) }
+
+// Step Implements Model
+func (Counter) Step(state interface{}, op Operation) (interface{}, bool) {
+	value := state.(int64)
+	switch op.Kind {
+	case OpRead:
+		if op.Output == nil {
+			return value, value == 0
+		}
+		return value, *op.Output == value
+	case OpAdd:
+		return value + op.Input, true
+	default:
+		return value, false
+	}
+}
+
+// Largest value read that Counter.Explains pins to the increments adding up
+// to it. The sums it tracks take a bit for every value up to the one read.
+const maxPinnedValue = 1 << 20
+
+// Explains Implements Explainer. Some of the increments invoked before the
+// read returned must add up to the value it read. Reads are not pinned
+// when an increment is negative, since any value can then be reached, nor
+// when the value is above maxPinnedValue.
+func (Counter) Explains(read Operation, ops []Operation) bool {
+	if read.Kind != OpRead || read.Output == nil || *read.Output <= 0 || *read.Output > maxPinnedValue {
+		return true
+	}
+	target := *read.Output
+
+	var deltas []int64
+	total := int64(0)
+	for _, op := range ops {
+		if op.Kind != OpAdd || op.Invoke >= read.Return {
+			continue
+		}
+		if op.Input < 0 {
+			return true
+		}
+		if op.Input > 0 && op.Input <= target {
+			deltas = append(deltas, op.Input)
+			total += op.Input
+		}
+	}
+	return total >= target && sumsTo(target, deltas)
+}
+
+// Reports whether some of deltas, all positive and at most target, add up
+// to target. Reachable sums are kept as bits and shifted by each delta.
+func sumsTo(target int64, deltas []int64) bool {
+	reachable := newBitset(int(target) + 1).set(0)
+	for _, delta := range deltas {
+		words, bits := int(delta/64), uint(delta%64)
+		// From the top down, so every word is shifted before it changes
+		for i := len(reachable) - 1; i >= words; i-- {
+			shifted := reachable[i-words] << bits
+			if bits > 0 && i > words {
+				shifted |= reachable[i-words-1] >> (64 - bits)
+			}
+			reachable[i] |= shifted
+		}
+		if reachable.has(int(target)) {
+			return true
+		}
+	}
+	return false
+}
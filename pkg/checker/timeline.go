@@ -0,0 +1,65 @@
+package checker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Width of the time axis drawn by Timeline
+const timelineWidth = 50
+
+// Timeline draws ops on a shared time axis, one row per operation in
+// order of invocation. Each bar runs from invocation to return; the bar
+// of an info operation runs off the end, since it may take effect at any
+// later point.
+//
+//	          0s                                             30ms
+//	client 0  [===============]                                 |  write(x, 1) ok
+//	client 1          [========================================]|  read(x) -> 2 ok
+func Timeline(ops []Operation) string {
+	if len(ops) == 0 {
+		return ""
+	}
+	ops = append([]Operation(nil), ops...)
+	sort.SliceStable(ops, func(i, j int) bool { return ops[i].Invoke < ops[j].Invoke })
+
+	start, end := ops[0].Invoke, ops[0].Invoke
+	for _, op := range ops {
+		if op.Return > end {
+			end = op.Return
+		}
+	}
+	span := end - start
+	if span <= 0 {
+		span = 1
+	}
+	column := func(at time.Duration) int {
+		return int((at - start) * (timelineWidth - 1) / span)
+	}
+
+	var b strings.Builder
+	from, to := start.String(), end.String()
+	fmt.Fprintf(&b, "%-10s%s%s%s\n", "", from, strings.Repeat(" ", timelineWidth+1-len(from)-len(to)), to)
+	for _, op := range ops {
+		row := []byte(strings.Repeat(" ", timelineWidth) + "|")
+		first := column(op.Invoke)
+		last := column(op.Return)
+		if op.Status == StatusInfo {
+			last = timelineWidth
+		}
+		for i := first; i <= last && i < len(row); i++ {
+			row[i] = '='
+		}
+		row[first] = '['
+		switch {
+		case op.Status == StatusInfo:
+			row[timelineWidth] = '>'
+		case last > first:
+			row[last] = ']'
+		}
+		fmt.Fprintf(&b, "%-10s%s  %s %s\n", fmt.Sprintf("client %d", op.Client), row, op.call(), op.Status)
+	}
+	return b.String()
+}
@@ -0,0 +1,31 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTimeline(t *testing.T) {
+	info := write(2, "x", 3, 20, 25)
+	info.Status = StatusInfo
+	got := Timeline([]Operation{
+		read(1, "x", value(2), 5, 30),
+		write(0, "x", 1, 0, 10),
+		info,
+	})
+
+	want := strings.Join([]string{
+		"          0s                                             30ms",
+		"client 0  [===============]                                 |  write(x, 1) ok",
+		"client 1          [========================================]|  read(x) -> 2 ok",
+		"client 2                                  [=================>  write(x, 3) info",
+		"",
+	}, "\n")
+	if got != want {
+		t.Errorf("Unexpected timeline:\n%s\nwant:\n%s", got, want)
+	}
+
+	if Timeline(nil) != "" {
+		t.Error("Expected an empty timeline for no operations")
+	}
+}
@@ -0,0 +1,93 @@
+// Package clock abstracts time so that nodes, transports and timers can run
+// against the wall clock or against a manually advanced virtual clock.
+package clock
+
+import "time"
+
+// Clock is the source of time and timers
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+
+	// After waits for the duration to elapse and then sends the current
+	// time on the returned channel
+	After(d time.Duration) <-chan time.Time
+
+	// NewTimer creates a timer that fires once after d
+	NewTimer(d time.Duration) Timer
+
+	// NewTicker creates a ticker that fires every d
+	NewTicker(d time.Duration) Ticker
+
+	// AfterFunc calls f after d. The returned timer can cancel the call.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a single event, mirroring time.Timer
+type Timer interface {
+	// C returns the channel the timer fires on. It is nil for timers
+	// created by AfterFunc.
+	C() <-chan time.Time
+
+	// Stop prevents the timer from firing, reporting whether it was pending
+	Stop() bool
+
+	// Reset changes the timer to fire after d, reporting whether it was pending
+	Reset(d time.Duration) bool
+}
+
+// Ticker delivers ticks at intervals, mirroring time.Ticker
+type Ticker interface {
+	// C returns the channel ticks are delivered on
+	C() <-chan time.Time
+
+	// Stop turns off the ticker
+	Stop()
+
+	// Reset changes the ticker period to d
+	Reset(d time.Duration)
+}
+
+// RealClock implements Clock using the time package
+type RealClock struct{}
+
+// Real returns the wall clock
+func Real() Clock {
+	return RealClock{}
+}
+
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+func (RealClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (RealClock) NewTimer(d time.Duration) Timer {
+	return &realTimer{timer: time.NewTimer(d)}
+}
+
+func (RealClock) NewTicker(d time.Duration) Ticker {
+	return &realTicker{ticker: time.NewTicker(d)}
+}
+
+func (RealClock) AfterFunc(d time.Duration, f func()) Timer {
+	return &realTimer{timer: time.AfterFunc(d, f)}
+}
+
+type realTimer struct {
+	timer *time.Timer
+}
+
+func (t *realTimer) C() <-chan time.Time        { return t.timer.C }
+func (t *realTimer) Stop() bool                 { return t.timer.Stop() }
+func (t *realTimer) Reset(d time.Duration) bool { return t.timer.Reset(d) }
+
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+func (t *realTicker) C() <-chan time.Time   { return t.ticker.C }
+func (t *realTicker) Stop()                 { t.ticker.Stop() }
+func (t *realTicker) Reset(d time.Duration) { t.ticker.Reset(d) }
@@ -0,0 +1,287 @@
+package clock
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// Manual is a virtual clock that only moves when advanced. Timers due
+// during an advance fire in time order on the advancing goroutine; timers
+// due at the same instant fire in the order they were scheduled. Channel
+// timers deliver without blocking, so an unread tick is dropped exactly
+// as with time.Ticker.
+type Manual struct {
+	now   time.Time
+	queue timerQueue
+	seq   uint64
+	steps uint64
+	mu    sync.Mutex
+}
+
+// Creates a manual clock reading start
+func NewManual(start time.Time) *Manual {
+	return &Manual{now: start}
+}
+
+// Now Implements Clock
+func (m *Manual) Now() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.now
+}
+
+// After Implements Clock
+func (m *Manual) After(d time.Duration) <-chan time.Time {
+	return m.NewTimer(d).C()
+}
+
+// NewTimer Implements Clock
+func (m *Manual) NewTimer(d time.Duration) Timer {
+	c := make(chan time.Time, 1)
+	t := &manualTimer{clock: m, c: c}
+	t.entry = &timerEntry{index: -1, fire: func(now time.Time) { send(c, now) }}
+	m.schedule(t.entry, d)
+	return t
+}
+
+// NewTicker Implements Clock
+func (m *Manual) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+
+	c := make(chan time.Time, 1)
+	t := &manualTicker{clock: m, c: c, period: d}
+	t.entry = &timerEntry{index: -1}
+	t.entry.fire = func(now time.Time) {
+		send(c, now)
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if !t.stopped {
+			m.pushLocked(t.entry, t.period)
+		}
+	}
+	m.schedule(t.entry, d)
+	return t
+}
+
+// AfterFunc Implements Clock. f runs on the goroutine advancing the clock.
+func (m *Manual) AfterFunc(d time.Duration, f func()) Timer {
+	t := &manualTimer{clock: m}
+	t.entry = &timerEntry{index: -1, fire: func(time.Time) { f() }}
+	m.schedule(t.entry, d)
+	return t
+}
+
+// Step fires the earliest pending timer, moving the clock to its deadline.
+// It reports false when nothing is pending.
+func (m *Manual) Step() bool {
+	m.mu.Lock()
+	if m.queue.Len() == 0 {
+		m.mu.Unlock()
+		return false
+	}
+	entry := heap.Pop(&m.queue).(*timerEntry)
+	if entry.at.After(m.now) {
+		m.now = entry.at
+	}
+	m.steps++
+	now := m.now
+	m.mu.Unlock()
+
+	entry.fire(now)
+	return true
+}
+
+// Advance moves the clock forward by d, firing every timer that falls due.
+// It returns the number of timers fired.
+func (m *Manual) Advance(d time.Duration) int {
+	return m.AdvanceTo(m.Now().Add(d))
+}
+
+// AdvanceTo moves the clock forward to t, firing every timer that falls
+// due. The clock never moves backwards. It returns the number of timers fired.
+func (m *Manual) AdvanceTo(t time.Time) int {
+	fired := 0
+	for {
+		m.mu.Lock()
+		if m.queue.Len() == 0 || m.queue[0].at.After(t) {
+			if m.now.Before(t) {
+				m.now = t
+			}
+			m.mu.Unlock()
+			return fired
+		}
+		m.mu.Unlock()
+
+		m.Step()
+		fired++
+	}
+}
+
+// NextAt returns the deadline of the earliest pending timer
+func (m *Manual) NextAt() (time.Time, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.queue.Len() == 0 {
+		return time.Time{}, false
+	}
+	return m.queue[0].at, true
+}
+
+// Pending returns the number of timers waiting to fire
+func (m *Manual) Pending() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.queue.Len()
+}
+
+// Steps returns the number of timers fired so far
+func (m *Manual) Steps() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.steps
+}
+
+func (m *Manual) schedule(entry *timerEntry, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pushLocked(entry, d)
+}
+
+func (m *Manual) pushLocked(entry *timerEntry, d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	m.seq++
+	entry.at = m.now.Add(d)
+	entry.seq = m.seq
+	heap.Push(&m.queue, entry)
+}
+
+// Removes an entry, reporting whether it was pending
+func (m *Manual) remove(entry *timerEntry) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.removeLocked(entry)
+}
+
+func (m *Manual) removeLocked(entry *timerEntry) bool {
+	if entry.index < 0 {
+		return false
+	}
+	heap.Remove(&m.queue, entry.index)
+	return true
+}
+
+func send(c chan time.Time, now time.Time) {
+	select {
+	case c <- now:
+	default:
+	}
+}
+
+type manualTimer struct {
+	clock *Manual
+	entry *timerEntry
+	c     chan time.Time
+}
+
+func (t *manualTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *manualTimer) Stop() bool {
+	return t.clock.remove(t.entry)
+}
+
+func (t *manualTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	pending := t.clock.removeLocked(t.entry)
+	t.clock.pushLocked(t.entry, d)
+	return pending
+}
+
+type manualTicker struct {
+	clock   *Manual
+	entry   *timerEntry
+	c       chan time.Time
+	period  time.Duration
+	stopped bool
+}
+
+func (t *manualTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *manualTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	t.stopped = true
+	t.clock.removeLocked(t.entry)
+}
+
+func (t *manualTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("clock: non-positive interval for Ticker.Reset")
+	}
+
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	t.stopped = false
+	t.period = d
+	t.clock.removeLocked(t.entry)
+	t.clock.pushLocked(t.entry, d)
+}
+
+type timerEntry struct {
+	at    time.Time
+	seq   uint64
+	fire  func(now time.Time)
+	index int
+}
+
+// Min-heap of timers ordered by deadline, then scheduling order
+type timerQueue []*timerEntry
+
+func (q timerQueue) Len() int { return len(q) }
+
+func (q timerQueue) Less(i, j int) bool {
+	if !q[i].at.Equal(q[j].at) {
+		return q[i].at.Before(q[j].at)
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q timerQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *timerQueue) Push(x interface{}) {
+	entry := x.(*timerEntry)
+	entry.index = len(*q)
+	*q = append(*q, entry)
+}
+
+func (q *timerQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	entry := old[n-1]
+	old[n-1] = nil
+	entry.index = -1
+	*q = old[:n-1]
+	return entry
+}
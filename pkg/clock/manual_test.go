@@ -0,0 +1,149 @@
+package clock
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func TestManualNowAndAdvance(t *testing.T) {
+	clock := NewManual(epoch)
+
+	if !clock.Now().Equal(epoch) {
+		t.Errorf("Expected %v, got %v", epoch, clock.Now())
+	}
+
+	clock.Advance(time.Second)
+	if got := clock.Now().Sub(epoch); got != time.Second {
+		t.Errorf("Expected 1s elapsed, got %v", got)
+	}
+
+	clock.AdvanceTo(epoch)
+	if got := clock.Now().Sub(epoch); got != time.Second {
+		t.Errorf("Clock should not move backwards, got %v elapsed", got)
+	}
+}
+
+func TestManualTimer(t *testing.T) {
+	clock := NewManual(epoch)
+	timer := clock.NewTimer(10 * time.Millisecond)
+
+	clock.Advance(9 * time.Millisecond)
+	select {
+	case <-timer.C():
+		t.Fatal("Timer fired early")
+	default:
+	}
+
+	clock.Advance(time.Millisecond)
+	select {
+	case fired := <-timer.C():
+		if fired.Sub(epoch) != 10*time.Millisecond {
+			t.Errorf("Expected fire time 10ms, got %v", fired.Sub(epoch))
+		}
+	default:
+		t.Fatal("Timer did not fire")
+	}
+
+	if timer.Stop() {
+		t.Error("Stop should report false for a fired timer")
+	}
+	if timer.Reset(5 * time.Millisecond) {
+		t.Error("Reset should report false for a fired timer")
+	}
+	if !timer.Stop() {
+		t.Error("Stop should report true for a pending timer")
+	}
+	clock.Advance(time.Second)
+	select {
+	case <-timer.C():
+		t.Fatal("Stopped timer fired")
+	default:
+	}
+}
+
+func TestManualAfter(t *testing.T) {
+	clock := NewManual(epoch)
+	ch := clock.After(time.Minute)
+
+	clock.Advance(time.Minute)
+	select {
+	case <-ch:
+	default:
+		t.Fatal("After did not fire")
+	}
+}
+
+func TestManualTicker(t *testing.T) {
+	clock := NewManual(epoch)
+	ticker := clock.NewTicker(10 * time.Millisecond)
+
+	ticks := 0
+	for i := 0; i < 5; i++ {
+		clock.Advance(10 * time.Millisecond)
+		select {
+		case <-ticker.C():
+			ticks++
+		default:
+		}
+	}
+	if ticks != 5 {
+		t.Errorf("Expected 5 ticks, got %d", ticks)
+	}
+
+	ticker.Stop()
+	if clock.Pending() != 0 {
+		t.Errorf("Expected no pending timers after Stop, got %d", clock.Pending())
+	}
+}
+
+func TestManualAfterFuncOrder(t *testing.T) {
+	clock := NewManual(epoch)
+
+	var order []string
+	clock.AfterFunc(20*time.Millisecond, func() { order = append(order, "c") })
+	clock.AfterFunc(10*time.Millisecond, func() { order = append(order, "a") })
+	clock.AfterFunc(10*time.Millisecond, func() {
+		order = append(order, "b")
+		// Timers scheduled while firing run in the same advance if due
+		clock.AfterFunc(5*time.Millisecond, func() { order = append(order, "b2") })
+	})
+	cancelled := clock.AfterFunc(15*time.Millisecond, func() { order = append(order, "x") })
+	cancelled.Stop()
+
+	if fired := clock.Advance(time.Second); fired != 4 {
+		t.Errorf("Expected 4 timers fired, got %d", fired)
+	}
+
+	expected := []string{"a", "b", "b2", "c"}
+	if fmt.Sprint(order) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, order)
+	}
+	if clock.Steps() != 4 {
+		t.Errorf("Expected 4 steps, got %d", clock.Steps())
+	}
+}
+
+func TestRealClock(t *testing.T) {
+	clock := Real()
+
+	before := time.Now()
+	if clock.Now().Before(before) {
+		t.Error("Real clock should follow wall-clock time")
+	}
+
+	done := make(chan struct{})
+	clock.AfterFunc(time.Millisecond, func() { close(done) })
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("AfterFunc did not run")
+	}
+
+	timer := clock.NewTimer(time.Hour)
+	if !timer.Stop() {
+		t.Error("Stop should report true for a pending timer")
+	}
+}
@@ -0,0 +1,161 @@
+package clock
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Skewed is a clock that reads a constant offset from its base clock and
+// runs at a drifted rate. A drift of 0.05 runs 5% fast and -0.05 runs 5%
+// slow. Like the monotonic readings behind time.Timer, timers measure
+// elapsed time at the drifted rate and are unaffected by Jump, which only
+// moves what Now reports. Values received from timer channels are readings
+// of the base clock.
+type Skewed struct {
+	base Clock
+
+	mu     sync.Mutex
+	origin time.Time // base reading when the current rate took effect
+	local  time.Time // local reading at origin
+	rate   float64
+}
+
+// Creates a clock reading base plus offset that drifts by drift
+func NewSkewed(base Clock, offset time.Duration, drift float64) (*Skewed, error) {
+	if err := validateDrift(drift); err != nil {
+		return nil, err
+	}
+
+	now := base.Now()
+	return &Skewed{
+		base:   base,
+		origin: now,
+		local:  now.Add(offset),
+		rate:   1 + drift,
+	}, nil
+}
+
+// Now Implements Clock
+func (s *Skewed) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.nowLocked(s.base.Now())
+}
+
+// After Implements Clock
+func (s *Skewed) After(d time.Duration) <-chan time.Time {
+	return s.NewTimer(d).C()
+}
+
+// NewTimer Implements Clock
+func (s *Skewed) NewTimer(d time.Duration) Timer {
+	return &skewedTimer{clock: s, timer: s.base.NewTimer(s.toBase(d))}
+}
+
+// NewTicker Implements Clock
+func (s *Skewed) NewTicker(d time.Duration) Ticker {
+	return &skewedTicker{clock: s, ticker: s.base.NewTicker(s.toBase(d))}
+}
+
+// AfterFunc Implements Clock
+func (s *Skewed) AfterFunc(d time.Duration, f func()) Timer {
+	return &skewedTimer{clock: s, timer: s.base.AfterFunc(s.toBase(d), f)}
+}
+
+// Offset returns how far the clock currently reads ahead of its base
+func (s *Skewed) Offset() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.base.Now()
+	return s.nowLocked(now).Sub(now)
+}
+
+// Drift returns the rate at which the clock gains on its base
+func (s *Skewed) Drift() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.rate - 1
+}
+
+// Set makes the clock read base plus offset from now on, drifting by drift
+func (s *Skewed) Set(offset time.Duration, drift float64) error {
+	if err := validateDrift(drift); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.origin = s.base.Now()
+	s.local = s.origin.Add(offset)
+	s.rate = 1 + drift
+	return nil
+}
+
+// Jump moves the clock's reading by d, which may be negative
+func (s *Skewed) Jump(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.base.Now()
+	s.local = s.nowLocked(now).Add(d)
+	s.origin = now
+}
+
+func (s *Skewed) nowLocked(baseNow time.Time) time.Time {
+	elapsed := baseNow.Sub(s.origin)
+	return s.local.Add(time.Duration(float64(elapsed) * s.rate))
+}
+
+// Converts a duration on this clock to the base clock
+func (s *Skewed) toBase(d time.Duration) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return time.Duration(float64(d) / s.rate)
+}
+
+func validateDrift(drift float64) error {
+	if drift <= -1 {
+		return fmt.Errorf("drift must be greater than -1, got %v", drift)
+	}
+	return nil
+}
+
+type skewedTimer struct {
+	clock *Skewed
+	timer Timer
+}
+
+func (t *skewedTimer) C() <-chan time.Time {
+	return t.timer.C()
+}
+
+func (t *skewedTimer) Stop() bool {
+	return t.timer.Stop()
+}
+
+func (t *skewedTimer) Reset(d time.Duration) bool {
+	return t.timer.Reset(t.clock.toBase(d))
+}
+
+type skewedTicker struct {
+	clock  *Skewed
+	ticker Ticker
+}
+
+func (t *skewedTicker) C() <-chan time.Time {
+	return t.ticker.C()
+}
+
+func (t *skewedTicker) Stop() {
+	t.ticker.Stop()
+}
+
+func (t *skewedTicker) Reset(d time.Duration) {
+	t.ticker.Reset(t.clock.toBase(d))
+}
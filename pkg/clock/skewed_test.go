@@ -0,0 +1,78 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSkewedOffsetAndDrift(t *testing.T) {
+	base := NewManual(epoch)
+	skewed, err := NewSkewed(base, time.Second, 0.05)
+	if err != nil {
+		t.Fatalf("NewSkewed failed: %v", err)
+	}
+
+	if got := skewed.Now().Sub(epoch); got != time.Second {
+		t.Errorf("Expected reading 1s ahead, got %v", got)
+	}
+
+	base.Advance(100 * time.Second)
+	if got := skewed.Now().Sub(epoch); got != 106*time.Second {
+		t.Errorf("Expected 106s after 100s at 5%% fast, got %v", got)
+	}
+	if got := skewed.Offset(); got != 6*time.Second {
+		t.Errorf("Expected offset 6s, got %v", got)
+	}
+
+	if _, err := NewSkewed(base, 0, -1); err == nil {
+		t.Error("Expected error for drift of -1")
+	}
+}
+
+func TestSkewedTimersRunAtDriftedRate(t *testing.T) {
+	base := NewManual(epoch)
+	fast, _ := NewSkewed(base, 0, 1.0) // runs twice as fast
+	slow, _ := NewSkewed(base, 0, -0.5)
+
+	var fastFired, slowFired time.Duration
+	fast.AfterFunc(10*time.Second, func() { fastFired = base.Now().Sub(epoch) })
+	slow.AfterFunc(10*time.Second, func() { slowFired = base.Now().Sub(epoch) })
+
+	base.Advance(time.Minute)
+	if fastFired != 5*time.Second {
+		t.Errorf("Fast clock timer fired at %v, expected 5s", fastFired)
+	}
+	if slowFired != 20*time.Second {
+		t.Errorf("Slow clock timer fired at %v, expected 20s", slowFired)
+	}
+}
+
+func TestSkewedJump(t *testing.T) {
+	base := NewManual(epoch)
+	skewed, _ := NewSkewed(base, 0, 0)
+
+	fired := false
+	skewed.AfterFunc(10*time.Second, func() { fired = true })
+
+	skewed.Jump(-time.Hour)
+	if got := skewed.Now().Sub(epoch); got != -time.Hour {
+		t.Errorf("Expected reading an hour behind, got %v", got)
+	}
+
+	base.Advance(10 * time.Second)
+	if !fired {
+		t.Error("Timers should not be affected by jumps")
+	}
+
+	skewed.Jump(2 * time.Hour)
+	if got := skewed.Now().Sub(epoch); got != time.Hour+10*time.Second {
+		t.Errorf("Expected reading 1h10s, got %v", got)
+	}
+
+	if err := skewed.Set(0, 0); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if !skewed.Now().Equal(base.Now()) {
+		t.Error("Set(0, 0) should make the clock read its base")
+	}
+}
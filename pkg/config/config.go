@@ -10,6 +10,10 @@ type Config struct {
 	Peers   []string `yaml:"peers"`
 	DataDir string   `yaml:"data_dir"`
 
+	// Set for a node joining a running cluster, which waits to be added
+	// rather than forming a cluster with Peers
+	Join bool `yaml:"join,omitempty"`
+
 	// Timing configuration
 	ElectionTimeout   time.Duration `yaml:"election_timeout"`
 	HeartbeatInterval time.Duration `yaml:"heartbeat_interval"`
@@ -2,8 +2,20 @@ package consensus
 
 import (
 	"context"
+	"errors"
+	"math/rand/v2"
 
+	"github.com/francisco-teixeirax86/consensusforge/pkg/clock"
 	"github.com/francisco-teixeirax86/consensusforge/pkg/config"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/logging"
+)
+
+var (
+	// Returned by Propose when the node cannot accept client requests
+	ErrNotLeader = errors.New("node is not the leader")
+
+	// Returned when operating on a node that has been stopped
+	ErrStopped = errors.New("node is stopped")
 )
 
 // Defines the interface for consensus algorithm implementations
@@ -23,6 +35,91 @@ type Node interface {
 	GetState() NodeState
 }
 
+// Holds the runtime dependencies the harness wires into a node. Nodes
+// take every timeout and random choice from Clock and Rand, so a harness
+// that supplies a virtual clock and a seeded source gets reproducible runs.
+type Environment struct {
+	Transport    Transport
+	StateMachine StateMachine
+	Logger       logging.Logger
+	Clock        clock.Clock
+	Rand         *rand.Rand
+
+	// Where the node persists its state. Nodes that persist state open
+	// storage under config.Config.DataDir when none is supplied.
+	Storage Storage
+}
+
+// Implemented by nodes whose dependencies are supplied after CreateNode
+// and before Start
+type Attachable interface {
+	Attach(env Environment) error
+}
+
+// Implemented by nodes that persist state. Crash stops the node as a
+// power failure would: writes it has not synced to disk are lost, where
+// Stop syncs them first.
+type Crashable interface {
+	Crash() error
+}
+
+// A snapshot of a node's replicated state, taken by checkers that verify
+// safety properties while a cluster runs
+type Observation struct {
+	ID    string
+	State NodeState
+	Term  int64
+
+	// The last entry compacted into a snapshot, zero without one
+	SnapshotIndex int64
+	SnapshotTerm  int64
+
+	Log         []Entry // every entry the node holds after the snapshot, in index order; read only
+	CommitIndex int64
+	LastApplied int64
+}
+
+// Implemented by nodes that expose their replicated state to checkers
+type Observable interface {
+	Observe() Observation
+}
+
+// A detailed view of a node for checkers, dashboards and reports. Fields
+// an algorithm has no notion of are left zero.
+type NodeStatus struct {
+	ID          string    `json:"id"`
+	State       NodeState `json:"state"`
+	Term        int64     `json:"term"`
+	VotedFor    string    `json:"voted_for,omitempty"`
+	Leader      string    `json:"leader,omitempty"` // leader the node follows, if known
+	CommitIndex int64     `json:"commit_index"`
+	LastApplied int64     `json:"last_applied"`
+	LogLength   int64     `json:"log_length"`        // entries held
+	Entries     []Entry   `json:"entries,omitempty"` // the most recent entries, oldest first
+
+	// Last index compacted into a snapshot; LogLength still counts it
+	SnapshotIndex int64 `json:"snapshot_index,omitempty"`
+
+	// Replication progress of each peer, reported by leaders
+	Peers map[string]PeerProgress `json:"peers,omitempty"`
+
+	// Latest membership the node knows of, reported by Reconfigurable nodes
+	Members *Membership `json:"members,omitempty"`
+}
+
+// What a leader knows about a peer's copy of the log
+type PeerProgress struct {
+	MatchIndex int64 `json:"match_index"` // highest index known to be replicated
+	NextIndex  int64 `json:"next_index"`  // next index to send
+}
+
+// Implemented by nodes that describe themselves to tooling
+type Inspectable interface {
+	// Status describes the node, including up to entries of its most
+	// recent log entries
+	Status(entries int) NodeStatus
+}
+
 // Represents the current state of a consensus node
 type NodeState int
 
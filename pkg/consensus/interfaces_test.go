@@ -37,6 +37,8 @@ func TestMessageType(t *testing.T) {
 		MessageAccepted,
 		MessageHeartbeat,
 		MessageClientRequest,
+		MessageInstallSnapshot,
+		MessageInstallSnapshotResponse,
 	}
 	
 	seen := make(map[MessageType]bool)
@@ -64,3 +66,18 @@ func TestEntryType(t *testing.T) {
 		seen[entryType] = true
 	}
 }
+
+func TestMessageTypeString(t *testing.T) {
+	if MessageAppendEntries.String() != "AppendEntries" {
+		t.Errorf("Expected AppendEntries, got %s", MessageAppendEntries)
+	}
+	if MessageClientRequest.String() != "ClientRequest" {
+		t.Errorf("Expected ClientRequest, got %s", MessageClientRequest)
+	}
+	if MessageInstallSnapshot.String() != "InstallSnapshot" {
+		t.Errorf("Expected InstallSnapshot, got %s", MessageInstallSnapshot)
+	}
+	if MessageType(999).String() != "Unknown" {
+		t.Errorf("Expected Unknown, got %s", MessageType(999))
+	}
+}
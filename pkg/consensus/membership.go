@@ -0,0 +1,145 @@
+package consensus
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Returned by ChangeMembership while an earlier change is not committed,
+// or before the leader has committed an entry of its own term
+var ErrChangeInProgress = errors.New("a membership change is in progress")
+
+// The members of a cluster. Voters elect leaders and make up the quorums
+// that commit entries; learners only receive the log.
+type Membership struct {
+	Voters   []string `json:"voters"`
+	Learners []string `json:"learners,omitempty"`
+}
+
+// Defines how a membership change affects its node
+type ChangeType int
+
+const (
+	// Adds a node that receives the log without voting
+	ChangeAddLearner ChangeType = iota
+	// Adds a node as a voter straight away
+	ChangeAddVoter
+	// Turns a learner into a voter
+	ChangePromote
+	// Removes a voter or a learner
+	ChangeRemove
+)
+
+func (t ChangeType) String() string {
+	switch t {
+	case ChangeAddLearner:
+		return "add learner"
+	case ChangeAddVoter:
+		return "add voter"
+	case ChangePromote:
+		return "promote"
+	case ChangeRemove:
+		return "remove"
+	default:
+		return "unknown"
+	}
+}
+
+// A change to the membership of a cluster, one node at a time
+type MembershipChange struct {
+	Type ChangeType
+	Node string
+}
+
+func (c MembershipChange) String() string {
+	return fmt.Sprintf("%s %s", c.Type, c.Node)
+}
+
+// Reports whether id is a voter
+func (m Membership) IsVoter(id string) bool {
+	return contains(m.Voters, id)
+}
+
+// Reports whether id is a voter or a learner
+func (m Membership) Contains(id string) bool {
+	return contains(m.Voters, id) || contains(m.Learners, id)
+}
+
+// Apply returns the membership after change, leaving m untouched
+func (m Membership) Apply(change MembershipChange) (Membership, error) {
+	id := change.Node
+	if id == "" {
+		return m, fmt.Errorf("membership change needs a node")
+	}
+	next := Membership{
+		Voters:   append([]string(nil), m.Voters...),
+		Learners: append([]string(nil), m.Learners...),
+	}
+
+	switch change.Type {
+	case ChangeAddLearner, ChangeAddVoter:
+		if m.Contains(id) {
+			return m, fmt.Errorf("%s is already a member", id)
+		}
+		if change.Type == ChangeAddVoter {
+			next.Voters = append(next.Voters, id)
+		} else {
+			next.Learners = append(next.Learners, id)
+		}
+	case ChangePromote:
+		if !contains(m.Learners, id) {
+			return m, fmt.Errorf("%s is not a learner", id)
+		}
+		next.Learners = without(next.Learners, id)
+		next.Voters = append(next.Voters, id)
+	case ChangeRemove:
+		if !m.Contains(id) {
+			return m, fmt.Errorf("%s is not a member", id)
+		}
+		if m.IsVoter(id) && len(m.Voters) == 1 {
+			return m, fmt.Errorf("cannot remove the last voter %s", id)
+		}
+		next.Voters = without(next.Voters, id)
+		next.Learners = without(next.Learners, id)
+	default:
+		return m, fmt.Errorf("unknown membership change %d", int(change.Type))
+	}
+
+	sort.Strings(next.Voters)
+	sort.Strings(next.Learners)
+	if len(next.Learners) == 0 {
+		next.Learners = nil
+	}
+	return next, nil
+}
+
+// Implemented by nodes whose cluster membership can change while they run
+type Reconfigurable interface {
+	// ChangeMembership proposes a change through the leader. It takes
+	// effect once its entry is in the log and is final once committed.
+	ChangeMembership(change MembershipChange) error
+
+	// Membership returns the latest membership the node knows of and
+	// whether it is committed
+	Membership() (Membership, bool)
+}
+
+func contains(ids []string, id string) bool {
+	for _, member := range ids {
+		if member == id {
+			return true
+		}
+	}
+	return false
+}
+
+func without(ids []string, id string) []string {
+	kept := ids[:0]
+	for _, member := range ids {
+		if member != id {
+			kept = append(kept, member)
+		}
+	}
+	return kept
+}
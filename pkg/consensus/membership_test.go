@@ -0,0 +1,68 @@
+package consensus
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMembershipApply(t *testing.T) {
+	m := Membership{Voters: []string{"a", "b", "c"}}
+
+	steps := []struct {
+		change MembershipChange
+		want   Membership
+	}{
+		{MembershipChange{ChangeAddLearner, "d"}, Membership{Voters: []string{"a", "b", "c"}, Learners: []string{"d"}}},
+		{MembershipChange{ChangePromote, "d"}, Membership{Voters: []string{"a", "b", "c", "d"}}},
+		{MembershipChange{ChangeRemove, "a"}, Membership{Voters: []string{"b", "c", "d"}}},
+		{MembershipChange{ChangeAddVoter, "a"}, Membership{Voters: []string{"a", "b", "c", "d"}}},
+	}
+	for _, step := range steps {
+		next, err := m.Apply(step.change)
+		if err != nil {
+			t.Fatalf("%s: %v", step.change, err)
+		}
+		if !reflect.DeepEqual(next, step.want) {
+			t.Fatalf("%s: expected %+v, got %+v", step.change, step.want, next)
+		}
+		m = next
+	}
+
+	if !m.IsVoter("a") || m.Contains("e") {
+		t.Errorf("Unexpected membership %+v", m)
+	}
+}
+
+func TestMembershipApplyLeavesOriginal(t *testing.T) {
+	m := Membership{Voters: []string{"a", "b"}, Learners: []string{"c"}}
+
+	if _, err := m.Apply(MembershipChange{ChangeRemove, "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Apply(MembershipChange{ChangePromote, "c"}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, Membership{Voters: []string{"a", "b"}, Learners: []string{"c"}}) {
+		t.Errorf("Apply changed the original membership: %+v", m)
+	}
+}
+
+func TestMembershipApplyInvalid(t *testing.T) {
+	m := Membership{Voters: []string{"a"}, Learners: []string{"b"}}
+
+	invalid := []MembershipChange{
+		{ChangeAddVoter, "a"},
+		{ChangeAddLearner, "b"},
+		{ChangePromote, "a"},
+		{ChangePromote, "c"},
+		{ChangeRemove, "c"},
+		{ChangeRemove, "a"}, // the last voter
+		{ChangeAddVoter, ""},
+		{ChangeType(42), "c"},
+	}
+	for _, change := range invalid {
+		if _, err := m.Apply(change); err == nil {
+			t.Errorf("%s should fail", change)
+		}
+	}
+}
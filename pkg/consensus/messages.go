@@ -21,8 +21,44 @@ const (
 	// Generic types
 	MessageHeartbeat
 	MessageClientRequest
+
+	// Raft snapshot transfer, numbered after the rest so that existing
+	// types keep their values
+	MessageInstallSnapshot
+	MessageInstallSnapshotResponse
 )
 
+func (t MessageType) String() string {
+	switch t {
+	case MessageAppendEntries:
+		return "AppendEntries"
+	case MessageRequestVote:
+		return "RequestVote"
+	case MessageAppendEntriesResponse:
+		return "AppendEntriesResponse"
+	case MessageRequestVoteResponse:
+		return "RequestVoteResponse"
+	case MessagePrepare:
+		return "Prepare"
+	case MessagePromise:
+		return "Promise"
+	case MessageAccept:
+		return "Accept"
+	case MessageAccepted:
+		return "Accepted"
+	case MessageHeartbeat:
+		return "Heartbeat"
+	case MessageClientRequest:
+		return "ClientRequest"
+	case MessageInstallSnapshot:
+		return "InstallSnapshot"
+	case MessageInstallSnapshotResponse:
+		return "InstallSnapshotResponse"
+	default:
+		return "Unknown"
+	}
+}
+
 // Represents a consensus protocol message
 type Message struct {
 	Type      MessageType `json:"type"`
@@ -43,3 +79,12 @@ type Transport interface {
 
 	Close() error
 }
+
+// Implemented by transports that can hand each delivered message to a
+// callback instead of the Receive channel. A simulated transport invokes
+// the callback from its scheduler, which keeps whole runs deterministic.
+type CallbackTransport interface {
+	Transport
+
+	OnReceive(handler func(Message))
+}
@@ -0,0 +1,89 @@
+package consensus
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Maps algorithm names to implementations
+type Registry struct {
+	algorithms map[string]Algorithm
+	mu         sync.RWMutex
+}
+
+// Creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		algorithms: make(map[string]Algorithm),
+	}
+}
+
+// Register adds an algorithm under its Name. Registering a nil algorithm,
+// an empty name or the same name twice is a programming error and panics.
+func (r *Registry) Register(algorithm Algorithm) {
+	if algorithm == nil {
+		panic("consensus: Register algorithm is nil")
+	}
+	name := algorithm.Name()
+	if name == "" {
+		panic("consensus: Register algorithm has an empty name")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.algorithms[name]; exists {
+		panic(fmt.Sprintf("consensus: Register called twice for algorithm %q", name))
+	}
+	r.algorithms[name] = algorithm
+}
+
+// Lookup returns the algorithm registered under name
+func (r *Registry) Lookup(name string) (Algorithm, error) {
+	r.mu.RLock()
+	algorithm, exists := r.algorithms[name]
+	r.mu.RUnlock()
+
+	if !exists {
+		known := r.List()
+		if len(known) == 0 {
+			return nil, fmt.Errorf("unknown algorithm %q: no algorithms are registered", name)
+		}
+		return nil, fmt.Errorf("unknown algorithm %q (known: %s)", name, strings.Join(known, ", "))
+	}
+	return algorithm, nil
+}
+
+// List returns the registered algorithm names in sorted order
+func (r *Registry) List() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.algorithms))
+	for name := range r.algorithms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var defaultRegistry = NewRegistry()
+
+// Register adds an algorithm to the default registry. Implementations
+// call it from init() so that importing them makes them selectable by
+// config.Config.Algorithm.
+func Register(algorithm Algorithm) {
+	defaultRegistry.Register(algorithm)
+}
+
+// Lookup returns the algorithm registered under name in the default registry
+func Lookup(name string) (Algorithm, error) {
+	return defaultRegistry.Lookup(name)
+}
+
+// List returns the names registered in the default registry
+func List() []string {
+	return defaultRegistry.List()
+}
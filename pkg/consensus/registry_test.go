@@ -0,0 +1,84 @@
+package consensus
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/config"
+)
+
+type fakeAlgorithm struct {
+	name string
+}
+
+func (f *fakeAlgorithm) Name() string { return f.name }
+
+func (f *fakeAlgorithm) CreateNode(id string, cfg config.Config) (Node, error) {
+	return nil, nil
+}
+
+func TestRegistryRegisterLookup(t *testing.T) {
+	registry := NewRegistry()
+
+	raft := &fakeAlgorithm{name: "raft"}
+	registry.Register(raft)
+	registry.Register(&fakeAlgorithm{name: "paxos"})
+
+	algorithm, err := registry.Lookup("raft")
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	if algorithm != raft {
+		t.Error("Lookup returned a different algorithm")
+	}
+
+	names := registry.List()
+	if len(names) != 2 || names[0] != "paxos" || names[1] != "raft" {
+		t.Errorf("Expected [paxos raft], got %v", names)
+	}
+}
+
+func TestRegistryUnknownAlgorithm(t *testing.T) {
+	registry := NewRegistry()
+
+	_, err := registry.Lookup("raft")
+	if err == nil {
+		t.Fatal("Lookup should fail on an empty registry")
+	}
+
+	registry.Register(&fakeAlgorithm{name: "raft"})
+	registry.Register(&fakeAlgorithm{name: "paxos"})
+
+	_, err = registry.Lookup("zab")
+	if err == nil {
+		t.Fatal("Lookup should fail for an unknown algorithm")
+	}
+	if !strings.Contains(err.Error(), "zab") || !strings.Contains(err.Error(), "paxos, raft") {
+		t.Errorf("Error should name the algorithm and list known ones, got: %v", err)
+	}
+}
+
+func TestRegistryRejectsInvalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm Algorithm
+	}{
+		{"nil", nil},
+		{"empty name", &fakeAlgorithm{}},
+		{"duplicate", &fakeAlgorithm{name: "raft"}},
+	}
+
+	registry := NewRegistry()
+	registry.Register(&fakeAlgorithm{name: "raft"})
+
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Register should panic for %s", test.name)
+				}
+			}()
+			registry.Register(test.algorithm)
+		}()
+	}
+}
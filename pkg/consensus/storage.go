@@ -0,0 +1,77 @@
+package consensus
+
+import (
+	"errors"
+	"time"
+)
+
+// Returned when stored data fails its integrity check
+var ErrCorrupt = errors.New("storage is corrupt")
+
+// The state a node must persist before it answers a message, so that it
+// never votes twice in a term or forgets the term it has seen
+type HardState struct {
+	Term     int64  `json:"term"`
+	VotedFor string `json:"voted_for,omitempty"`
+}
+
+// A snapshot of the state machine that stands in for the log up to and
+// including Index
+type Snapshot struct {
+	Index int64  `json:"index"` // last entry the snapshot covers
+	Term  int64  `json:"term"`  // term of that entry
+	Data  []byte `json:"data,omitempty"`
+
+	// Command of the last EntryConfig entry the snapshot covers, so the
+	// membership survives compaction
+	Config []byte `json:"config,omitempty"`
+}
+
+// Persists the entries of a replicated log. Writes are applied at once
+// but only survive a crash once synced.
+type LogStore interface {
+	// Index of the first entry held, or LastIndex()+1 when empty
+	FirstIndex() int64
+	// Index of the last entry held, or the snapshot index when empty
+	LastIndex() int64
+	// Returns the entries from index lo up to but excluding hi
+	Entries(lo, hi int64) ([]Entry, error)
+	// Adds entries after the last one; their indexes must follow on
+	Append(entries []Entry) error
+	// Removes every entry from index on
+	Truncate(index int64) error
+	Sync() error
+}
+
+// Persists the hard state of a node
+type StableStore interface {
+	GetHardState() (HardState, error)
+	SetHardState(state HardState) error
+	Sync() error
+}
+
+// Persists the latest snapshot of a node
+type SnapshotStore interface {
+	// Returns the latest snapshot, with Index 0 when there is none
+	GetSnapshot() (Snapshot, error)
+	// Replaces the snapshot and compacts the log: the entries it covers
+	// are removed and the log goes on from the entry after it
+	SetSnapshot(snapshot Snapshot) error
+	Sync() error
+}
+
+// Durable storage for a node: its log, hard state and snapshot. A storage
+// that also implements Crashable can lose the writes it has not synced.
+type Storage interface {
+	LogStore
+	StableStore
+	SnapshotStore
+	Close() error
+}
+
+// Implemented by storage whose syncs take time. A node holds back the
+// messages that depend on a sync until it would have completed.
+type SlowStorage interface {
+	// How long the last sync took
+	SyncDelay() time.Duration
+}
@@ -0,0 +1,59 @@
+package network
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/consensus"
+)
+
+// Token bucket shaping one direction of a link. It is kept in its
+// theoretical-arrival-time form: drained marks when the bucket would be
+// full again if nothing else were sent, so time spent before drained,
+// less the burst allowance, is time a new message has to queue.
+type linkShaper struct {
+	drained time.Time
+}
+
+// Admits a message of size bytes at now, returning how long it waits for
+// the link, including its own transmission, and whether it was dropped
+// because the queue ahead of it exceeded the limit
+func (ls *linkShaper) admit(now time.Time, size int64, conditions NetworkConditions) (time.Duration, bool) {
+	burst := bytesToDuration(conditions.BandwidthBurst, conditions.Bandwidth)
+
+	start := ls.drained
+	if start.Before(now) {
+		start = now
+	}
+
+	if conditions.QueueLimit > 0 {
+		waiting := start.Sub(now) - burst
+		if waiting > 0 && durationToBytes(waiting, conditions.Bandwidth) > conditions.QueueLimit {
+			return 0, true
+		}
+	}
+
+	ls.drained = start.Add(bytesToDuration(size, conditions.Bandwidth))
+	delay := ls.drained.Sub(now) - burst
+	if delay < 0 {
+		delay = 0
+	}
+	return delay, false
+}
+
+// Returns the size a message occupies on the wire
+func messageSize(msg consensus.Message) int64 {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return int64(len(msg.Data))
+	}
+	return int64(len(data))
+}
+
+func bytesToDuration(bytes, bandwidth int64) time.Duration {
+	return time.Duration(float64(bytes) / float64(bandwidth) * float64(time.Second))
+}
+
+func durationToBytes(d time.Duration, bandwidth int64) int64 {
+	return int64(d.Seconds() * float64(bandwidth))
+}
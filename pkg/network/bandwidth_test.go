@@ -0,0 +1,114 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/consensus"
+)
+
+func TestLinkShaperQueueing(t *testing.T) {
+	conditions := DefaultNetworkConditions()
+	conditions.Bandwidth = 1000 // 1 byte per millisecond
+
+	shaper := &linkShaper{}
+	now := SimulationEpoch
+
+	// Back-to-back messages queue behind each other
+	for i, expected := range []time.Duration{100, 200, 300} {
+		delay, dropped := shaper.admit(now, 100, conditions)
+		if dropped {
+			t.Fatalf("Message %d dropped with an unbounded queue", i)
+		}
+		if delay != expected*time.Millisecond {
+			t.Errorf("Message %d: expected delay %dms, got %v", i, expected, delay)
+		}
+	}
+
+	// Once the queue drains only transmission time remains
+	delay, _ := shaper.admit(now.Add(time.Second), 100, conditions)
+	if delay != 100*time.Millisecond {
+		t.Errorf("Expected delay 100ms on an idle link, got %v", delay)
+	}
+}
+
+func TestLinkShaperBurst(t *testing.T) {
+	conditions := DefaultNetworkConditions()
+	conditions.Bandwidth = 1000
+	conditions.BandwidthBurst = 250
+
+	shaper := &linkShaper{}
+	now := SimulationEpoch
+
+	expected := []time.Duration{0, 0, 50 * time.Millisecond, 150 * time.Millisecond}
+	for i := range expected {
+		delay, _ := shaper.admit(now, 100, conditions)
+		if delay != expected[i] {
+			t.Errorf("Message %d: expected delay %v, got %v", i, expected[i], delay)
+		}
+	}
+}
+
+func TestLinkShaperTailDrop(t *testing.T) {
+	conditions := DefaultNetworkConditions()
+	conditions.Bandwidth = 1000
+	conditions.QueueLimit = 250
+
+	shaper := &linkShaper{}
+	now := SimulationEpoch
+
+	accepted := 0
+	for i := 0; i < 10; i++ {
+		if _, dropped := shaper.admit(now, 100, conditions); !dropped {
+			accepted++
+		}
+	}
+	// One in transmission plus 250 bytes of queue
+	if accepted != 3 {
+		t.Errorf("Expected 3 messages admitted, got %d", accepted)
+	}
+}
+
+func TestMemoryTransportBandwidth(t *testing.T) {
+	sim := NewSimulator(1)
+	manager := NewSimulatedNetworkManager(sim)
+	node1 := manager.CreateNode("node-1")
+	node2 := manager.CreateNode("node-2").(*MemoryTransport)
+
+	msg := consensus.Message{From: "node-1", To: "node-2", Data: make([]byte, 1000)}
+	size := messageSize(msg)
+
+	conditions := DefaultNetworkConditions()
+	conditions.BaseLatency = 0
+	conditions.Bandwidth = size * 10 // ten messages per second
+	conditions.QueueLimit = size * 4
+	node1.SetConditions("node-1", "node-2", conditions)
+
+	var arrivals []time.Duration
+	node2.OnReceive(func(consensus.Message) {
+		arrivals = append(arrivals, sim.Elapsed())
+	})
+
+	for i := 0; i < 10; i++ {
+		node1.Send("node-2", msg)
+	}
+	sim.RunFor(10 * time.Second)
+
+	if len(arrivals) != 5 {
+		t.Fatalf("Expected 5 deliveries with a four message queue, got %d", len(arrivals))
+	}
+	for i, arrival := range arrivals {
+		expected := time.Duration(i+1) * 100 * time.Millisecond
+		if diff := arrival - expected; diff < -time.Millisecond || diff > time.Millisecond {
+			t.Errorf("Delivery %d at %v, expected about %v", i, arrival, expected)
+		}
+	}
+
+	stats := node1.GetStats()
+	if stats.MessagesTailDrop != 5 || stats.MessagesDropped != 5 {
+		t.Errorf("Expected 5 tail drops, got %d (dropped %d)", stats.MessagesTailDrop, stats.MessagesDropped)
+	}
+	if stats.AverageQueueDelay != 300*time.Millisecond {
+		t.Errorf("Expected average queue delay 300ms, got %v", stats.AverageQueueDelay)
+	}
+}
@@ -0,0 +1,95 @@
+package network
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/clock"
+)
+
+// Describes the time faults injected into one node's clock
+type ClockConditions struct {
+	Offset time.Duration `yaml:"offset"` // how far the clock reads ahead
+	Drift  float64       `yaml:"drift"`  // 0.05 runs 5% fast, -0.05 runs 5% slow
+
+	Jumps []ClockJump `yaml:"jumps"`
+}
+
+// A step change to a node's clock reading during a run
+type ClockJump struct {
+	After time.Duration `yaml:"after"` // delay after the conditions are applied
+	By    time.Duration `yaml:"by"`    // negative values jump backwards
+}
+
+// DefaultClockConditions returns an accurate clock
+func DefaultClockConditions() ClockConditions {
+	return ClockConditions{
+		Offset: 0,
+		Drift:  0.0,
+		Jumps:  []ClockJump{},
+	}
+}
+
+// A node's skewed clock and its pending jumps
+type nodeClock struct {
+	clock *clock.Skewed
+	jumps []clock.Timer
+}
+
+// NodeClock returns the clock a node should run on. It follows the
+// network clock until time faults are injected with SetClockConditions.
+func (nm *NetworkManager) NodeClock(nodeID string) (*clock.Skewed, error) {
+	nm.mu.RLock()
+	defer nm.mu.RUnlock()
+
+	nc, exists := nm.clocks[nodeID]
+	if !exists {
+		return nil, fmt.Errorf("node %s not found", nodeID)
+	}
+	return nc.clock, nil
+}
+
+// SetClockConditions applies offset and drift to a node's clock from now
+// on and schedules its jumps, replacing any jumps still pending
+func (nm *NetworkManager) SetClockConditions(nodeID string, conditions ClockConditions) error {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	nc, exists := nm.clocks[nodeID]
+	if !exists {
+		return fmt.Errorf("node %s not found", nodeID)
+	}
+	if err := nc.clock.Set(conditions.Offset, conditions.Drift); err != nil {
+		return fmt.Errorf("node %s: %w", nodeID, err)
+	}
+
+	nc.stopJumps()
+	for _, jump := range conditions.Jumps {
+		by := jump.By
+		skewed := nc.clock
+		nc.jumps = append(nc.jumps, nm.clock.AfterFunc(jump.After, func() { skewed.Jump(by) }))
+	}
+	return nil
+}
+
+// GetClockConditions returns a node's current offset and drift
+func (nm *NetworkManager) GetClockConditions(nodeID string) (ClockConditions, error) {
+	nm.mu.RLock()
+	defer nm.mu.RUnlock()
+
+	nc, exists := nm.clocks[nodeID]
+	if !exists {
+		return ClockConditions{}, fmt.Errorf("node %s not found", nodeID)
+	}
+	conditions := DefaultClockConditions()
+	conditions.Offset = nc.clock.Offset()
+	conditions.Drift = nc.clock.Drift()
+	return conditions, nil
+}
+
+func (nc *nodeClock) stopJumps() {
+	for _, jump := range nc.jumps {
+		jump.Stop()
+	}
+	nc.jumps = nil
+}
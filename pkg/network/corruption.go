@@ -0,0 +1,162 @@
+package network
+
+import (
+	"fmt"
+	"math/rand/v2"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/consensus"
+)
+
+// Defines a way a message can be corrupted in transit
+type CorruptionMode int
+
+const (
+	// Payload corruption
+	CorruptBitFlip CorruptionMode = iota
+	CorruptTruncate
+	CorruptByteSwap
+
+	// Header corruption
+	CorruptTerm
+	CorruptFrom
+	CorruptType
+)
+
+// Type corruption turns a message into any other type up to this one
+const lastMessageType = consensus.MessageInstallSnapshotResponse
+
+var corruptionModeNames = map[CorruptionMode]string{
+	CorruptBitFlip:  "bit_flip",
+	CorruptTruncate: "truncate",
+	CorruptByteSwap: "byte_swap",
+	CorruptTerm:     "term",
+	CorruptFrom:     "from",
+	CorruptType:     "type",
+}
+
+// AllCorruptionModes returns every corruption mode
+func AllCorruptionModes() []CorruptionMode {
+	return []CorruptionMode{
+		CorruptBitFlip,
+		CorruptTruncate,
+		CorruptByteSwap,
+		CorruptTerm,
+		CorruptFrom,
+		CorruptType,
+	}
+}
+
+func (m CorruptionMode) String() string {
+	if name, ok := corruptionModeNames[m]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// MarshalText lets modes appear by name in configuration files
+func (m CorruptionMode) MarshalText() ([]byte, error) {
+	if _, ok := corruptionModeNames[m]; !ok {
+		return nil, fmt.Errorf("unknown corruption mode %d", int(m))
+	}
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText parses a mode name such as "bit_flip"
+func (m *CorruptionMode) UnmarshalText(text []byte) error {
+	for mode, name := range corruptionModeNames {
+		if name == string(text) {
+			*m = mode
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown corruption mode %q", text)
+}
+
+// Reports whether the mode can alter msg
+func (m CorruptionMode) applies(msg consensus.Message, nodes []string) bool {
+	switch m {
+	case CorruptBitFlip, CorruptTruncate:
+		return len(msg.Data) > 0
+	case CorruptByteSwap:
+		// Swapping identical bytes would leave the payload intact
+		for i := 1; i < len(msg.Data); i++ {
+			if msg.Data[i] != msg.Data[0] {
+				return true
+			}
+		}
+		return false
+	case CorruptFrom:
+		for _, node := range nodes {
+			if node != msg.From {
+				return true
+			}
+		}
+		return false
+	case CorruptTerm, CorruptType:
+		return true
+	default:
+		return false
+	}
+}
+
+// Returns a copy of msg damaged by one of modes, chosen at random among
+// those that can alter it; an empty modes list allows all of them. nodes
+// are the identities a corrupted From field may be rewritten to. The
+// second result is false when no mode applies.
+func corruptMessage(msg consensus.Message, modes []CorruptionMode, nodes []string, rng *rand.Rand) (consensus.Message, CorruptionMode, bool) {
+	if len(modes) == 0 {
+		modes = AllCorruptionModes()
+	}
+
+	candidates := make([]CorruptionMode, 0, len(modes))
+	for _, mode := range modes {
+		if mode.applies(msg, nodes) {
+			candidates = append(candidates, mode)
+		}
+	}
+	if len(candidates) == 0 {
+		return msg, 0, false
+	}
+	mode := candidates[rng.IntN(len(candidates))]
+
+	corrupted := msg
+	corrupted.Data = append([]byte(nil), msg.Data...)
+	data := corrupted.Data
+
+	switch mode {
+	case CorruptBitFlip:
+		flips := 1 + rng.IntN(3)
+		for i := 0; i < flips; i++ {
+			bit := rng.IntN(len(data) * 8)
+			data[bit/8] ^= 1 << (bit % 8)
+		}
+	case CorruptTruncate:
+		corrupted.Data = data[:rng.IntN(len(data))]
+	case CorruptByteSwap:
+		for {
+			i, j := rng.IntN(len(data)), rng.IntN(len(data))
+			if data[i] != data[j] {
+				data[i], data[j] = data[j], data[i]
+				break
+			}
+		}
+	case CorruptTerm:
+		delta := 1 + rng.Int64N(5)
+		if rng.IntN(2) == 0 {
+			delta = -delta
+		}
+		corrupted.Term += delta
+	case CorruptFrom:
+		others := make([]string, 0, len(nodes))
+		for _, node := range nodes {
+			if node != msg.From {
+				others = append(others, node)
+			}
+		}
+		corrupted.From = others[rng.IntN(len(others))]
+	case CorruptType:
+		offset := 1 + rng.IntN(int(lastMessageType))
+		corrupted.Type = (msg.Type + consensus.MessageType(offset)) % (lastMessageType + 1)
+	}
+	return corrupted, mode, true
+}
@@ -0,0 +1,160 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"math/rand/v2"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/consensus"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/logging"
+)
+
+// Records the messages logged at warn level
+type recordingLogger struct {
+	logging.NoOpLogger
+	mu       sync.Mutex
+	warnings []string
+}
+
+func (r *recordingLogger) Warn(msg string, fields ...logging.Field) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.warnings = append(r.warnings, msg)
+}
+
+func (r *recordingLogger) With(fields ...logging.Field) logging.Logger    { return r }
+func (r *recordingLogger) WithContext(ctx context.Context) logging.Logger { return r }
+
+func TestCorruptionModeText(t *testing.T) {
+	for _, mode := range AllCorruptionModes() {
+		text, err := mode.MarshalText()
+		if err != nil {
+			t.Fatalf("MarshalText failed for %v: %v", mode, err)
+		}
+		var parsed CorruptionMode
+		if err := parsed.UnmarshalText(text); err != nil {
+			t.Fatalf("UnmarshalText failed for %s: %v", text, err)
+		}
+		if parsed != mode {
+			t.Errorf("Round trip of %v produced %v", mode, parsed)
+		}
+	}
+
+	var mode CorruptionMode
+	if err := mode.UnmarshalText([]byte("gamma_ray")); err == nil {
+		t.Error("Expected error for unknown mode")
+	}
+	if CorruptionMode(999).String() != "unknown" {
+		t.Error("Expected 'unknown' for an invalid mode")
+	}
+}
+
+func TestCorruptMessageModes(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	nodes := []string{"node-1", "node-2", "node-3"}
+	original := consensus.Message{
+		Type: consensus.MessageAppendEntries,
+		From: "node-1",
+		To:   "node-2",
+		Term: 7,
+		Data: []byte(`{"leader_id":"node-1","entries":[]}`),
+	}
+
+	for _, mode := range AllCorruptionModes() {
+		for i := 0; i < 20; i++ {
+			corrupted, applied, ok := corruptMessage(original, []CorruptionMode{mode}, nodes, rng)
+			if !ok || applied != mode {
+				t.Fatalf("Expected %v to apply, got %v (ok=%v)", mode, applied, ok)
+			}
+
+			switch mode {
+			case CorruptBitFlip, CorruptByteSwap:
+				if len(corrupted.Data) != len(original.Data) || bytes.Equal(corrupted.Data, original.Data) {
+					t.Errorf("%v should alter the payload in place", mode)
+				}
+			case CorruptTruncate:
+				if len(corrupted.Data) >= len(original.Data) {
+					t.Errorf("Truncation should shorten the payload, got %d bytes", len(corrupted.Data))
+				}
+			case CorruptTerm:
+				if corrupted.Term == original.Term {
+					t.Error("Term corruption should change the term")
+				}
+			case CorruptFrom:
+				if corrupted.From == original.From || corrupted.From == "" {
+					t.Errorf("From corruption produced %q", corrupted.From)
+				}
+			case CorruptType:
+				if corrupted.Type == original.Type || corrupted.Type > lastMessageType {
+					t.Errorf("Type corruption produced %v", corrupted.Type)
+				}
+			}
+		}
+	}
+
+	if string(original.Data) != `{"leader_id":"node-1","entries":[]}` {
+		t.Error("Corruption must not modify the original payload")
+	}
+}
+
+func TestCorruptMessageSkipsInapplicableModes(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	empty := consensus.Message{From: "node-1"}
+
+	if _, _, ok := corruptMessage(empty, []CorruptionMode{CorruptBitFlip, CorruptTruncate}, nil, rng); ok {
+		t.Error("Payload modes should not apply to an empty payload")
+	}
+
+	_, mode, ok := corruptMessage(empty, []CorruptionMode{CorruptBitFlip, CorruptTerm}, nil, rng)
+	if !ok || mode != CorruptTerm {
+		t.Errorf("Expected fallback to term corruption, got %v (ok=%v)", mode, ok)
+	}
+}
+
+func TestMemoryTransportCorruption(t *testing.T) {
+	sim := NewSimulator(1)
+	manager := NewSimulatedNetworkManager(sim)
+	logger := &recordingLogger{}
+	manager.SetLogger(logger)
+
+	node1 := manager.CreateNode("node-1")
+	node2 := manager.CreateNode("node-2")
+
+	// Duplication alone must not corrupt messages
+	conditions := DefaultNetworkConditions()
+	conditions.Duplication = 1.0
+	node1.SetConditions("node-1", "node-2", conditions)
+	node1.Send("node-2", consensus.Message{From: "node-1", Data: []byte("intact")})
+	sim.RunFor(time.Second)
+
+	for i := 0; i < 2; i++ {
+		msg := <-node2.Receive()
+		if string(msg.Data) != "intact" {
+			t.Errorf("Duplicated message was altered: %q", msg.Data)
+		}
+	}
+	if stats := node1.GetStats(); stats.MessagesCorrupted != 0 {
+		t.Errorf("Expected no corrupted messages, got %d", stats.MessagesCorrupted)
+	}
+
+	conditions = DefaultNetworkConditions()
+	conditions.Corruption = 1.0
+	conditions.CorruptionModes = []CorruptionMode{CorruptTruncate}
+	node1.SetConditions("node-1", "node-2", conditions)
+	node1.Send("node-2", consensus.Message{From: "node-1", Data: []byte("payload")})
+	sim.RunFor(time.Second)
+
+	msg := <-node2.Receive()
+	if len(msg.Data) >= len("payload") {
+		t.Errorf("Expected truncated payload, got %q", msg.Data)
+	}
+	if stats := node1.GetStats(); stats.MessagesCorrupted != 1 {
+		t.Errorf("Expected 1 corrupted message, got %d", stats.MessagesCorrupted)
+	}
+	if len(logger.warnings) != 1 || logger.warnings[0] != "corrupted message" {
+		t.Errorf("Expected corruption to be logged, got %v", logger.warnings)
+	}
+}
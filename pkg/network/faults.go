@@ -0,0 +1,138 @@
+package network
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Conditions of every directed link. Links without an override use the
+// table's defaults.
+type linkTable struct {
+	defaults  NetworkConditions
+	overrides map[Link]NetworkConditions
+	mu        sync.RWMutex
+}
+
+func newLinkTable() *linkTable {
+	return &linkTable{
+		defaults:  DefaultNetworkConditions(),
+		overrides: make(map[Link]NetworkConditions),
+	}
+}
+
+func (lt *linkTable) get(from, to string) NetworkConditions {
+	lt.mu.RLock()
+	defer lt.mu.RUnlock()
+
+	if conditions, exists := lt.overrides[Link{From: from, To: to}]; exists {
+		return conditions
+	}
+	return lt.defaults
+}
+
+func (lt *linkTable) set(from, to string, conditions NetworkConditions) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	lt.overrides[Link{From: from, To: to}] = conditions
+}
+
+// Replaces the defaults and drops every override
+func (lt *linkTable) reset(conditions NetworkConditions) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	lt.defaults = conditions
+	lt.overrides = make(map[Link]NetworkConditions)
+}
+
+// Name of the partition installed by Isolate
+func isolationPartition(nodeID string) string {
+	return "isolate/" + nodeID
+}
+
+// Partition splits the cluster into groups that cannot reach each other,
+// replacing the previous default partition. Nodes not listed in any group
+// form one more group.
+func (nm *NetworkManager) Partition(groups ...[]string) error {
+	for _, group := range groups {
+		if err := nm.checkNodes(group...); err != nil {
+			return err
+		}
+	}
+	return nm.topology.Add(Partition{Name: DefaultPartition, Groups: groups})
+}
+
+// Isolate cuts a node off from the rest of the cluster in both directions.
+// The isolation can be healed on its own with HealPartition.
+func (nm *NetworkManager) Isolate(nodeID string) error {
+	if err := nm.checkNodes(nodeID); err != nil {
+		return err
+	}
+	return nm.topology.Add(Partition{
+		Name:   isolationPartition(nodeID),
+		Groups: [][]string{{nodeID}},
+	})
+}
+
+// AddPartition installs a named partition across the cluster
+func (nm *NetworkManager) AddPartition(p Partition) error {
+	return nm.topology.Add(p)
+}
+
+// CutLink stops messages from one node to another, leaving the reverse
+// direction intact. The cut belongs to the named partition.
+func (nm *NetworkManager) CutLink(name, from, to string) error {
+	if err := nm.checkNodes(from, to); err != nil {
+		return err
+	}
+	return nm.topology.Cut(name, from, to)
+}
+
+// HealPartition removes a single named partition
+func (nm *NetworkManager) HealPartition(name string) error {
+	return nm.topology.Heal(name)
+}
+
+// Heal removes every partition. Link conditions are left in place.
+func (nm *NetworkManager) Heal() {
+	nm.topology.HealAll()
+}
+
+// Partitions returns the active partitions ordered by name
+func (nm *NetworkManager) Partitions() []Partition {
+	return nm.topology.Partitions()
+}
+
+// SetLink sets the conditions of the link from one node to another
+func (nm *NetworkManager) SetLink(from, to string, conditions NetworkConditions) error {
+	if err := nm.checkNodes(from, to); err != nil {
+		return err
+	}
+	nm.links.set(from, to, conditions)
+	return nil
+}
+
+// GetLink returns the conditions of the link from one node to another
+func (nm *NetworkManager) GetLink(from, to string) NetworkConditions {
+	return nm.links.get(from, to)
+}
+
+// SetAllLinks applies conditions to every link, including links to nodes
+// created later, and drops any per-link conditions
+func (nm *NetworkManager) SetAllLinks(conditions NetworkConditions) {
+	nm.links.reset(conditions)
+}
+
+// Returns an error naming the first node that is not part of the cluster
+func (nm *NetworkManager) checkNodes(nodes ...string) error {
+	nm.mu.RLock()
+	defer nm.mu.RUnlock()
+
+	for _, nodeID := range nodes {
+		if _, exists := nm.transports[nodeID]; !exists {
+			return fmt.Errorf("node %s not found", nodeID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/consensus"
+)
+
+// Creates a simulated cluster and counts deliveries per link
+func faultCluster(nodes ...string) (*Simulator, *NetworkManager, map[Link]int) {
+	sim := NewSimulator(1)
+	manager := NewSimulatedNetworkManager(sim)
+	delivered := make(map[Link]int)
+	for _, nodeID := range nodes {
+		nodeID := nodeID
+		transport := manager.CreateNode(nodeID).(*MemoryTransport)
+		transport.OnReceive(func(msg consensus.Message) {
+			delivered[Link{From: msg.From, To: nodeID}]++
+		})
+	}
+	return sim, manager, delivered
+}
+
+// Sends one message over every link and runs the simulation
+func sendAll(sim *Simulator, manager *NetworkManager) {
+	for _, from := range manager.GetAllNodes() {
+		transport, _ := manager.GetNode(from)
+		transport.Broadcast(consensus.Message{From: from})
+	}
+	sim.RunFor(time.Second)
+}
+
+func TestNetworkManagerPartition(t *testing.T) {
+	sim, manager, delivered := faultCluster("a", "b", "c", "d", "e")
+
+	if err := manager.Partition([]string{"a", "b"}, []string{"c", "d"}); err != nil {
+		t.Fatalf("Partition failed: %v", err)
+	}
+	sendAll(sim, manager)
+
+	for _, link := range []Link{{"a", "b"}, {"b", "a"}, {"c", "d"}, {"d", "c"}} {
+		if delivered[link] != 1 {
+			t.Errorf("Expected %s to deliver within its group", link)
+		}
+	}
+	for _, link := range []Link{{"a", "c"}, {"d", "b"}, {"e", "a"}, {"c", "e"}} {
+		if delivered[link] != 0 {
+			t.Errorf("Expected %s to be cut", link)
+		}
+	}
+
+	manager.Heal()
+	sendAll(sim, manager)
+	if delivered[Link{"a", "c"}] != 1 || delivered[Link{"e", "d"}] != 1 {
+		t.Errorf("Expected every link to deliver after Heal, got %v", delivered)
+	}
+
+	if err := manager.Partition([]string{"a"}, []string{"x"}); err == nil {
+		t.Error("Expected error partitioning an unknown node")
+	}
+}
+
+func TestNetworkManagerIsolate(t *testing.T) {
+	sim, manager, delivered := faultCluster("a", "b", "c")
+
+	if err := manager.Isolate("a"); err != nil {
+		t.Fatalf("Isolate failed: %v", err)
+	}
+	if err := manager.CutLink("one-way", "b", "c"); err != nil {
+		t.Fatalf("CutLink failed: %v", err)
+	}
+	sendAll(sim, manager)
+	if delivered[Link{"a", "b"}] != 0 || delivered[Link{"c", "a"}] != 0 {
+		t.Error("Expected a to be isolated in both directions")
+	}
+	if delivered[Link{"b", "c"}] != 0 || delivered[Link{"c", "b"}] != 1 {
+		t.Error("Expected only b->c to be cut between b and c")
+	}
+
+	if err := manager.HealPartition(isolationPartition("a")); err != nil {
+		t.Fatalf("HealPartition failed: %v", err)
+	}
+	sendAll(sim, manager)
+	if delivered[Link{"a", "b"}] != 1 {
+		t.Error("Expected a to rejoin after its isolation is healed")
+	}
+	if delivered[Link{"b", "c"}] != 0 {
+		t.Error("Healing the isolation should leave the one-way cut in place")
+	}
+
+	if err := manager.Isolate("x"); err == nil {
+		t.Error("Expected error isolating an unknown node")
+	}
+}
+
+func TestNetworkManagerSetLink(t *testing.T) {
+	sim, manager, delivered := faultCluster("a", "b")
+
+	lossy := DefaultNetworkConditions()
+	lossy.PacketLoss = 1.0
+	if err := manager.SetLink("a", "b", lossy); err != nil {
+		t.Fatalf("SetLink failed: %v", err)
+	}
+
+	// Conditions survive nodes joining the cluster
+	manager.CreateNode("c")
+	if manager.GetLink("a", "b").PacketLoss != 1.0 {
+		t.Fatal("Expected link conditions to persist when a node joins")
+	}
+
+	// Every transport sees the same state
+	transport, _ := manager.GetNode("b")
+	if transport.GetConditions("a", "b").PacketLoss != 1.0 {
+		t.Error("Expected transports to share the manager's link conditions")
+	}
+
+	sendAll(sim, manager)
+	if delivered[Link{"a", "b"}] != 0 || delivered[Link{"b", "a"}] != 1 {
+		t.Errorf("Expected only a->b to drop, got %v", delivered)
+	}
+
+	manager.SetAllLinks(lossy)
+	if manager.GetLink("c", "a").PacketLoss != 1.0 {
+		t.Error("Expected SetAllLinks to apply to every link")
+	}
+
+	if err := manager.SetLink("a", "x", lossy); err == nil {
+		t.Error("Expected error setting a link to an unknown node")
+	}
+}
+
+func TestTransportPartitionIsClusterWide(t *testing.T) {
+	sim, manager, delivered := faultCluster("a", "b")
+
+	// A partition created through one transport affects every sender
+	transport, _ := manager.GetNode("a")
+	transport.CreatePartition([]string{"a"})
+	sendAll(sim, manager)
+	if delivered[Link{"b", "a"}] != 0 {
+		t.Error("Expected b->a to be cut by a partition created on a's transport")
+	}
+	if len(manager.Partitions()) != 1 {
+		t.Errorf("Expected the manager to report 1 partition, got %d", len(manager.Partitions()))
+	}
+}
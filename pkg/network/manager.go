@@ -2,17 +2,85 @@ package network
 
 import (
 	"fmt"
+	"sort"
 	"sync"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/clock"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/consensus"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/logging"
 )
 
 type NetworkManager struct {
 	transports map[string]*MemoryTransport
+	clocks     map[string]*nodeClock
+	links      *linkTable // conditions of every link
+	topology   *Topology  // partitions across the cluster
+	clock      clock.Clock
+	logger     logging.Logger
+	observer   func(consensus.Message)
+	sim        *Simulator // nil unless running a simulation
 	mu         sync.RWMutex
 }
 
 func NewNetworkManager() *NetworkManager {
+	return NewNetworkManagerWithClock(clock.Real())
+}
+
+// Creates a manager whose transports measure latency on clk
+func NewNetworkManagerWithClock(clk clock.Clock) *NetworkManager {
+	return &NetworkManager{
+		transports: make(map[string]*MemoryTransport),
+		clocks:     make(map[string]*nodeClock),
+		links:      newLinkTable(),
+		topology:   NewTopology(),
+		clock:      clk,
+		logger:     logging.NewNoOpLogger(),
+	}
+}
+
+// Creates a manager whose transports deliver messages as events on sim
+func NewSimulatedNetworkManager(sim *Simulator) *NetworkManager {
 	return &NetworkManager{
 		transports: make(map[string]*MemoryTransport),
+		clocks:     make(map[string]*nodeClock),
+		links:      newLinkTable(),
+		topology:   NewTopology(),
+		clock:      sim,
+		logger:     logging.NewNoOpLogger(),
+		sim:        sim,
+	}
+}
+
+// Clock returns the clock the network runs on
+func (nm *NetworkManager) Clock() clock.Clock {
+	return nm.clock
+}
+
+// Simulator returns the simulator driving the network, or nil
+func (nm *NetworkManager) Simulator() *Simulator {
+	return nm.sim
+}
+
+// SetLogger sets the logger every transport records injected faults to
+func (nm *NetworkManager) SetLogger(logger logging.Logger) {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	nm.logger = logger
+	for _, transport := range nm.transports {
+		transport.SetLogger(logger)
+	}
+}
+
+// SetObserver sets a function that sees every message delivered to any
+// node, including nodes created later
+func (nm *NetworkManager) SetObserver(observer func(consensus.Message)) {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	nm.observer = observer
+	for _, transport := range nm.transports {
+		transport.SetObserver(observer)
 	}
 }
 
@@ -20,9 +88,22 @@ func (nm *NetworkManager) CreateNode(nodeID string) NetworkTransport {
 	nm.mu.Lock()
 	defer nm.mu.Unlock()
 
-	transport := NewMemoryTransport(nodeID)
+	var transport *MemoryTransport
+	if nm.sim != nil {
+		transport = NewSimulatedTransport(nodeID, nm.sim)
+	} else {
+		transport = NewMemoryTransportWithClock(nodeID, nm.clock)
+	}
+	transport.SetLogger(nm.logger)
+	transport.SetObserver(nm.observer)
+	transport.share(nm.links, nm.topology)
 	nm.transports[nodeID] = transport
 
+	if _, exists := nm.clocks[nodeID]; !exists {
+		skewed, _ := clock.NewSkewed(nm.clock, 0, 0)
+		nm.clocks[nodeID] = &nodeClock{clock: skewed}
+	}
+
 	transport.Connect(nm.transports)
 
 	for _, existingTrasport := range nm.transports {
@@ -43,6 +124,10 @@ func (nm *NetworkManager) RemoveNode(nodeID string) error {
 
 	transport.Close()
 	delete(nm.transports, nodeID)
+	if nc, exists := nm.clocks[nodeID]; exists {
+		nc.stopJumps()
+		delete(nm.clocks, nodeID)
+	}
 
 	for _, existingTransport := range nm.transports {
 		existingTransport.Connect(nm.transports)
@@ -71,6 +156,7 @@ func (nm *NetworkManager) GetAllNodes() []string {
 	for nodeID := range nm.transports {
 		nodes = append(nodes, nodeID)
 	}
+	sort.Strings(nodes)
 	return nodes
 }
 
@@ -82,5 +168,9 @@ func (nm *NetworkManager) Shutdown() error {
 		transport.Close()
 	}
 	nm.transports = make(map[string]*MemoryTransport)
+	for _, nc := range nm.clocks {
+		nc.stopJumps()
+	}
+	nm.clocks = make(map[string]*nodeClock)
 	return nil
 }
@@ -2,6 +2,7 @@ package network
 
 import (
 	"testing"
+	"time"
 )
 
 func TestNetworkManagerCreation(t *testing.T) {
@@ -116,3 +117,53 @@ func TestNetworkManagerShutdown(t *testing.T) {
 		t.Errorf("Expected 0 nodes after shutdown, got %d", len(nodes))
 	}
 }
+
+func TestNetworkManagerClockConditions(t *testing.T) {
+	sim := NewSimulator(1)
+	manager := NewSimulatedNetworkManager(sim)
+	manager.CreateNode("node-1")
+	manager.CreateNode("node-2")
+
+	if _, err := manager.NodeClock("node-3"); err == nil {
+		t.Error("NodeClock should fail for an unknown node")
+	}
+
+	err := manager.SetClockConditions("node-1", ClockConditions{
+		Offset: time.Second,
+		Drift:  0.1,
+		Jumps: []ClockJump{
+			{After: 10 * time.Second, By: -time.Minute},
+		},
+	})
+	if err != nil {
+		t.Fatalf("SetClockConditions failed: %v", err)
+	}
+
+	skewed, _ := manager.NodeClock("node-1")
+	accurate, _ := manager.NodeClock("node-2")
+
+	sim.RunFor(5 * time.Second)
+	if got := skewed.Now().Sub(sim.Now()); got != 1500*time.Millisecond {
+		t.Errorf("Expected node-1 1.5s ahead, got %v", got)
+	}
+	if !accurate.Now().Equal(sim.Now()) {
+		t.Error("node-2 clock should be accurate")
+	}
+
+	sim.RunFor(5 * time.Second)
+	if got := skewed.Now().Sub(sim.Now()); got != 2*time.Second-time.Minute {
+		t.Errorf("Expected node-1 58s behind after jump, got %v", got)
+	}
+
+	conditions, err := manager.GetClockConditions("node-1")
+	if err != nil {
+		t.Fatalf("GetClockConditions failed: %v", err)
+	}
+	if conditions.Drift < 0.099 || conditions.Drift > 0.101 {
+		t.Errorf("Expected drift 0.1, got %v", conditions.Drift)
+	}
+
+	if err := manager.SetClockConditions("node-2", ClockConditions{Drift: -2}); err == nil {
+		t.Error("Expected error for invalid drift")
+	}
+}
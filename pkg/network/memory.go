@@ -3,10 +3,13 @@ package network
 import (
 	"fmt"
 	"math/rand/v2"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/francisco-teixeirax86/consensusforge/pkg/clock"
 	"github.com/francisco-teixeirax86/consensusforge/pkg/consensus"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/logging"
 )
 
 // Implements the NetworkTransport for in-memory testing
@@ -14,24 +17,56 @@ type MemoryTransport struct {
 	nodeID     string
 	nodes      map[string]*MemoryTransport
 	inbox      chan consensus.Message
-	conditions map[string]NetworkConditions // from -> to
-	partitions map[string]bool              // partitioned nodes
+	handler    func(consensus.Message)
+	observer   func(consensus.Message) // sees every message delivered here
+	conditions *linkTable              // shared with the manager's other transports
+	topology   *Topology               // shared with the manager's other transports
+	sequence   map[string]int64        // last sequence number sent per destination
+	delivered  map[string]int64        // highest sequence number delivered per destination
+	shapers    map[string]*linkShaper  // bandwidth state per destination
+	queueDelay time.Duration           // total time messages spent queued for bandwidth
+	queued     int64                   // messages that passed through a shaper
 	stats      NetworkStats
+	clock      clock.Clock
+	rng        *rand.Rand
+	logger     logging.Logger
 	mu         sync.RWMutex
 	closed     bool
 }
 
 // Creates a new in-memory transport
 func NewMemoryTransport(nodeID string) *MemoryTransport {
+	return NewMemoryTransportWithClock(nodeID, clock.Real())
+}
+
+// Creates an in-memory transport whose latency elapses on clk
+func NewMemoryTransportWithClock(nodeID string, clk clock.Clock) *MemoryTransport {
+	return newMemoryTransport(nodeID, clk, rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())))
+}
+
+// Creates an in-memory transport whose deliveries are events on sim.
+// Latency elapses in virtual time and every random decision is drawn
+// from a stream seeded by the simulator.
+func NewSimulatedTransport(nodeID string, sim *Simulator) *MemoryTransport {
+	return newMemoryTransport(nodeID, sim, sim.NewRand("transport/"+nodeID))
+}
+
+func newMemoryTransport(nodeID string, clk clock.Clock, rng *rand.Rand) *MemoryTransport {
 	return &MemoryTransport{
 		nodeID:     nodeID,
 		nodes:      make(map[string]*MemoryTransport),
 		inbox:      make(chan consensus.Message, 1000),
-		conditions: make(map[string]NetworkConditions),
-		partitions: make(map[string]bool),
+		conditions: newLinkTable(),
+		topology:   NewTopology(),
+		sequence:   make(map[string]int64),
+		delivered:  make(map[string]int64),
+		shapers:    make(map[string]*linkShaper),
 		stats: NetworkStats{
 			NodeStats: make(map[string]NodeStats),
 		},
+		clock:  clk,
+		rng:    rng,
+		logger: logging.NewNoOpLogger(),
 	}
 }
 
@@ -40,44 +75,46 @@ func (mt *MemoryTransport) Connect(nodes map[string]*MemoryTransport) {
 	mt.mu.Lock()
 	defer mt.mu.Unlock()
 
-	mt.nodes = nodes
-	// Set default conditions for all nodes
-	for nodeID := range nodes {
-		if nodeID != mt.nodeID {
-			key := fmt.Sprintf("%s->%s", mt.nodeID, nodeID)
-			mt.conditions[key] = DefaultNetworkConditions()
-		}
+	mt.nodes = make(map[string]*MemoryTransport, len(nodes))
+	for nodeID, node := range nodes {
+		mt.nodes[nodeID] = node
 	}
 }
 
+// Makes the transport consult fault state shared across a cluster
+func (mt *MemoryTransport) share(conditions *linkTable, topology *Topology) {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	mt.conditions = conditions
+	mt.topology = topology
+}
+
 func (mt *MemoryTransport) Send(to string, msg consensus.Message) error {
-	mt.mu.RLock()
+	mt.mu.Lock()
 	if mt.closed {
-		mt.mu.RUnlock()
+		mt.mu.Unlock()
 		return fmt.Errorf("transport closed")
 	}
 
 	target, exists := mt.nodes[to]
 	if !exists {
-		mt.mu.RUnlock()
+		mt.mu.Unlock()
 		return fmt.Errorf("node %s not found", to)
 	}
 
 	// Check if nodes are partitioned
-	if mt.partitions[mt.nodeID] != mt.partitions[to] &&
-		(mt.partitions[mt.nodeID] || mt.partitions[to]) {
+	if mt.topology.Blocked(mt.nodeID, to) {
 		mt.stats.MessagesDropped++
-		mt.mu.RUnlock()
+		mt.mu.Unlock()
 		return nil // silently drop
 	}
 
-	key := fmt.Sprintf("%s->%s", mt.nodeID, to)
-	conditions := mt.conditions[key]
-	mt.mu.RUnlock()
+	conditions := mt.conditions.get(mt.nodeID, to)
 
-	// Aplly network conditions
-	if rand.Float64() < conditions.PacketLoss {
-		mt.mu.Lock()
+	// Aplly network conditions. Every random decision is made here, in
+	// send order, so a seeded transport behaves reproducibly.
+	if mt.rng.Float64() < conditions.PacketLoss {
 		mt.stats.MessagesDropped++
 		mt.mu.Unlock()
 		return nil // packet loss
@@ -85,55 +122,142 @@ func (mt *MemoryTransport) Send(to string, msg consensus.Message) error {
 
 	// Calculate latency
 	latency := conditions.BaseLatency
+	if conditions.Bandwidth > 0 {
+		shaper, exists := mt.shapers[to]
+		if !exists {
+			shaper = &linkShaper{}
+			mt.shapers[to] = shaper
+		}
+		delay, dropped := shaper.admit(mt.clock.Now(), messageSize(msg), conditions)
+		if dropped {
+			mt.stats.MessagesDropped++
+			mt.stats.MessagesTailDrop++
+			mt.mu.Unlock()
+			return nil // queue overflow
+		}
+		latency += delay
+		mt.queueDelay += delay
+		mt.queued++
+		mt.stats.AverageQueueDelay = mt.queueDelay / time.Duration(mt.queued)
+	}
 	if conditions.LatencyJitter > 0 {
-		jitter := time.Duration(rand.Int64N(int64(conditions.LatencyJitter)))
+		jitter := time.Duration(mt.rng.Int64N(int64(conditions.LatencyJitter)))
 		latency += jitter
 	}
-
-	go func() {
-		if latency > 0 {
-			time.Sleep(latency)
-		}
-
-		finalMsg := msg
-		if rand.Float64() < conditions.Duplication {
-			finalMsg.Data = []byte("corrupted")
-			mt.mu.Lock()
+	if conditions.ReorderWindow > 0 && mt.rng.Float64() < conditions.Reorder {
+		latency += 1 + time.Duration(mt.rng.Int64N(int64(conditions.ReorderWindow)))
+	}
+	mt.sequence[to]++
+	seq := mt.sequence[to]
+
+	finalMsg := msg
+	if mt.rng.Float64() < conditions.Corruption {
+		corrupted, mode, ok := corruptMessage(msg, conditions.CorruptionModes, mt.knownNodes(), mt.rng)
+		if ok {
+			finalMsg = corrupted
 			mt.stats.MessagesCorrupted++
-			mt.mu.Unlock()
+			mt.logger.Warn("corrupted message",
+				logging.String("from", mt.nodeID),
+				logging.String("to", to),
+				logging.String("mode", mode.String()),
+				logging.Int("type", int(msg.Type)),
+				logging.Int("original_size", len(msg.Data)),
+				logging.Int("corrupted_size", len(finalMsg.Data)))
 		}
+	}
+	duplicate := mt.rng.Float64() < conditions.Duplication
+	mt.mu.Unlock()
 
+	mt.clock.AfterFunc(latency, func() {
 		// Deliver message
-		select {
-		case target.inbox <- finalMsg:
+		if target.deliver(finalMsg) {
 			mt.mu.Lock()
 			mt.stats.MessagesSent++
 			nodeStats := mt.stats.NodeStats[to]
 			nodeStats.Sent++
 			mt.stats.NodeStats[to] = nodeStats
+			if seq < mt.delivered[to] {
+				mt.stats.MessagesReordered++
+			} else {
+				mt.delivered[to] = seq
+			}
 			mt.mu.Unlock()
-
-		default:
-			// inbox is full, drop message
+		} else {
+			// inbox is full or closed, drop message
 			mt.mu.Lock()
 			mt.stats.MessagesDropped++
 			mt.mu.Unlock()
 		}
 
-		if rand.Float64() < conditions.Duplication {
-			select {
-			case target.inbox <- finalMsg:
+		if duplicate {
+			if target.deliver(finalMsg) {
 				mt.mu.Lock()
 				mt.stats.MessagesDuplicated++
 				mt.mu.Unlock()
-			default:
 			}
 		}
-	}()
+	})
 
 	return nil
 }
 
+// Returns the IDs of every connected node in sorted order
+func (mt *MemoryTransport) knownNodes() []string {
+	nodes := make([]string, 0, len(mt.nodes))
+	for nodeID := range mt.nodes {
+		nodes = append(nodes, nodeID)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// SetLogger sets the logger that records injected faults
+func (mt *MemoryTransport) SetLogger(logger logging.Logger) {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	mt.logger = logger.With(logging.String("node_id", mt.nodeID))
+}
+
+// Places a message in the inbox without blocking, or hands it to the
+// receive callback when one is set. Reports whether it was accepted.
+func (mt *MemoryTransport) deliver(msg consensus.Message) bool {
+	mt.mu.RLock()
+	if mt.closed {
+		mt.mu.RUnlock()
+		return false
+	}
+	observer := mt.observer
+	if handler := mt.handler; handler != nil {
+		mt.mu.RUnlock()
+		if observer != nil {
+			observer(msg)
+		}
+		handler(msg)
+		return true
+	}
+	defer mt.mu.RUnlock()
+
+	select {
+	case mt.inbox <- msg:
+		if observer != nil {
+			observer(msg)
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// SetObserver sets a function that sees every message delivered to this
+// transport, just before the node receives it
+func (mt *MemoryTransport) SetObserver(observer func(consensus.Message)) {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	mt.observer = observer
+}
+
 // Broadcast Implements the consensus.Transport
 func (mt *MemoryTransport) Broadcast(msg consensus.Message) error {
 	mt.mu.RLock()
@@ -150,6 +274,9 @@ func (mt *MemoryTransport) Broadcast(msg consensus.Message) error {
 	}
 	mt.mu.RUnlock()
 
+	// Sorted so that seeded runs draw randomness in a stable order
+	sort.Strings(nodes)
+
 	for _, nodeID := range nodes {
 		if err := mt.Send(nodeID, msg); err != nil {
 			return err
@@ -163,6 +290,16 @@ func (mt *MemoryTransport) Receive() <-chan consensus.Message {
 	return mt.inbox
 }
 
+// OnReceive Implements consensus.CallbackTransport. Once a handler is set,
+// messages bypass the Receive channel; in simulation mode the handler runs
+// on the simulator's goroutine.
+func (mt *MemoryTransport) OnReceive(handler func(consensus.Message)) {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	mt.handler = handler
+}
+
 // Close Implements the consensus.Transport
 func (mt *MemoryTransport) Close() error {
 	mt.mu.Lock()
@@ -175,53 +312,96 @@ func (mt *MemoryTransport) Close() error {
 	return nil
 }
 
+// SetConditions sets the conditions of a link. Transports created by a
+// NetworkManager share link conditions, so this affects the whole cluster.
 func (mt *MemoryTransport) SetConditions(from, to string, conditions NetworkConditions) {
-	mt.mu.Lock()
-	defer mt.mu.Unlock()
-
-	key := fmt.Sprintf("%s->%s", from, to)
-	mt.conditions[key] = conditions
+	mt.conditions.set(from, to, conditions)
 }
 
 func (mt *MemoryTransport) GetConditions(from, to string) NetworkConditions {
-	mt.mu.RLock()
-	defer mt.mu.RUnlock()
-
-	key := fmt.Sprintf("%s->%s", from, to)
-	if conditions, exists := mt.conditions[key]; exists {
-		return conditions
-	}
-	return DefaultNetworkConditions()
+	return mt.conditions.get(from, to)
 }
 
+// CreatePartition adds nodes to the default partition, which cuts them
+// off from every node outside it in both directions. Transports created by
+// a NetworkManager share partitions, so this affects the whole cluster.
 func (mt *MemoryTransport) CreatePartition(nodes []string) error {
 	mt.mu.Lock()
 	defer mt.mu.Unlock()
 
+	p, _ := mt.topology.Get(DefaultPartition)
+	members := []string{}
+	if len(p.Groups) > 0 {
+		members = p.Groups[0]
+	}
 	for _, nodeID := range nodes {
-		mt.partitions[nodeID] = true
+		if !containsNode(members, nodeID) {
+			members = append(members, nodeID)
+		}
 	}
-	return nil
+	if len(members) == 0 {
+		return nil
+	}
+	return mt.topology.Add(Partition{Name: DefaultPartition, Groups: [][]string{members}})
 }
 
+// RemovePartition takes nodes out of the default partition
 func (mt *MemoryTransport) RemovePartition(nodes []string) error {
 	mt.mu.Lock()
 	defer mt.mu.Unlock()
 
-	for _, nodeID := range nodes {
-		delete(mt.partitions, nodeID)
+	p, exists := mt.topology.Get(DefaultPartition)
+	if !exists {
+		return nil
 	}
-	return nil
+	members := []string{}
+	for _, nodeID := range p.Groups[0] {
+		if !containsNode(nodes, nodeID) {
+			members = append(members, nodeID)
+		}
+	}
+	if len(members) == 0 {
+		return mt.topology.Heal(DefaultPartition)
+	}
+	return mt.topology.Add(Partition{Name: DefaultPartition, Groups: [][]string{members}})
 }
 
+// ClearPartitions heals every partition
 func (mt *MemoryTransport) ClearPartitions() error {
-	mt.mu.Lock()
-	defer mt.mu.Unlock()
-
-	mt.partitions = make(map[string]bool)
+	mt.topology.HealAll()
 	return nil
 }
 
+// AddPartition installs a named partition, replacing one with the same name
+func (mt *MemoryTransport) AddPartition(p Partition) error {
+	return mt.topology.Add(p)
+}
+
+// CutLink stops messages from one node to another, leaving the reverse
+// direction intact. The cut belongs to the named partition.
+func (mt *MemoryTransport) CutLink(name, from, to string) error {
+	return mt.topology.Cut(name, from, to)
+}
+
+// HealPartition removes a single named partition
+func (mt *MemoryTransport) HealPartition(name string) error {
+	return mt.topology.Heal(name)
+}
+
+// Partitions returns the active partitions ordered by name
+func (mt *MemoryTransport) Partitions() []Partition {
+	return mt.topology.Partitions()
+}
+
+func containsNode(nodes []string, nodeID string) bool {
+	for _, node := range nodes {
+		if node == nodeID {
+			return true
+		}
+	}
+	return false
+}
+
 func (mt *MemoryTransport) GetStats() NetworkStats {
 	mt.mu.RLock()
 	defer mt.mu.RUnlock()
@@ -241,4 +421,6 @@ func (mt *MemoryTransport) ResetStats() {
 	mt.stats = NetworkStats{
 		NodeStats: make(map[string]NodeStats),
 	}
+	mt.queueDelay = 0
+	mt.queued = 0
 }
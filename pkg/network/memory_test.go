@@ -230,3 +230,68 @@ func TestMemoryTransportPartition(t *testing.T) {
 		t.Fatal("Message should have been received after partition removal")
 	}
 }
+
+// Sends count messages a millisecond apart and returns the delivery order
+func simulatedLinkOrder(conditions NetworkConditions, count int) ([]int, NetworkStats) {
+	sim := NewSimulator(3)
+	manager := NewSimulatedNetworkManager(sim)
+	node1 := manager.CreateNode("node-1")
+	node2 := manager.CreateNode("node-2").(*MemoryTransport)
+	node1.SetConditions("node-1", "node-2", conditions)
+
+	var order []int
+	node2.OnReceive(func(msg consensus.Message) {
+		order = append(order, int(msg.Term))
+	})
+
+	for i := 0; i < count; i++ {
+		node1.Send("node-2", consensus.Message{From: "node-1", Term: int64(i)})
+		sim.RunFor(time.Millisecond)
+	}
+	sim.RunFor(time.Second)
+	return order, node1.GetStats()
+}
+
+func TestMemoryTransportReordering(t *testing.T) {
+	conditions := DefaultNetworkConditions()
+
+	order, stats := simulatedLinkOrder(conditions, 100)
+	for i, term := range order {
+		if term != i {
+			t.Fatalf("Link without jitter or reordering should be FIFO, got %v", order)
+		}
+	}
+	if stats.MessagesReordered != 0 {
+		t.Errorf("Expected no reordered messages, got %d", stats.MessagesReordered)
+	}
+
+	conditions.Reorder = 0.3
+	conditions.ReorderWindow = 10 * time.Millisecond
+	order, stats = simulatedLinkOrder(conditions, 100)
+	if len(order) != 100 {
+		t.Fatalf("Reordering should not lose messages, got %d", len(order))
+	}
+
+	// Count messages that arrived after a message sent later
+	reordered, highest := int64(0), -1
+	for _, term := range order {
+		if term < highest {
+			reordered++
+		} else {
+			highest = term
+		}
+	}
+	if reordered == 0 {
+		t.Error("Expected some messages to be reordered")
+	}
+	if stats.MessagesReordered != reordered {
+		t.Errorf("Expected %d reordered messages in stats, got %d", reordered, stats.MessagesReordered)
+	}
+
+	// A zero window disables reordering
+	conditions.ReorderWindow = 0
+	_, stats = simulatedLinkOrder(conditions, 100)
+	if stats.MessagesReordered != 0 {
+		t.Errorf("Expected no reordering with a zero window, got %d", stats.MessagesReordered)
+	}
+}
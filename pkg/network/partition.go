@@ -0,0 +1,211 @@
+package network
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Name of the partition managed by CreatePartition and RemovePartition
+const DefaultPartition = "default"
+
+// A directed link between two nodes
+type Link struct {
+	From string `yaml:"from" json:"from"`
+	To   string `yaml:"to" json:"to"`
+}
+
+func (l Link) String() string {
+	return fmt.Sprintf("%s->%s", l.From, l.To)
+}
+
+// A named set of link cuts that can be healed as a unit
+type Partition struct {
+	Name string `yaml:"name" json:"name"`
+
+	// Nodes in different groups cannot reach each other in either
+	// direction. Nodes not listed in any group form one more group.
+	Groups [][]string `yaml:"groups,omitempty" json:"groups,omitempty"`
+
+	// One-way cuts: From can no longer reach To, while To may still
+	// reach From unless that link is cut too
+	Cuts []Link `yaml:"cuts,omitempty" json:"cuts,omitempty"`
+}
+
+// Blocks reports whether the partition stops messages from one node to another
+func (p Partition) Blocks(from, to string) bool {
+	return compilePartition(p).blocks(from, to)
+}
+
+// Partition with lookups precomputed
+type compiledPartition struct {
+	spec  Partition
+	group map[string]int // node -> group number, absent for unlisted nodes
+	cuts  map[Link]bool
+}
+
+func compilePartition(p Partition) compiledPartition {
+	cp := compiledPartition{
+		spec:  p,
+		group: make(map[string]int),
+		cuts:  make(map[Link]bool, len(p.Cuts)),
+	}
+	for i, group := range p.Groups {
+		for _, node := range group {
+			cp.group[node] = i + 1
+		}
+	}
+	for _, cut := range p.Cuts {
+		cp.cuts[cut] = true
+	}
+	return cp
+}
+
+func (cp compiledPartition) blocks(from, to string) bool {
+	if from == to {
+		return false
+	}
+	if cp.cuts[Link{From: from, To: to}] {
+		return true
+	}
+	if len(cp.spec.Groups) == 0 {
+		return false
+	}
+	return cp.group[from] != cp.group[to]
+}
+
+// Validates a partition before it is installed
+func (p Partition) validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("partition name is required")
+	}
+
+	seen := make(map[string]int)
+	for i, group := range p.Groups {
+		if len(group) == 0 {
+			return fmt.Errorf("partition %s: group %d is empty", p.Name, i)
+		}
+		for _, node := range group {
+			if previous, exists := seen[node]; exists && previous != i {
+				return fmt.Errorf("partition %s: node %s appears in groups %d and %d", p.Name, node, previous, i)
+			}
+			seen[node] = i
+		}
+	}
+	for _, cut := range p.Cuts {
+		if cut.From == "" || cut.To == "" || cut.From == cut.To {
+			return fmt.Errorf("partition %s: invalid cut %s", p.Name, cut)
+		}
+	}
+	return nil
+}
+
+// Holds the active partitions. A message is blocked when any active
+// partition blocks its link.
+type Topology struct {
+	partitions map[string]compiledPartition
+	mu         sync.RWMutex
+}
+
+// Creates a topology with no partitions
+func NewTopology() *Topology {
+	return &Topology{
+		partitions: make(map[string]compiledPartition),
+	}
+}
+
+// Add installs a partition, replacing any partition with the same name
+func (t *Topology) Add(p Partition) error {
+	if err := p.validate(); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.partitions[p.Name] = compilePartition(clonePartition(p))
+	return nil
+}
+
+// Cut adds a one-way cut to the named partition, creating it if needed
+func (t *Topology) Cut(name, from, to string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p := t.partitions[name].spec
+	p.Name = name
+	p = clonePartition(p)
+	p.Cuts = append(p.Cuts, Link{From: from, To: to})
+	if err := p.validate(); err != nil {
+		return err
+	}
+	t.partitions[name] = compilePartition(p)
+	return nil
+}
+
+// Heal removes the named partition
+func (t *Topology) Heal(name string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, exists := t.partitions[name]; !exists {
+		return fmt.Errorf("partition %s not found", name)
+	}
+	delete(t.partitions, name)
+	return nil
+}
+
+// HealAll removes every partition
+func (t *Topology) HealAll() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.partitions = make(map[string]compiledPartition)
+}
+
+// Blocked reports whether any partition stops messages from one node to another
+func (t *Topology) Blocked(from, to string) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for _, cp := range t.partitions {
+		if cp.blocks(from, to) {
+			return true
+		}
+	}
+	return false
+}
+
+// Get returns the named partition
+func (t *Topology) Get(name string) (Partition, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	cp, exists := t.partitions[name]
+	if !exists {
+		return Partition{}, false
+	}
+	return clonePartition(cp.spec), true
+}
+
+// Partitions returns the active partitions ordered by name
+func (t *Topology) Partitions() []Partition {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	partitions := make([]Partition, 0, len(t.partitions))
+	for _, cp := range t.partitions {
+		partitions = append(partitions, clonePartition(cp.spec))
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i].Name < partitions[j].Name })
+	return partitions
+}
+
+func clonePartition(p Partition) Partition {
+	clone := Partition{Name: p.Name}
+	for _, group := range p.Groups {
+		clone.Groups = append(clone.Groups, append([]string(nil), group...))
+	}
+	clone.Cuts = append([]Link(nil), p.Cuts...)
+	return clone
+}
@@ -0,0 +1,161 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/consensus"
+)
+
+func TestPartitionGroups(t *testing.T) {
+	p := Partition{
+		Name:   "split",
+		Groups: [][]string{{"a", "b"}, {"c"}},
+	}
+
+	cases := []struct {
+		from, to string
+		blocked  bool
+	}{
+		{"a", "b", false},
+		{"a", "c", true},
+		{"c", "b", true},
+		// Unlisted nodes form their own group
+		{"d", "e", false},
+		{"d", "a", true},
+		{"c", "d", true},
+		{"a", "a", false},
+	}
+	for _, tc := range cases {
+		if got := p.Blocks(tc.from, tc.to); got != tc.blocked {
+			t.Errorf("Blocks(%s, %s) = %v, expected %v", tc.from, tc.to, got, tc.blocked)
+		}
+	}
+}
+
+func TestPartitionOneWayCut(t *testing.T) {
+	p := Partition{Name: "deaf", Cuts: []Link{{From: "b", To: "a"}}}
+
+	if !p.Blocks("b", "a") {
+		t.Error("Expected b->a to be cut")
+	}
+	if p.Blocks("a", "b") {
+		t.Error("Expected a->b to stay open")
+	}
+	if p.Blocks("b", "c") {
+		t.Error("Expected b->c to stay open")
+	}
+}
+
+func TestPartitionValidation(t *testing.T) {
+	invalid := []Partition{
+		{Groups: [][]string{{"a"}}},
+		{Name: "overlap", Groups: [][]string{{"a", "b"}, {"b", "c"}}},
+		{Name: "empty", Groups: [][]string{{"a"}, {}}},
+		{Name: "self", Cuts: []Link{{From: "a", To: "a"}}},
+	}
+	topology := NewTopology()
+	for _, p := range invalid {
+		if err := topology.Add(p); err == nil {
+			t.Errorf("Expected error for partition %+v", p)
+		}
+	}
+	if len(topology.Partitions()) != 0 {
+		t.Error("Invalid partitions should not be installed")
+	}
+}
+
+func TestTopologyHealIndividually(t *testing.T) {
+	topology := NewTopology()
+	if err := topology.Add(Partition{Name: "p1", Groups: [][]string{{"a"}, {"b", "c"}}}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := topology.Cut("p2", "c", "b"); err != nil {
+		t.Fatalf("Cut failed: %v", err)
+	}
+	if err := topology.Cut("p2", "b", "a"); err != nil {
+		t.Fatalf("Cut failed: %v", err)
+	}
+
+	if !topology.Blocked("a", "b") || !topology.Blocked("c", "b") {
+		t.Fatal("Expected both partitions to be active")
+	}
+	if names := topology.Partitions(); len(names) != 2 || names[0].Name != "p1" || names[1].Name != "p2" {
+		t.Fatalf("Expected partitions p1 and p2, got %+v", names)
+	}
+
+	if err := topology.Heal("p1"); err != nil {
+		t.Fatalf("Heal failed: %v", err)
+	}
+	if topology.Blocked("a", "b") || topology.Blocked("a", "c") {
+		t.Error("Healing p1 should restore a's outbound links")
+	}
+	if !topology.Blocked("c", "b") || !topology.Blocked("b", "a") {
+		t.Error("Healing p1 should leave p2 in place")
+	}
+	if err := topology.Heal("p1"); err == nil {
+		t.Error("Expected error healing an unknown partition")
+	}
+
+	topology.HealAll()
+	if topology.Blocked("c", "b") {
+		t.Error("Expected no partitions after HealAll")
+	}
+}
+
+func TestMemoryTransportAsymmetricPartition(t *testing.T) {
+	sim := NewSimulator(1)
+	manager := NewSimulatedNetworkManager(sim)
+	node1 := manager.CreateNode("node-1").(*MemoryTransport)
+	node2 := manager.CreateNode("node-2").(*MemoryTransport)
+
+	received := map[string]int{}
+	node1.OnReceive(func(msg consensus.Message) { received["node-1"]++ })
+	node2.OnReceive(func(msg consensus.Message) { received["node-2"]++ })
+
+	// node-2 can hear node-1 but its replies are lost
+	if err := node2.CutLink("deaf", "node-2", "node-1"); err != nil {
+		t.Fatalf("CutLink failed: %v", err)
+	}
+	node1.Send("node-2", consensus.Message{From: "node-1"})
+	node2.Send("node-1", consensus.Message{From: "node-2"})
+	sim.RunFor(time.Second)
+
+	if received["node-2"] != 1 || received["node-1"] != 0 {
+		t.Fatalf("Expected only node-1->node-2 to deliver, got %v", received)
+	}
+
+	if err := node2.HealPartition("deaf"); err != nil {
+		t.Fatalf("HealPartition failed: %v", err)
+	}
+	node2.Send("node-1", consensus.Message{From: "node-2"})
+	sim.RunFor(time.Second)
+	if received["node-1"] != 1 {
+		t.Errorf("Expected delivery after healing, got %v", received)
+	}
+}
+
+func TestMemoryTransportDefaultPartition(t *testing.T) {
+	transport := NewMemoryTransport("node-1")
+
+	transport.CreatePartition([]string{"node-1"})
+	transport.CreatePartition([]string{"node-2"})
+	transport.AddPartition(Partition{Name: "other", Cuts: []Link{{From: "node-1", To: "node-2"}}})
+
+	if !transport.topology.Blocked("node-1", "node-3") {
+		t.Error("Expected the default partition to cut node-1 from node-3")
+	}
+
+	transport.RemovePartition([]string{"node-1", "node-2"})
+	if _, exists := transport.topology.Get(DefaultPartition); exists {
+		t.Error("Expected the default partition to be healed once empty")
+	}
+	if !transport.topology.Blocked("node-1", "node-2") {
+		t.Error("RemovePartition should not heal named partitions")
+	}
+
+	transport.ClearPartitions()
+	if len(transport.Partitions()) != 0 {
+		t.Error("Expected ClearPartitions to heal every partition")
+	}
+}
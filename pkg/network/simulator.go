@@ -0,0 +1,89 @@
+package network
+
+import (
+	"hash/fnv"
+	"math/rand/v2"
+	"time"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/clock"
+)
+
+// Virtual time at which every simulation starts
+var SimulationEpoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Drives a discrete-event simulation in virtual time. It is a manual
+// clock.Clock: scheduled callbacks run one at a time on the goroutine
+// calling Step or RunFor, in timestamp order, and callbacks due at the same
+// instant run in the order they were scheduled. Given the same seed and
+// the same sequence of calls, a simulation replays identically.
+type Simulator struct {
+	*clock.Manual
+	seed uint64
+}
+
+// Creates a simulator whose randomness derives from seed
+func NewSimulator(seed uint64) *Simulator {
+	return &Simulator{
+		Manual: clock.NewManual(SimulationEpoch),
+		seed:   seed,
+	}
+}
+
+// Seed returns the seed the simulator was created with
+func (s *Simulator) Seed() uint64 {
+	return s.seed
+}
+
+// Elapsed returns the virtual time since the start of the simulation
+func (s *Simulator) Elapsed() time.Duration {
+	return s.Now().Sub(SimulationEpoch)
+}
+
+// NewRand returns a random source for the named stream. Each stream is
+// independent, so one component consuming more randomness does not shift
+// the values another component sees.
+func (s *Simulator) NewRand(stream string) *rand.Rand {
+	h := fnv.New64a()
+	h.Write([]byte(stream))
+	return rand.New(rand.NewPCG(s.seed, h.Sum64()))
+}
+
+// Schedule runs fn after delay of virtual time
+func (s *Simulator) Schedule(delay time.Duration, fn func()) clock.Timer {
+	return s.AfterFunc(delay, fn)
+}
+
+// Cancel removes a pending event, reporting whether it had yet to run
+func (s *Simulator) Cancel(event clock.Timer) bool {
+	if event == nil {
+		return false
+	}
+	return event.Stop()
+}
+
+// RunFor runs every event due within d of virtual time, then advances the
+// clock to the end of the window. It returns the number of events run.
+func (s *Simulator) RunFor(d time.Duration) int {
+	return s.Advance(d)
+}
+
+// RunUntil runs every event due at or before deadline, then advances the
+// clock to the deadline. It returns the number of events run.
+func (s *Simulator) RunUntil(deadline time.Time) int {
+	return s.AdvanceTo(deadline)
+}
+
+// RunWhile runs events until cond returns false, no events remain or the
+// limit of virtual time has elapsed. It reports whether cond became false.
+func (s *Simulator) RunWhile(cond func() bool, limit time.Duration) bool {
+	deadline := s.Now().Add(limit)
+	for cond() {
+		next, ok := s.NextAt()
+		if !ok || next.After(deadline) {
+			s.AdvanceTo(deadline)
+			return !cond()
+		}
+		s.Step()
+	}
+	return true
+}
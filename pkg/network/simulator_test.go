@@ -0,0 +1,189 @@
+package network
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/consensus"
+)
+
+func TestSimulatorOrdering(t *testing.T) {
+	sim := NewSimulator(1)
+
+	var order []string
+	sim.Schedule(20*time.Millisecond, func() { order = append(order, "c") })
+	sim.Schedule(10*time.Millisecond, func() { order = append(order, "a") })
+	sim.Schedule(10*time.Millisecond, func() { order = append(order, "b") })
+
+	for sim.Step() {
+	}
+
+	expected := []string{"a", "b", "c"}
+	if fmt.Sprint(order) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, order)
+	}
+	if sim.Elapsed() != 20*time.Millisecond {
+		t.Errorf("Expected 20ms elapsed, got %v", sim.Elapsed())
+	}
+	if sim.Steps() != 3 {
+		t.Errorf("Expected 3 steps, got %d", sim.Steps())
+	}
+}
+
+func TestSimulatorCancel(t *testing.T) {
+	sim := NewSimulator(1)
+
+	ran := false
+	event := sim.Schedule(time.Second, func() { ran = true })
+	if !sim.Cancel(event) {
+		t.Error("Cancel should succeed for a pending event")
+	}
+	if sim.Cancel(event) {
+		t.Error("Cancel should fail for an already cancelled event")
+	}
+
+	sim.RunFor(2 * time.Second)
+	if ran {
+		t.Error("Cancelled event should not run")
+	}
+	if sim.Elapsed() != 2*time.Second {
+		t.Errorf("Expected clock to advance to 2s, got %v", sim.Elapsed())
+	}
+}
+
+func TestSimulatorRunWhile(t *testing.T) {
+	sim := NewSimulator(1)
+
+	count := 0
+	var tick func()
+	tick = func() {
+		count++
+		sim.Schedule(time.Second, tick)
+	}
+	sim.Schedule(time.Second, tick)
+
+	if !sim.RunWhile(func() bool { return count < 5 }, time.Minute) {
+		t.Fatal("Condition should have been met")
+	}
+	if count != 5 || sim.Elapsed() != 5*time.Second {
+		t.Errorf("Expected 5 ticks at 5s, got %d at %v", count, sim.Elapsed())
+	}
+
+	if sim.RunWhile(func() bool { return true }, 10*time.Second) {
+		t.Error("RunWhile should report false when the limit is reached")
+	}
+	if sim.Elapsed() != 15*time.Second {
+		t.Errorf("Expected clock at 15s, got %v", sim.Elapsed())
+	}
+}
+
+func TestSimulatorRandStreams(t *testing.T) {
+	a := NewSimulator(42).NewRand("node-1")
+	b := NewSimulator(42).NewRand("node-1")
+	c := NewSimulator(42).NewRand("node-2")
+
+	same, different := true, false
+	for i := 0; i < 10; i++ {
+		x, y, z := a.Uint64(), b.Uint64(), c.Uint64()
+		same = same && x == y
+		different = different || x != z
+	}
+	if !same {
+		t.Error("Streams with the same seed and name should match")
+	}
+	if !different {
+		t.Error("Streams with different names should differ")
+	}
+}
+
+// Runs a lossy, jittery exchange and returns the delivery trace
+func simulatedTrace(seed uint64) []string {
+	sim := NewSimulator(seed)
+	manager := NewSimulatedNetworkManager(sim)
+
+	ids := []string{"node-1", "node-2", "node-3"}
+	var trace []string
+	for _, id := range ids {
+		id := id
+		transport := manager.CreateNode(id).(*MemoryTransport)
+		transport.OnReceive(func(msg consensus.Message) {
+			trace = append(trace, fmt.Sprintf("%v %s->%s %s", sim.Elapsed(), msg.From, id, msg.Data))
+		})
+	}
+
+	conditions := DefaultNetworkConditions()
+	conditions.LatencyJitter = 50 * time.Millisecond
+	conditions.PacketLoss = 0.2
+	conditions.Duplication = 0.1
+	for _, from := range ids {
+		transport, _ := manager.GetNode(from)
+		for _, to := range ids {
+			transport.SetConditions(from, to, conditions)
+		}
+	}
+
+	for i := 0; i < 20; i++ {
+		for _, from := range ids {
+			transport, _ := manager.GetNode(from)
+			transport.Broadcast(consensus.Message{From: from, Data: []byte(fmt.Sprint(i))})
+		}
+		sim.RunFor(5 * time.Millisecond)
+	}
+	sim.RunFor(time.Second)
+	return trace
+}
+
+func TestSimulatedTransportDeterministic(t *testing.T) {
+	first := simulatedTrace(7)
+	second := simulatedTrace(7)
+	other := simulatedTrace(8)
+
+	if len(first) == 0 {
+		t.Fatal("Expected deliveries")
+	}
+	if fmt.Sprint(first) != fmt.Sprint(second) {
+		t.Error("Runs with the same seed should produce identical traces")
+	}
+	if fmt.Sprint(first) == fmt.Sprint(other) {
+		t.Error("Runs with different seeds should differ")
+	}
+}
+
+func TestSimulatedTransportVirtualLatency(t *testing.T) {
+	sim := NewSimulator(1)
+	manager := NewSimulatedNetworkManager(sim)
+
+	node1 := manager.CreateNode("node-1")
+	node2 := manager.CreateNode("node-2")
+
+	conditions := DefaultNetworkConditions()
+	conditions.BaseLatency = time.Hour
+	node1.SetConditions("node-1", "node-2", conditions)
+
+	start := time.Now()
+	if err := node1.Send("node-2", consensus.Message{From: "node-1", Data: []byte("slow")}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	sim.RunFor(59 * time.Minute)
+	select {
+	case <-node2.Receive():
+		t.Fatal("Message delivered before its latency elapsed")
+	default:
+	}
+
+	sim.RunFor(time.Minute)
+	select {
+	case msg := <-node2.Receive():
+		if string(msg.Data) != "slow" {
+			t.Errorf("Expected 'slow', got '%s'", msg.Data)
+		}
+	default:
+		t.Fatal("Message not delivered after its latency elapsed")
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Simulated hour took %v of wall-clock time", elapsed)
+	}
+}
@@ -0,0 +1,154 @@
+package network
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sort"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/consensus"
+)
+
+// Names of the partitions installed by the topology helpers
+const (
+	HalvesPartition       = "halves"
+	BridgePartition       = "bridge"
+	MajorityRingPartition = "majority-ring"
+	MinorityPartition     = "minority"
+)
+
+// RandomHalves splits nodes into two halves chosen by rng. With an odd
+// number of nodes the first group is the smaller one.
+func RandomHalves(nodes []string, rng *rand.Rand) (Partition, error) {
+	if len(nodes) < 2 {
+		return Partition{}, fmt.Errorf("halves need at least 2 nodes, got %d", len(nodes))
+	}
+
+	shuffled := shuffleNodes(nodes, rng)
+	half := len(shuffled) / 2
+	return Partition{
+		Name:   HalvesPartition,
+		Groups: [][]string{shuffled[:half], shuffled[half:]},
+	}, nil
+}
+
+// Bridge splits nodes into two sides that cannot reach each other, except
+// through a single bridge node connected to both
+func Bridge(nodes []string, rng *rand.Rand) (Partition, error) {
+	if len(nodes) < 3 {
+		return Partition{}, fmt.Errorf("a bridge needs at least 3 nodes, got %d", len(nodes))
+	}
+
+	shuffled := shuffleNodes(nodes, rng)
+	half := (len(shuffled) - 1) / 2
+	left, right := shuffled[:half], shuffled[half+1:]
+
+	p := Partition{Name: BridgePartition}
+	for _, a := range left {
+		for _, b := range right {
+			p.Cuts = append(p.Cuts, Link{From: a, To: b}, Link{From: b, To: a})
+		}
+	}
+	return p, nil
+}
+
+// MajorityRing arranges nodes in a ring where each node only reaches its
+// closest neighbours. Every node sees a majority of the cluster, but no two
+// nodes see the same majority.
+func MajorityRing(nodes []string, rng *rand.Rand) (Partition, error) {
+	if len(nodes) < 5 {
+		return Partition{}, fmt.Errorf("a majority ring needs at least 5 nodes, got %d", len(nodes))
+	}
+
+	ring := shuffleNodes(nodes, rng)
+	majority := len(ring)/2 + 1
+	reach := majority / 2 // neighbours visible on each side
+
+	p := Partition{Name: MajorityRingPartition}
+	for i, a := range ring {
+		for j, b := range ring {
+			distance := (j - i + len(ring)) % len(ring)
+			if distance > reach && distance < len(ring)-reach {
+				p.Cuts = append(p.Cuts, Link{From: a, To: b})
+			}
+		}
+	}
+	return p, nil
+}
+
+// RandomMinority cuts off a random minority of nodes, which can still
+// reach each other, from the majority
+func RandomMinority(nodes []string, rng *rand.Rand) (Partition, error) {
+	if len(nodes) < 3 {
+		return Partition{}, fmt.Errorf("a minority needs at least 3 nodes, got %d", len(nodes))
+	}
+
+	shuffled := shuffleNodes(nodes, rng)
+	size := 1 + rng.IntN((len(shuffled)-1)/2)
+	return Partition{
+		Name:   MinorityPartition,
+		Groups: [][]string{shuffled[:size]},
+	}, nil
+}
+
+// Returns a sorted copy of nodes shuffled by rng, so that the result only
+// depends on the node set and the random stream
+func shuffleNodes(nodes []string, rng *rand.Rand) []string {
+	shuffled := append([]string(nil), nodes...)
+	sort.Strings(shuffled)
+	rng.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	return shuffled
+}
+
+// PartitionHalves splits the cluster into two random halves
+func (nm *NetworkManager) PartitionHalves(seed uint64) (Partition, error) {
+	return nm.applyTopology(RandomHalves, seed)
+}
+
+// PartitionBridge splits the cluster in two around a random bridge node
+func (nm *NetworkManager) PartitionBridge(seed uint64) (Partition, error) {
+	return nm.applyTopology(Bridge, seed)
+}
+
+// PartitionMajorityRing gives every node a different majority view
+func (nm *NetworkManager) PartitionMajorityRing(seed uint64) (Partition, error) {
+	return nm.applyTopology(MajorityRing, seed)
+}
+
+// IsolateMinority cuts off a random minority of the cluster
+func (nm *NetworkManager) IsolateMinority(seed uint64) (Partition, error) {
+	return nm.applyTopology(RandomMinority, seed)
+}
+
+// IsolateLeader isolates the node that currently believes it is leader
+// and returns its ID. If several nodes do, the lowest ID is isolated.
+func (nm *NetworkManager) IsolateLeader(nodes []consensus.Node) (string, error) {
+	leaders := []string{}
+	for _, node := range nodes {
+		if node.IsLeader() {
+			leaders = append(leaders, node.ID())
+		}
+	}
+	if len(leaders) == 0 {
+		return "", fmt.Errorf("no leader to isolate")
+	}
+
+	sort.Strings(leaders)
+	if err := nm.Isolate(leaders[0]); err != nil {
+		return "", err
+	}
+	return leaders[0], nil
+}
+
+// Builds a partition from the current nodes and installs it
+func (nm *NetworkManager) applyTopology(build func([]string, *rand.Rand) (Partition, error), seed uint64) (Partition, error) {
+	p, err := build(nm.GetAllNodes(), rand.New(rand.NewPCG(seed, 0)))
+	if err != nil {
+		return Partition{}, err
+	}
+	if err := nm.topology.Add(p); err != nil {
+		return Partition{}, err
+	}
+	return p, nil
+}
@@ -0,0 +1,178 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/consensus"
+)
+
+var fiveNodes = []string{"n1", "n2", "n3", "n4", "n5"}
+
+// Returns the nodes each node can reach, including itself
+func visibility(p Partition, nodes []string) map[string][]string {
+	visible := make(map[string][]string)
+	for _, a := range nodes {
+		for _, b := range nodes {
+			if !p.Blocks(a, b) {
+				visible[a] = append(visible[a], b)
+			}
+		}
+	}
+	return visible
+}
+
+func TestRandomHalves(t *testing.T) {
+	p, err := RandomHalves(fiveNodes, rand.New(rand.NewPCG(1, 0)))
+	if err != nil {
+		t.Fatalf("RandomHalves failed: %v", err)
+	}
+	if len(p.Groups) != 2 || len(p.Groups[0]) != 2 || len(p.Groups[1]) != 3 {
+		t.Fatalf("Expected groups of 2 and 3, got %v", p.Groups)
+	}
+	for node, visible := range visibility(p, fiveNodes) {
+		if len(visible) != 2 && len(visible) != 3 {
+			t.Errorf("Node %s should only see its half, sees %v", node, visible)
+		}
+	}
+
+	if _, err := RandomHalves([]string{"n1"}, rand.New(rand.NewPCG(1, 0))); err == nil {
+		t.Error("Expected error splitting a single node")
+	}
+}
+
+func TestBridge(t *testing.T) {
+	p, err := Bridge(fiveNodes, rand.New(rand.NewPCG(1, 0)))
+	if err != nil {
+		t.Fatalf("Bridge failed: %v", err)
+	}
+
+	bridges := 0
+	for node, visible := range visibility(p, fiveNodes) {
+		switch len(visible) {
+		case 5:
+			bridges++
+		case 3:
+			// Own side of two plus the bridge
+		default:
+			t.Errorf("Node %s sees %v", node, visible)
+		}
+	}
+	if bridges != 1 {
+		t.Errorf("Expected exactly 1 bridge node, got %d", bridges)
+	}
+}
+
+func TestMajorityRing(t *testing.T) {
+	for _, size := range []int{5, 6, 7} {
+		nodes := make([]string, size)
+		for i := range nodes {
+			nodes[i] = fmt.Sprintf("n%d", i+1)
+		}
+
+		p, err := MajorityRing(nodes, rand.New(rand.NewPCG(1, 0)))
+		if err != nil {
+			t.Fatalf("MajorityRing failed: %v", err)
+		}
+
+		views := make(map[string]string)
+		for node, visible := range visibility(p, nodes) {
+			if len(visible) <= size/2 {
+				t.Errorf("%d nodes: %s sees only %v", size, node, visible)
+			}
+			if len(visible) == size {
+				t.Errorf("%d nodes: %s should not see every node", size, node)
+			}
+			for _, other := range visible {
+				if p.Blocks(other, node) {
+					t.Errorf("%d nodes: link between %s and %s should be symmetric", size, node, other)
+				}
+			}
+			view := fmt.Sprint(visible)
+			if previous, exists := views[view]; exists {
+				t.Errorf("%d nodes: %s and %s see the same majority", size, node, previous)
+			}
+			views[view] = node
+		}
+	}
+
+	if _, err := MajorityRing([]string{"n1", "n2", "n3"}, rand.New(rand.NewPCG(1, 0))); err == nil {
+		t.Error("Expected error for a ring of 3 nodes")
+	}
+}
+
+func TestRandomMinority(t *testing.T) {
+	for seed := uint64(0); seed < 20; seed++ {
+		p, err := RandomMinority(fiveNodes, rand.New(rand.NewPCG(seed, 0)))
+		if err != nil {
+			t.Fatalf("RandomMinority failed: %v", err)
+		}
+		if size := len(p.Groups[0]); size < 1 || size > 2 {
+			t.Fatalf("Expected a minority of 1 or 2 nodes, got %v", p.Groups[0])
+		}
+	}
+}
+
+func TestTopologySeeded(t *testing.T) {
+	manager := NewNetworkManager()
+	defer manager.Shutdown()
+	for _, nodeID := range fiveNodes {
+		manager.CreateNode(nodeID)
+	}
+
+	first, err := manager.PartitionBridge(42)
+	if err != nil {
+		t.Fatalf("PartitionBridge failed: %v", err)
+	}
+	second, _ := manager.PartitionBridge(42)
+	if fmt.Sprint(first) != fmt.Sprint(second) {
+		t.Errorf("Same seed should build the same partition: %v vs %v", first, second)
+	}
+
+	manager.PartitionHalves(42)
+	manager.PartitionMajorityRing(42)
+	manager.IsolateMinority(42)
+	if len(manager.Partitions()) != 4 {
+		t.Errorf("Expected 4 named partitions, got %v", manager.Partitions())
+	}
+}
+
+type fakeNode struct {
+	id     string
+	leader bool
+}
+
+func (n *fakeNode) Start(ctx context.Context) error { return nil }
+func (n *fakeNode) Stop() error                     { return nil }
+func (n *fakeNode) ID() string                      { return n.id }
+func (n *fakeNode) IsLeader() bool                  { return n.leader }
+func (n *fakeNode) Propose(data []byte) error       { return nil }
+func (n *fakeNode) GetState() consensus.NodeState   { return consensus.StateFollower }
+
+func TestIsolateLeader(t *testing.T) {
+	manager := NewNetworkManager()
+	defer manager.Shutdown()
+	nodes := []consensus.Node{}
+	for _, nodeID := range fiveNodes {
+		manager.CreateNode(nodeID)
+		nodes = append(nodes, &fakeNode{id: nodeID})
+	}
+
+	if _, err := manager.IsolateLeader(nodes); err == nil {
+		t.Error("Expected error without a leader")
+	}
+
+	nodes[2].(*fakeNode).leader = true
+	leader, err := manager.IsolateLeader(nodes)
+	if err != nil {
+		t.Fatalf("IsolateLeader failed: %v", err)
+	}
+	if leader != "n3" {
+		t.Errorf("Expected n3 to be isolated, got %s", leader)
+	}
+	if !manager.topology.Blocked("n3", "n1") || !manager.topology.Blocked("n1", "n3") {
+		t.Error("Expected the leader to be cut off in both directions")
+	}
+}
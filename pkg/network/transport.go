@@ -3,6 +3,8 @@ package network
 import (
 	"time"
 
+	"gopkg.in/yaml.v3"
+
 	"github.com/francisco-teixeirax86/consensusforge/pkg/consensus"
 )
 
@@ -13,26 +15,57 @@ type NetworkConditions struct {
 	PacketLoss  float64 `yaml:"packet_loss"` // 0.0 to 1.0
 	Duplication float64 `yaml:"duplication"` // 0.0 to 1.0
 
-	Bandwidth int64 `yaml:"bandwidth"` // bytes per second
+	// A reordered message is held back for up to ReorderWindow so that
+	// later messages on the same link overtake it. A zero window disables
+	// reordering.
+	Reorder       float64       `yaml:"reorder"` // 0.0 to 1.0
+	ReorderWindow time.Duration `yaml:"reorder_window"`
+
+	// Links are shaped by a token bucket refilled at Bandwidth bytes per
+	// second (0 = unlimited) holding up to BandwidthBurst bytes. Messages
+	// beyond the burst queue behind each other; once QueueLimit bytes are
+	// waiting further messages are tail-dropped (0 = unbounded queue).
+	Bandwidth      int64 `yaml:"bandwidth"`       // bytes per second
+	BandwidthBurst int64 `yaml:"bandwidth_burst"` // bytes
+	QueueLimit     int64 `yaml:"queue_limit"`     // bytes
 
 	Partitioned    bool     `yaml:"partitioned"`
 	PartitionNodes []string `yaml:"partition_nodes"`
 
-	Corruption float64 `yaml:"corruption"` // 0.0 to 1.0
+	// A corrupted message is damaged by one of CorruptionModes chosen at
+	// random; an empty list allows every mode
+	Corruption      float64          `yaml:"corruption"` // 0.0 to 1.0
+	CorruptionModes []CorruptionMode `yaml:"corruption_modes"`
 }
 
 // DefaultNetworkConditions returns normal network conditions
 func DefaultNetworkConditions() NetworkConditions {
 	return NetworkConditions{
-		BaseLatency:    1 * time.Millisecond,
-		LatencyJitter:  0,
-		PacketLoss:     0.0,
-		Duplication:    0.0,
-		Bandwidth:      0, // unlimited
-		Partitioned:    false,
-		PartitionNodes: []string{},
-		Corruption:     0.0,
+		BaseLatency:     1 * time.Millisecond,
+		LatencyJitter:   0,
+		PacketLoss:      0.0,
+		Duplication:     0.0,
+		Reorder:         0.0,
+		ReorderWindow:   0,
+		Bandwidth:       0, // unlimited
+		BandwidthBurst:  0,
+		QueueLimit:      0, // unbounded
+		Partitioned:     false,
+		PartitionNodes:  []string{},
+		Corruption:      0.0,
+		CorruptionModes: []CorruptionMode{},
+	}
+}
+
+// UnmarshalYAML fills fields missing from the document with their defaults
+func (c *NetworkConditions) UnmarshalYAML(value *yaml.Node) error {
+	type plain NetworkConditions
+	conditions := plain(DefaultNetworkConditions())
+	if err := value.Decode(&conditions); err != nil {
+		return err
 	}
+	*c = NetworkConditions(conditions)
+	return nil
 }
 
 // Extends the basic transport with network simulation capabilities
@@ -46,6 +79,11 @@ type NetworkTransport interface {
 	RemovePartition(nodes []string) error
 	ClearPartitions() error
 
+	AddPartition(p Partition) error
+	CutLink(name, from, to string) error
+	HealPartition(name string) error
+	Partitions() []Partition
+
 	GetStats() NetworkStats
 	ResetStats()
 }
@@ -57,6 +95,9 @@ type NetworkStats struct {
 	MessagesDropped    int64                `json:"messages_dropped"`
 	MessagesDuplicated int64                `json:"messages_duplicated"`
 	MessagesCorrupted  int64                `json:"messages_corrupted"`
+	MessagesReordered  int64                `json:"messages_reordered"` // delivered after a later message on the same link
+	MessagesTailDrop   int64                `json:"messages_tail_drop"` // dropped because the link queue was full
+	AverageQueueDelay  time.Duration        `json:"average_queue_delay"`
 	AverageLatency     time.Duration        `json:"average_latency"`
 	NodeStats          map[string]NodeStats `json:"node_stats"`
 }
@@ -0,0 +1,119 @@
+package scenario
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/config"
+)
+
+// Format version written to artifacts
+const ArtifactVersion = 1
+
+// Returned by Replay when the replayed run does not match the recording
+var ErrDiverged = errors.New("replay diverged from the recorded run")
+
+// Everything needed to reproduce a run: the scenario with its seed, the
+// node configuration it produced and the events it recorded
+type Artifact struct {
+	Version    int           `json:"version"`
+	Seed       uint64        `json:"seed"`
+	Scenario   string        `json:"scenario"` // YAML
+	Config     config.Config `json:"config"`   // node configuration before per-node fields
+	Passed     bool          `json:"passed"`
+	Violations []Violation   `json:"violations"`
+	Events     []Event       `json:"events"`
+}
+
+// NewArtifact records a finished run of s
+func NewArtifact(s *Scenario, result *Result) (*Artifact, error) {
+	data, err := s.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return &Artifact{
+		Version:    ArtifactVersion,
+		Seed:       s.Seed,
+		Scenario:   string(data),
+		Config:     s.Config.Apply(config.DefaultConfig()),
+		Passed:     result.Passed,
+		Violations: result.Violations,
+		Events:     result.Events,
+	}, nil
+}
+
+// LoadArtifact reads an artifact written by Write
+func LoadArtifact(path string) (*Artifact, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var a Artifact
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if a.Version != ArtifactVersion {
+		return nil, fmt.Errorf("%s: unsupported artifact version %d", path, a.Version)
+	}
+	return &a, nil
+}
+
+// Write saves the artifact as compact JSON
+func (a *Artifact) Write(path string) error {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// ParseScenario returns the recorded scenario
+func (a *Artifact) ParseScenario() (*Scenario, error) {
+	s, err := Parse([]byte(a.Scenario))
+	if err != nil {
+		return nil, err
+	}
+	if s.Seed != a.Seed {
+		return nil, fmt.Errorf("artifact seed %d does not match scenario seed %d", a.Seed, s.Seed)
+	}
+	return s, nil
+}
+
+// Replay runs the recorded scenario again with message tracing on, so it
+// produces the same events as the recording. Hooks in opts see each event
+// as it is replayed. If any event differs from the recording the result
+// is still returned, along with an error wrapping ErrDiverged.
+func Replay(ctx context.Context, a *Artifact, opts Options) (*Result, error) {
+	s, err := a.ParseScenario()
+	if err != nil {
+		return nil, err
+	}
+
+	diverged := 0
+	onEvent := opts.OnEvent
+	opts.TraceMessages = true
+	opts.OnEvent = func(e Event) {
+		if diverged == 0 && (e.Seq > len(a.Events) || a.Events[e.Seq-1] != e) {
+			diverged = e.Seq
+		}
+		if onEvent != nil {
+			onEvent(e)
+		}
+	}
+
+	result, err := Run(ctx, s, opts)
+	if err != nil {
+		return nil, err
+	}
+	if diverged == 0 && len(result.Events) != len(a.Events) {
+		diverged = len(result.Events) + 1
+	}
+	if diverged != 0 {
+		return result, fmt.Errorf("%w at event %d", ErrDiverged, diverged)
+	}
+	return result, nil
+}
@@ -0,0 +1,63 @@
+package scenario
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestArtifactReplay(t *testing.T) {
+	s := mustParse(t, `
+seed: 5
+duration: 2s
+workload: {kind: kv, keys: 3, clients: 2}
+timeline:
+  - {at: 500ms, action: partition, topology: halves}
+  - {at: 1s, action: heal}
+assertions:
+  - kind: converged
+`)
+	result, err := Run(context.Background(), s, Options{TraceMessages: true})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	artifact, err := NewArtifact(s, result)
+	if err != nil {
+		t.Fatalf("NewArtifact failed: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "artifact.json")
+	if err := artifact.Write(path); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	loaded, err := LoadArtifact(path)
+	if err != nil {
+		t.Fatalf("LoadArtifact failed: %v", err)
+	}
+
+	steps := 0
+	replayed, err := Replay(context.Background(), loaded, Options{
+		OnStep: func(e Event, cluster Inspector) {
+			steps++
+			if e.Seq == 10 && len(cluster.Nodes()) != 3 {
+				t.Errorf("Expected 3 nodes to inspect, got %d", len(cluster.Nodes()))
+			}
+			if e.Seq == 10 && cluster.Nodes()[0].Status == nil {
+				t.Error("Expected the status of Raft nodes")
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if steps != len(result.Events) || len(replayed.Events) != len(result.Events) {
+		t.Errorf("Expected %d replayed events, got %d", len(result.Events), steps)
+	}
+
+	// Tampering with the recording is detected
+	loaded.Events[3].Detail = "something else"
+	if _, err := Replay(context.Background(), loaded, Options{}); !errors.Is(err, ErrDiverged) {
+		t.Errorf("Expected ErrDiverged, got %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package scenario
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/checker"
+)
+
+// The command a client proposes to the cluster
+type Command struct {
+	ID    int64          `json:"id"`
+	Kind  checker.OpKind `json:"kind"`
+	Key   string         `json:"key"`
+	Value int64          `json:"value,omitempty"`
+}
+
+// The state machine every node runs during a scenario. It holds integer
+// values by key and reports each command it applies.
+type stateMachine struct {
+	values  map[string]int64
+	applied []int64 // command IDs in the order they were applied
+	onApply func(cmd Command, output *int64)
+}
+
+func newStateMachine(onApply func(cmd Command, output *int64)) *stateMachine {
+	return &stateMachine{
+		values:  make(map[string]int64),
+		onApply: onApply,
+	}
+}
+
+// Apply Implements consensus.StateMachine
+func (sm *stateMachine) Apply(data []byte) ([]byte, error) {
+	var cmd Command
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return nil, fmt.Errorf("decode command: %w", err)
+	}
+
+	var output *int64
+	switch cmd.Kind {
+	case checker.OpRead:
+		if value, exists := sm.values[cmd.Key]; exists {
+			output = &value
+		}
+	case checker.OpWrite:
+		sm.values[cmd.Key] = cmd.Value
+	case checker.OpAdd:
+		sm.values[cmd.Key] += cmd.Value
+	default:
+		return nil, fmt.Errorf("unknown command kind %q", cmd.Kind)
+	}
+	sm.applied = append(sm.applied, cmd.ID)
+
+	if sm.onApply != nil {
+		sm.onApply(cmd, output)
+	}
+	return json.Marshal(output)
+}
+
+type stateMachineSnapshot struct {
+	Values  map[string]int64 `json:"values"`
+	Applied []int64          `json:"applied"`
+}
+
+// Snapshot Implements consensus.StateMachine
+func (sm *stateMachine) Snapshot() ([]byte, error) {
+	return json.Marshal(stateMachineSnapshot{Values: sm.values, Applied: sm.applied})
+}
+
+// Restore Implements consensus.StateMachine
+func (sm *stateMachine) Restore(snapshot []byte) error {
+	var state stateMachineSnapshot
+	if err := json.Unmarshal(snapshot, &state); err != nil {
+		return err
+	}
+	sm.values = state.Values
+	if sm.values == nil {
+		sm.values = make(map[string]int64)
+	}
+	sm.applied = state.Applied
+	return nil
+}
+
+// GetState Implements consensus.StateMachine
+func (sm *stateMachine) GetState() interface{} {
+	values := make(map[string]int64, len(sm.values))
+	for key, value := range sm.values {
+		values[key] = value
+	}
+	return values
+}
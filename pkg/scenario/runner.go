@@ -0,0 +1,878 @@
+package scenario
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	_ "github.com/francisco-teixeirax86/consensusforge/pkg/algorithms" // registers the built-in algorithms
+	"github.com/francisco-teixeirax86/consensusforge/pkg/checker"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/config"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/consensus"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/logging"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/network"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/storage"
+)
+
+// Name of the partition that cut_link steps without a partition name use
+const defaultCutPartition = "cuts"
+
+// The membership change each membership action proposes
+var membershipChanges = map[Action]consensus.ChangeType{
+	ActionAddLearner: consensus.ChangeAddLearner,
+	ActionAddVoter:   consensus.ChangeAddVoter,
+	ActionPromote:    consensus.ChangePromote,
+	ActionRemoveNode: consensus.ChangeRemove,
+}
+
+type Options struct {
+	Logger   logging.Logger      // receives node and transport logs
+	Registry *consensus.Registry // nil uses the default registry
+	OnEvent  func(Event)         // called as each event is recorded
+
+	// Called after each event with read access to the cluster. The run
+	// does not advance until it returns.
+	OnStep func(Event, Inspector)
+
+	// Record every message delivery as an event
+	TraceMessages bool
+}
+
+// Gives read access to the cluster during a run
+type Inspector interface {
+	Nodes() []NodeSnapshot
+}
+
+// Number of recent log entries included in a node snapshot
+const snapshotEntries = 5
+
+// The state of one node at a point in a run
+type NodeSnapshot struct {
+	ID      string              `json:"id"`
+	Running bool                `json:"running"`
+	State   consensus.NodeState `json:"state"`
+	Applied int                 `json:"applied"` // commands applied by the state machine
+	Values  map[string]int64    `json:"values"`
+
+	// Set for nodes that implement consensus.Inspectable
+	Status *consensus.NodeStatus `json:"status,omitempty"`
+}
+
+func (n NodeSnapshot) String() string {
+	if !n.Running {
+		return fmt.Sprintf("%s: crashed", n.ID)
+	}
+	if n.Status == nil {
+		return fmt.Sprintf("%s: %s, %d applied, values %v", n.ID, n.State, n.Applied, n.Values)
+	}
+	leader := n.Status.Leader
+	if leader == "" {
+		leader = "unknown"
+	}
+	return fmt.Sprintf("%s: %s in term %d, leader %s, log %d, commit %d, %d applied, values %v",
+		n.ID, n.State, n.Status.Term, leader, n.Status.LogLength, n.Status.CommitIndex, n.Applied, n.Values)
+}
+
+// Defines what an event records
+type EventKind string
+
+const (
+	EventAction    EventKind = "action"    // a timeline step ran
+	EventDeliver   EventKind = "deliver"   // a message reached a node
+	EventInvoke    EventKind = "invoke"    // a client issued an operation
+	EventComplete  EventKind = "complete"  // a client operation ended
+	EventViolation EventKind = "violation" // a property did not hold
+	EventFault     EventKind = "fault"     // a node's storage injected a fault
+)
+
+// Something that happened during a run, At an offset from its start.
+// Events are numbered from 1 in the order they happened.
+type Event struct {
+	Seq    int           `json:"seq"`
+	At     time.Duration `json:"at"`
+	Kind   EventKind     `json:"kind"`
+	Node   string        `json:"node,omitempty"`
+	From   string        `json:"from,omitempty"` // sender of a delivered message
+	Detail string        `json:"detail"`
+}
+
+func (e Event) String() string {
+	node := e.Node
+	if e.From != "" {
+		node = e.From + "->" + e.Node
+	}
+	if node != "" {
+		return fmt.Sprintf("#%-6d %10v %-9s %s: %s", e.Seq, e.At, e.Kind, node, e.Detail)
+	}
+	return fmt.Sprintf("#%-6d %10v %-9s %s", e.Seq, e.At, e.Kind, e.Detail)
+}
+
+// A property that did not hold
+type Violation struct {
+	Kind    string        `json:"kind"`
+	At      time.Duration `json:"at"`
+	Message string        `json:"message"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s at %v: %s", v.Kind, v.At, v.Message)
+}
+
+type Stats struct {
+	Operations int    `json:"operations"`
+	Completed  int    `json:"completed"`
+	Failed     int    `json:"failed"`
+	Unknown    int    `json:"unknown"`
+	Events     uint64 `json:"events"` // simulator events run
+
+	// Message counters summed over every transport
+	Network network.NetworkStats `json:"network"`
+}
+
+// The outcome of a run
+type Result struct {
+	Scenario   string              `json:"scenario"`
+	Algorithm  string              `json:"algorithm"`
+	Seed       uint64              `json:"seed"`
+	Nodes      int                 `json:"nodes"`
+	Duration   time.Duration       `json:"duration"`
+	Passed     bool                `json:"passed"`
+	Violations []Violation         `json:"violations"`
+	Stats      Stats               `json:"stats"`
+	History    []checker.Operation `json:"history"`
+	Events     []Event             `json:"events"`
+}
+
+// Run executes a scenario on a simulated cluster. Everything runs on the
+// scenario's virtual clock, so the same scenario and seed always produce
+// the same result. The error is only set when the run could not be
+// carried out; property violations are reported in the result.
+func Run(ctx context.Context, s *Scenario, opts Options) (*Result, error) {
+	r, err := newRunner(ctx, s, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer r.shutdown()
+
+	timeline := append([]Step(nil), s.Timeline...)
+	sort.SliceStable(timeline, func(i, j int) bool { return timeline[i].At < timeline[j].At })
+
+	r.workload.start()
+	for _, step := range timeline {
+		if err := r.runUntil(step.At); err != nil {
+			return nil, err
+		}
+		if err := r.execute(step); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.runUntil(s.Duration); err != nil {
+		return nil, err
+	}
+
+	r.workload.finish()
+	r.checkAssertions()
+	return r.finish(), nil
+}
+
+// A node slot in the cluster. A crashed node is replaced by a fresh
+// instance when it restarts. Spares have no instance until they join.
+type member struct {
+	node        consensus.Node
+	sm          *stateMachine
+	running     bool
+	spare       bool // outside the cluster when it started
+	leaving     bool // its removal is proposed but not known to be committed
+	incarnation int
+	cancel      context.CancelFunc // cancels the context the node runs in
+	storage     *storage.Faulty
+	disk        storage.DiskConditions
+}
+
+type runner struct {
+	scenario  *Scenario
+	opts      Options
+	logger    logging.Logger
+	algorithm consensus.Algorithm
+	sim       *network.Simulator
+	manager   *network.NetworkManager
+	start     time.Time
+	ctx       context.Context
+	cancel    context.CancelFunc
+	ids       []string // initial nodes, then spares
+	members   map[string]*member
+	dataDir   string     // holds a data directory per node, removed when the run ends
+	rng       *rand.Rand // timeline randomness
+	workload  *workload
+	monitor   *checker.Monitor
+	stats     network.NetworkStats // from transports closed by crashes
+	result    Result
+}
+
+func newRunner(ctx context.Context, s *Scenario, opts Options) (*runner, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	registry := opts.Registry
+	lookup := consensus.Lookup
+	if registry != nil {
+		lookup = registry.Lookup
+	}
+	algorithm, err := lookup(s.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = logging.NewNoOpLogger()
+	}
+
+	sim := network.NewSimulator(s.Seed)
+	manager := network.NewSimulatedNetworkManager(sim)
+	manager.SetLogger(logger)
+	if s.Network != nil {
+		manager.SetAllLinks(*s.Network)
+	}
+
+	dataDir, err := os.MkdirTemp(s.Config.DataDir, "consensusforge-run-")
+	if err != nil {
+		return nil, fmt.Errorf("create data directory: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	r := &runner{
+		scenario:  s,
+		opts:      opts,
+		logger:    logger,
+		algorithm: algorithm,
+		sim:       sim,
+		manager:   manager,
+		start:     sim.Now(),
+		ctx:       ctx,
+		cancel:    cancel,
+		ids:       append(s.NodeIDs(), s.SpareIDs()...),
+		members:   make(map[string]*member),
+		dataDir:   dataDir,
+		rng:       sim.NewRand("timeline"),
+		monitor:   checker.NewMonitor(),
+		result: Result{
+			Scenario:  s.Name,
+			Algorithm: algorithm.Name(),
+			Seed:      s.Seed,
+			Nodes:     s.Nodes,
+		},
+	}
+	r.workload = newWorkload(r, s.Workload, sim.NewRand("workload"))
+	if opts.TraceMessages {
+		manager.SetObserver(func(msg consensus.Message) {
+			r.record(Event{Kind: EventDeliver, Node: msg.To, From: msg.From,
+				Detail: fmt.Sprintf("%s term %d, %d bytes", msg.Type, msg.Term, len(msg.Data))})
+		})
+	}
+
+	for _, id := range r.ids {
+		r.members[id] = &member{disk: storage.DefaultDiskConditions()}
+		if s.Disk != nil {
+			r.members[id].disk = *s.Disk
+		}
+	}
+	for _, id := range s.SpareIDs() {
+		r.members[id].spare = true
+	}
+	for _, id := range s.NodeIDs() {
+		manager.CreateNode(id)
+	}
+	for _, id := range s.NodeIDs() {
+		if err := r.startNode(id); err != nil {
+			r.shutdown()
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Creates an instance of a node from its data directory on its current
+// transport and starts it. The node's storage injects the faults of its
+// disk conditions. Spares start outside the cluster, waiting to be added.
+func (r *runner) startNode(id string) error {
+	transport, err := r.manager.GetNode(id)
+	if err != nil {
+		return err
+	}
+	nodeClock, err := r.manager.NodeClock(id)
+	if err != nil {
+		return err
+	}
+
+	cfg := r.scenario.Config.Apply(config.DefaultConfig())
+	cfg.NodeID = id
+	cfg.Algorithm = r.algorithm.Name()
+	cfg.DataDir = filepath.Join(r.dataDir, id)
+	cfg.Peers = make([]string, 0, r.scenario.Nodes)
+	for _, peer := range r.scenario.NodeIDs() {
+		if peer != id {
+			cfg.Peers = append(cfg.Peers, peer)
+		}
+	}
+	cfg.Join = r.members[id].spare
+
+	node, err := r.algorithm.CreateNode(id, cfg)
+	if err != nil {
+		return fmt.Errorf("create node %s: %w", id, err)
+	}
+	attachable, ok := node.(consensus.Attachable)
+	if !ok {
+		return fmt.Errorf("algorithm %s does not accept an environment", r.algorithm.Name())
+	}
+
+	m := r.members[id]
+	m.incarnation++
+	m.sm = newStateMachine(r.workload.applied)
+	wal, err := storage.OpenWAL(cfg.DataDir)
+	if err != nil {
+		return fmt.Errorf("open storage of node %s: %w", id, err)
+	}
+	disk, err := storage.NewFaulty(wal, r.sim.NewRand(fmt.Sprintf("disk/%s/%d", id, m.incarnation)))
+	if err != nil {
+		wal.Close()
+		return fmt.Errorf("open storage of node %s: %w", id, err)
+	}
+	disk.SetConditions(m.disk)
+	// Faults happen while the node holds its lock, so they are recorded
+	// as a separate event at the same instant
+	disk.SetObserver(func(fault string) {
+		r.sim.Schedule(0, func() { r.event(EventFault, id, "%s", fault) })
+	})
+	m.storage = disk
+
+	err = attachable.Attach(consensus.Environment{
+		Transport:    transport,
+		StateMachine: m.sm,
+		Logger:       r.logger,
+		Clock:        nodeClock,
+		Rand:         r.sim.NewRand(fmt.Sprintf("node/%s/%d", id, m.incarnation)),
+		Storage:      disk,
+	})
+	if err != nil {
+		disk.Close()
+		return fmt.Errorf("attach node %s: %w", id, err)
+	}
+	ctx, cancel := context.WithCancel(r.ctx)
+	if err := node.Start(ctx); err != nil {
+		cancel()
+		node.Stop()
+		return fmt.Errorf("start node %s: %w", id, err)
+	}
+
+	m.node = node
+	m.running = true
+	m.cancel = cancel
+	return nil
+}
+
+// Runs simulator events until offset from the start of the run
+func (r *runner) runUntil(offset time.Duration) error {
+	if remaining := offset - r.elapsed(); remaining > 0 {
+		r.sim.RunWhile(r.proceed, remaining)
+	}
+	return r.ctx.Err()
+}
+
+// Called between simulator events. Checks the safety invariants against
+// the nodes as the last event left them and reports whether to go on.
+// Nodes that stopped on their own, such as after a failed sync, are
+// taken down as if they crashed.
+func (r *runner) proceed() bool {
+	var observations []consensus.Observation
+	for _, id := range r.running() {
+		if r.members[id].node.GetState() == consensus.StateStopped {
+			r.takeDown(id)
+			r.event(EventAction, id, "stopped on its own")
+			continue
+		}
+		node, ok := r.members[id].node.(consensus.Observable)
+		if !ok {
+			continue
+		}
+		observations = append(observations, node.Observe())
+	}
+	for _, v := range r.monitor.Observe(observations) {
+		r.violation(v.Invariant, "%s", v.Message)
+	}
+	r.removeLeaving(observations)
+	return r.ctx.Err() == nil
+}
+
+func (r *runner) elapsed() time.Duration {
+	return r.sim.Now().Sub(r.start)
+}
+
+func (r *runner) event(kind EventKind, node, format string, args ...interface{}) {
+	r.record(Event{Kind: kind, Node: node, Detail: fmt.Sprintf(format, args...)})
+}
+
+func (r *runner) record(e Event) {
+	e.Seq = len(r.result.Events) + 1
+	e.At = r.elapsed()
+	r.result.Events = append(r.result.Events, e)
+	if r.opts.OnEvent != nil {
+		r.opts.OnEvent(e)
+	}
+	if r.opts.OnStep != nil {
+		r.opts.OnStep(e, r)
+	}
+}
+
+// Nodes Implements Inspector
+func (r *runner) Nodes() []NodeSnapshot {
+	nodes := make([]NodeSnapshot, 0, len(r.ids))
+	for _, id := range r.ids {
+		m := r.members[id]
+		if m.node == nil {
+			// A spare that never joined
+			continue
+		}
+		snapshot := NodeSnapshot{ID: id, Running: m.running}
+		if m.running {
+			snapshot.State = m.node.GetState()
+			snapshot.Applied = len(m.sm.applied)
+			snapshot.Values = m.sm.GetState().(map[string]int64)
+			if node, ok := m.node.(consensus.Inspectable); ok {
+				status := node.Status(snapshotEntries)
+				snapshot.Status = &status
+			}
+		}
+		nodes = append(nodes, snapshot)
+	}
+	return nodes
+}
+
+func (r *runner) violation(kind, format string, args ...interface{}) {
+	v := Violation{Kind: kind, At: r.elapsed(), Message: fmt.Sprintf(format, args...)}
+	r.result.Violations = append(r.result.Violations, v)
+	r.event(EventViolation, "", "%s", v.String())
+}
+
+// Returns the IDs of the running nodes in order
+func (r *runner) running() []string {
+	running := make([]string, 0, len(r.ids))
+	for _, id := range r.ids {
+		if r.members[id].running {
+			running = append(running, id)
+		}
+	}
+	return running
+}
+
+// Returns the running nodes in order
+func (r *runner) runningNodes() []consensus.Node {
+	nodes := []consensus.Node{}
+	for _, id := range r.running() {
+		nodes = append(nodes, r.members[id].node)
+	}
+	return nodes
+}
+
+// Returns a running node that believes it is leader, preferring the
+// lowest ID, or "" if there is none
+func (r *runner) leader() string {
+	for _, node := range r.runningNodes() {
+		if node.IsLeader() {
+			return node.ID()
+		}
+	}
+	return ""
+}
+
+// Executes one timeline step
+func (r *runner) execute(step Step) error {
+	var err error
+	switch step.Action {
+	case ActionPartition:
+		err = r.partition(step)
+	case ActionHeal:
+		if step.Partition == "" {
+			r.manager.Heal()
+			r.event(EventAction, "", "heal every partition")
+		} else if err = r.manager.HealPartition(step.Partition); err == nil {
+			r.event(EventAction, "", "heal partition %s", step.Partition)
+		}
+	case ActionIsolate:
+		for _, id := range step.Nodes {
+			if err = r.manager.Isolate(id); err != nil {
+				break
+			}
+			r.event(EventAction, id, "isolate")
+		}
+	case ActionIsolateLeader:
+		var leader string
+		if leader, err = r.manager.IsolateLeader(r.runningNodes()); err == nil {
+			r.event(EventAction, leader, "isolate leader")
+		}
+	case ActionCutLink:
+		name := step.Partition
+		if name == "" {
+			name = defaultCutPartition
+		}
+		if err = r.manager.CutLink(name, step.From, step.To); err == nil {
+			r.event(EventAction, "", "cut link %s->%s (%s)", step.From, step.To, name)
+		}
+	case ActionSetLink:
+		if step.From == "" {
+			r.manager.SetAllLinks(*step.Conditions)
+			r.event(EventAction, "", "set every link to %+v", *step.Conditions)
+		} else if err = r.manager.SetLink(step.From, step.To, *step.Conditions); err == nil {
+			r.event(EventAction, "", "set link %s->%s to %+v", step.From, step.To, *step.Conditions)
+		}
+	case ActionSetClock:
+		for _, id := range step.Nodes {
+			if err = r.manager.SetClockConditions(id, *step.Clock); err != nil {
+				break
+			}
+			r.event(EventAction, id, "set clock to %+v", *step.Clock)
+		}
+	case ActionSetDisk:
+		for _, id := range step.Nodes {
+			m := r.members[id]
+			m.disk = *step.Disk
+			if m.storage != nil {
+				m.storage.SetConditions(m.disk)
+			}
+			r.event(EventAction, id, "set disk to %+v", m.disk)
+		}
+	case ActionCrash:
+		for _, id := range step.Nodes {
+			r.crash(id, step.LoseUnsynced)
+		}
+	case ActionRestart:
+		for _, id := range step.Nodes {
+			if err = r.restart(id); err != nil {
+				return err
+			}
+		}
+	case ActionWaitForLeader:
+		return r.waitForLeader(step.Timeout)
+	case ActionAddLearner, ActionAddVoter, ActionPromote, ActionRemoveNode:
+		id := step.Nodes[0]
+		change := consensus.MembershipChange{Type: membershipChanges[step.Action], Node: id}
+		err = r.changeMembership(change)
+		adding := change.Type == consensus.ChangeAddLearner || change.Type == consensus.ChangeAddVoter
+		if err == nil && adding && !r.members[id].running {
+			return r.join(id)
+		}
+	}
+
+	// Faults that cannot be applied in the cluster's current state are
+	// recorded rather than ending the run
+	if err != nil {
+		r.event(EventAction, "", "%s skipped: %v", step.Action, err)
+	}
+	return nil
+}
+
+func (r *runner) partition(step Step) error {
+	if len(step.Groups) > 0 {
+		if err := r.manager.Partition(step.Groups...); err != nil {
+			return err
+		}
+		r.event(EventAction, "", "partition %v", step.Groups)
+		return nil
+	}
+
+	build := map[string]func(uint64) (network.Partition, error){
+		TopologyHalves:       r.manager.PartitionHalves,
+		TopologyBridge:       r.manager.PartitionBridge,
+		TopologyMajorityRing: r.manager.PartitionMajorityRing,
+		TopologyMinority:     r.manager.IsolateMinority,
+	}[step.Topology]
+	p, err := build(r.rng.Uint64())
+	if err != nil {
+		return err
+	}
+	if len(p.Groups) > 0 {
+		r.event(EventAction, "", "partition %s %v", step.Topology, p.Groups)
+	} else {
+		r.event(EventAction, "", "partition %s %v", step.Topology, p.Cuts)
+	}
+	return nil
+}
+
+// Stops a node, cancels its context and drops its transport. Messages in
+// flight to it are lost, and so are the writes it has not synced when
+// loseUnsynced is set and the node persists state.
+func (r *runner) crash(id string, loseUnsynced bool) {
+	m := r.members[id]
+	if !m.running {
+		r.event(EventAction, id, "crash skipped: not running")
+		return
+	}
+
+	detail := "crash"
+	var err error
+	if crashable, ok := m.node.(consensus.Crashable); ok && loseUnsynced {
+		err = crashable.Crash()
+		detail = "crash, unsynced writes lost"
+	} else {
+		err = m.node.Stop()
+	}
+	r.takeDown(id)
+	if err != nil {
+		detail = fmt.Sprintf("%s (%v)", detail, err)
+	}
+	r.event(EventAction, id, "%s", detail)
+}
+
+// Cancels the context of a stopped node and drops its transport. Its
+// storage is closed in case the node did not use it.
+func (r *runner) takeDown(id string) {
+	m := r.members[id]
+	m.cancel()
+	m.running = false
+	m.storage.Close()
+	if transport, err := r.manager.GetNode(id); err == nil {
+		addNetworkStats(&r.stats, transport.GetStats())
+		transport.Close()
+	}
+}
+
+// Starts a new instance of a crashed node on a new transport. The node
+// keeps its clock and what it persisted in its data directory; the rest
+// of its state, including the state machine, starts empty.
+func (r *runner) restart(id string) error {
+	if r.members[id].running {
+		r.event(EventAction, id, "restart skipped: already running")
+		return nil
+	}
+
+	r.manager.CreateNode(id)
+	if err := r.startNode(id); err != nil {
+		return err
+	}
+	r.event(EventAction, id, "restart")
+	return nil
+}
+
+// Proposes a membership change through the current leader
+func (r *runner) changeMembership(change consensus.MembershipChange) error {
+	leader := r.leader()
+	if leader == "" {
+		return fmt.Errorf("no leader")
+	}
+	node, ok := r.members[leader].node.(consensus.Reconfigurable)
+	if !ok {
+		return fmt.Errorf("algorithm %s does not support membership changes", r.algorithm.Name())
+	}
+	if err := node.ChangeMembership(change); err != nil {
+		return err
+	}
+	r.event(EventAction, change.Node, "%s proposed to %s", change.Type, leader)
+	if change.Type == consensus.ChangeRemove {
+		r.members[change.Node].leaving = true
+	}
+	return nil
+}
+
+// Starts a node outside the cluster on a new transport, once a change
+// adding it has been proposed. A node removed earlier comes back with
+// what it persisted.
+func (r *runner) join(id string) error {
+	r.manager.CreateNode(id)
+	if err := r.startNode(id); err != nil {
+		return err
+	}
+	r.event(EventAction, id, "join")
+	return nil
+}
+
+// Stops the nodes being removed, and drops their transport, once the
+// leader of the highest term has committed a membership without them
+func (r *runner) removeLeaving(observations []consensus.Observation) {
+	leaving := false
+	for _, m := range r.members {
+		leaving = leaving || m.leaving
+	}
+	if !leaving {
+		return
+	}
+
+	var leader *consensus.Observation
+	for i, observation := range observations {
+		if observation.State == consensus.StateLeader && (leader == nil || observation.Term > leader.Term) {
+			leader = &observations[i]
+		}
+	}
+	if leader == nil {
+		return
+	}
+	node, ok := r.members[leader.ID].node.(consensus.Reconfigurable)
+	if !ok {
+		return
+	}
+	members, committed := node.Membership()
+	if !committed {
+		return
+	}
+
+	for _, id := range r.ids {
+		m := r.members[id]
+		if !m.leaving || members.Contains(id) {
+			continue
+		}
+		m.leaving = false
+		if m.running {
+			m.node.Stop()
+			r.takeDown(id)
+		}
+		r.manager.RemoveNode(id)
+		r.event(EventAction, id, "removed from the cluster")
+	}
+}
+
+func (r *runner) waitForLeader(timeout time.Duration) error {
+	if remaining := r.scenario.Duration - r.elapsed(); remaining < timeout {
+		timeout = remaining
+	}
+	r.sim.RunWhile(func() bool { return r.proceed() && r.leader() == "" }, timeout)
+	if err := r.ctx.Err(); err != nil {
+		return err
+	}
+
+	if leader := r.leader(); leader != "" {
+		r.event(EventAction, leader, "leader elected")
+	} else {
+		r.violation(string(ActionWaitForLeader), "no leader elected within %v", timeout)
+	}
+	return nil
+}
+
+func (r *runner) checkAssertions() {
+	for _, assertion := range r.scenario.Assertions {
+		switch assertion.Kind {
+		case AssertLeader:
+			if r.leader() == "" {
+				r.violation(string(assertion.Kind), "no leader at the end of the run")
+			}
+		case AssertConverged:
+			r.checkConverged()
+		case AssertMinCompleted:
+			completed := 0
+			for _, op := range r.workload.history {
+				if op.Status == checker.StatusOK {
+					completed++
+				}
+			}
+			if completed < assertion.Min {
+				r.violation(string(assertion.Kind), "%d operations completed, expected at least %d", completed, assertion.Min)
+			}
+		case AssertLinearizable:
+			if result := r.workload.checkLinearizable(); !result.Linearizable {
+				r.violation(string(assertion.Kind), "%s", result)
+			}
+		}
+	}
+}
+
+// Checks that every running node applied the same commands in the same
+// order. Nodes that know they are outside the cluster are left out.
+func (r *runner) checkConverged() {
+	var running []string
+	for _, id := range r.running() {
+		node, ok := r.members[id].node.(consensus.Reconfigurable)
+		if ok {
+			if members, _ := node.Membership(); !members.Contains(id) {
+				continue
+			}
+		}
+		running = append(running, id)
+	}
+	if len(running) == 0 {
+		return
+	}
+
+	reference := running[0]
+	expected := r.members[reference].sm.applied
+	for _, id := range running[1:] {
+		applied := r.members[id].sm.applied
+		if len(applied) != len(expected) {
+			r.violation(string(AssertConverged), "%s applied %d commands, %s applied %d",
+				reference, len(expected), id, len(applied))
+			continue
+		}
+		for i := range applied {
+			if applied[i] != expected[i] {
+				r.violation(string(AssertConverged), "command %d differs: %s applied %d, %s applied %d",
+					i+1, reference, expected[i], id, applied[i])
+				break
+			}
+		}
+	}
+}
+
+// Builds the result once the run is over
+func (r *runner) finish() *Result {
+	result := r.result
+	result.Duration = r.elapsed()
+	result.Passed = len(result.Violations) == 0
+	result.History = r.workload.history
+
+	stats := Stats{Events: r.sim.Steps(), Network: r.stats}
+	for _, id := range r.running() {
+		if transport, err := r.manager.GetNode(id); err == nil {
+			addNetworkStats(&stats.Network, transport.GetStats())
+		}
+	}
+	for _, op := range result.History {
+		stats.Operations++
+		switch op.Status {
+		case checker.StatusOK:
+			stats.Completed++
+		case checker.StatusFail:
+			stats.Failed++
+		case checker.StatusInfo:
+			stats.Unknown++
+		}
+	}
+	result.Stats = stats
+	return &result
+}
+
+func (r *runner) shutdown() {
+	for _, id := range r.running() {
+		m := r.members[id]
+		m.node.Stop()
+		m.storage.Close()
+		m.running = false
+	}
+	r.cancel()
+	r.manager.Shutdown()
+	if err := os.RemoveAll(r.dataDir); err != nil {
+		r.logger.Warn("removing data directory failed", logging.Error(err))
+	}
+}
+
+// Adds the counters of s to total. Averages are not combined.
+func addNetworkStats(total *network.NetworkStats, s network.NetworkStats) {
+	total.MessagesSent += s.MessagesSent
+	total.MessagesReceived += s.MessagesReceived
+	total.MessagesDropped += s.MessagesDropped
+	total.MessagesDuplicated += s.MessagesDuplicated
+	total.MessagesCorrupted += s.MessagesCorrupted
+	total.MessagesReordered += s.MessagesReordered
+	total.MessagesTailDrop += s.MessagesTailDrop
+	if total.NodeStats == nil {
+		total.NodeStats = make(map[string]network.NodeStats)
+	}
+	for id, node := range s.NodeStats {
+		sum := total.NodeStats[id]
+		sum.Sent += node.Sent
+		sum.Received += node.Received
+		sum.Dropped += node.Dropped
+		total.NodeStats[id] = sum
+	}
+}
@@ -0,0 +1,459 @@
+package scenario
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/algorithms/raft"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/checker"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/config"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/consensus"
+)
+
+func mustParse(t *testing.T, doc string) *Scenario {
+	t.Helper()
+	s, err := Parse([]byte(doc))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	return s
+}
+
+func mustRun(t *testing.T, s *Scenario) *Result {
+	t.Helper()
+	result, err := Run(context.Background(), s, Options{})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	return result
+}
+
+func TestRunCommittedScenarios(t *testing.T) {
+	paths := []string{
+		"../algorithms/raft/scenarios/leader_isolation.yaml",
+		"../algorithms/raft/scenarios/asymmetric_partition.yaml",
+		"../algorithms/raft/scenarios/node_replacement.yaml",
+		"../algorithms/paxos/scenarios/minority_partition.yaml",
+	}
+	for _, path := range paths {
+		s, err := Load(path)
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		result := mustRun(t, s)
+		if !result.Passed {
+			t.Errorf("%s: expected to pass, got %v", path, result.Violations)
+		}
+		if result.Stats.Completed == 0 {
+			t.Errorf("%s: expected completed operations, got %+v", path, result.Stats)
+		}
+	}
+}
+
+func TestRunDeterministic(t *testing.T) {
+	s, err := Load("../algorithms/raft/scenarios/leader_isolation.yaml")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	first, second := mustRun(t, s), mustRun(t, s)
+	if fmt.Sprint(first.Events) != fmt.Sprint(second.Events) {
+		t.Error("Runs with the same seed should record the same events")
+	}
+	if fmt.Sprint(first.Stats) != fmt.Sprint(second.Stats) {
+		t.Errorf("Runs with the same seed should have the same stats: %+v vs %+v", first.Stats, second.Stats)
+	}
+
+	s.Seed++
+	third := mustRun(t, s)
+	if fmt.Sprint(first.Events) == fmt.Sprint(third.Events) {
+		t.Error("Runs with different seeds should differ")
+	}
+}
+
+func TestRunCrashRestart(t *testing.T) {
+	result := mustRun(t, mustParse(t, `
+nodes: 3
+duration: 6s
+workload: {kind: register, clients: 2, read_ratio: 0.5}
+timeline:
+  - {at: 0s, action: wait_for_leader, timeout: 2s}
+  - {at: 2s, action: crash, nodes: [node-1, node-2]}
+  - {at: 2s, action: crash, nodes: [node-1]}
+  - {at: 3s, action: restart, nodes: [node-1, node-2]}
+  - {at: 3s, action: wait_for_leader, timeout: 2s}
+assertions:
+  - kind: leader
+  - kind: min_completed
+    min: 5
+`))
+	if !result.Passed {
+		t.Fatalf("Expected the cluster to recover, got %v", result.Violations)
+	}
+
+	skipped := false
+	for _, e := range result.Events {
+		if e.Kind == EventAction && e.Node == "node-1" && e.Detail == "crash skipped: not running" {
+			skipped = true
+		}
+	}
+	if !skipped {
+		t.Error("Expected crashing a stopped node to be skipped")
+	}
+
+	// With two of three nodes down no operation can complete
+	for _, op := range result.History {
+		if op.Status == checker.StatusOK && op.Return > 2*time.Second && op.Return < 3*time.Second {
+			t.Errorf("Operation completed without a quorum: %v", op)
+		}
+	}
+}
+
+func TestRunRestartKeepsDurableState(t *testing.T) {
+	for _, algorithm := range []string{"raft", "paxos"} {
+		result := mustRun(t, mustParse(t, fmt.Sprintf(`
+algorithm: %s
+nodes: 3
+duration: 6s
+workload: {kind: counter, clients: 2, read_ratio: 0.5, stop: 5s}
+timeline:
+  - {at: 0s, action: wait_for_leader, timeout: 2s}
+  - {at: 2s, action: crash, nodes: [node-1, node-2, node-3]}
+  - {at: 3s, action: restart, nodes: [node-1, node-2, node-3]}
+  - {at: 3s, action: wait_for_leader, timeout: 2s}
+assertions:
+  - kind: converged
+  - kind: linearizable
+  - kind: min_completed
+    min: 5
+`, algorithm)))
+		if !result.Passed {
+			t.Errorf("%s: expected the restarted cluster to keep its committed writes, got %v", algorithm, result.Violations)
+		}
+	}
+}
+
+func TestRunCrashLosesUnsyncedWrites(t *testing.T) {
+	doc := `
+nodes: 3
+duration: 6s
+config:
+  settings: {sync_interval: 1h}
+workload: {kind: register, clients: 2, read_ratio: 0.5}
+timeline:
+  - {at: 0s, action: wait_for_leader, timeout: 2s}
+  - {at: 2s, action: crash, nodes: [node-1, node-2, node-3], lose_unsynced: %v}
+  - {at: 3s, action: restart, nodes: [node-1, node-2, node-3]}
+  - {at: 3s, action: wait_for_leader, timeout: 2s}
+assertions:
+  - kind: linearizable
+`
+	if result := mustRun(t, mustParse(t, fmt.Sprintf(doc, false))); !result.Passed {
+		t.Fatalf("A clean stop syncs every write, got %v", result.Violations)
+	}
+
+	result := mustRun(t, mustParse(t, fmt.Sprintf(doc, true)))
+	if result.Passed {
+		t.Fatal("Expected acknowledged writes lost in the crash to break linearizability")
+	}
+	lost := false
+	for _, e := range result.Events {
+		if e.Kind == EventAction && e.Detail == "crash, unsynced writes lost" {
+			lost = true
+		}
+	}
+	if !lost {
+		t.Error("Expected crash events to record the lost writes")
+	}
+}
+
+func TestRunDiskFaults(t *testing.T) {
+	// Entries damaged on disk come back when the node restarts
+	result := mustRun(t, mustParse(t, `
+nodes: 3
+duration: 4s
+workload: {kind: register, clients: 2}
+timeline:
+  - {at: 0s, action: wait_for_leader, timeout: 2s}
+  - {at: 0s, action: set_disk, nodes: [node-1], disk: {bit_rot: 0.5}}
+  - {at: 2s, action: crash, nodes: [node-1]}
+  - {at: 2500ms, action: restart, nodes: [node-1]}
+`))
+	if len(result.Violations) == 0 || result.Violations[0].Kind != checker.StateMachineSafety {
+		t.Errorf("Expected bit rot to break state machine safety, got %v", result.Violations)
+	}
+	faults := 0
+	for _, e := range result.Events {
+		if e.Kind == EventFault && e.Node == "node-1" {
+			faults++
+		}
+	}
+	if faults == 0 {
+		t.Error("Expected the flipped bits to be recorded")
+	}
+
+	// A node whose sync fails stops, and comes back on restart
+	for _, algorithm := range []string{"raft", "paxos"} {
+		result = mustRun(t, mustParse(t, fmt.Sprintf(`
+algorithm: %s
+nodes: 3
+duration: 4s
+workload: {kind: register, clients: 2, stop: 3s}
+disk: {latency: 2ms, latency_jitter: 1ms}
+timeline:
+  - {at: 0s, action: wait_for_leader, timeout: 2s}
+  - {at: 1s, action: set_disk, nodes: [node-1], disk: {sync_failure: 1}}
+  - {at: 2s, action: set_disk, nodes: [node-1], disk: {}}
+  - {at: 2s, action: restart, nodes: [node-1]}
+assertions:
+  - kind: converged
+  - kind: linearizable
+`, algorithm)))
+		if !result.Passed {
+			t.Errorf("%s: expected the cluster to survive a failing disk, got %v", algorithm, result.Violations)
+		}
+		stopped, restarted := false, false
+		for _, e := range result.Events {
+			if e.Kind == EventAction && e.Node == "node-1" {
+				stopped = stopped || e.Detail == "stopped on its own"
+				restarted = restarted || (stopped && e.Detail == "restart")
+			}
+		}
+		if !stopped || !restarted {
+			t.Errorf("%s: expected node-1 to stop on a failed sync and restart", algorithm)
+		}
+	}
+}
+
+func TestRunSnapshots(t *testing.T) {
+	// node-3 misses more than the leaders keep in their logs, so it
+	// catches up through a snapshot sent over a lossy, slow network
+	s := mustParse(t, `
+nodes: 3
+duration: 10s
+config:
+  settings: {snapshot_threshold: 20, snapshot_chunk_size: 256}
+network: {packet_loss: 0.05, bandwidth: 20000, queue_limit: 4096}
+workload: {kind: kv, clients: 3, keys: 4, stop: 8s}
+timeline:
+  - {at: 0s, action: wait_for_leader, timeout: 2s}
+  - {at: 1s, action: crash, nodes: [node-3]}
+  - {at: 5s, action: restart, nodes: [node-3]}
+  - {at: 7s, action: crash, nodes: [node-1, node-2, node-3]}
+  - {at: 7500ms, action: restart, nodes: [node-1, node-2, node-3]}
+assertions:
+  - kind: converged
+  - kind: linearizable
+`)
+	result, err := Run(context.Background(), s, Options{TraceMessages: true})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !result.Passed {
+		t.Fatalf("Expected the cluster to stay correct across snapshots, got %v", result.Violations)
+	}
+	chunks := 0
+	for _, e := range result.Events {
+		if e.Kind == EventDeliver && e.Node == "node-3" && strings.HasPrefix(e.Detail, "InstallSnapshot ") {
+			chunks++
+		}
+	}
+	if chunks < 2 {
+		t.Errorf("Expected node-3 to receive a snapshot in chunks, got %d chunks", chunks)
+	}
+}
+
+func TestRunMembershipChanges(t *testing.T) {
+	// Two spares join, one proposed to the old leader while it is cut off,
+	// and a founding node leaves, all under client load
+	s := mustParse(t, `
+nodes: 3
+spares: 2
+duration: 12s
+network: {packet_loss: 0.02}
+workload: {kind: kv, clients: 3, keys: 4, stop: 10s}
+timeline:
+  - {at: 0s, action: wait_for_leader, timeout: 2s}
+  - {at: 1s, action: add_learner, nodes: [node-4]}
+  - {at: 2s, action: promote, nodes: [node-4]}
+  - {at: 3s, action: isolate_leader}
+  - {at: 4s, action: add_voter, nodes: [node-5]}
+  - {at: 5s, action: heal}
+  - {at: 6s, action: remove_node, nodes: [node-1]}
+  - {at: 7s, action: crash, nodes: [node-2]}
+  - {at: 8s, action: restart, nodes: [node-2]}
+assertions:
+  - kind: leader
+  - kind: converged
+  - kind: linearizable
+`)
+	result := mustRun(t, s)
+	if !result.Passed {
+		t.Fatalf("Expected the cluster to stay correct across membership changes, got %v", result.Violations)
+	}
+
+	var actions []string
+	for _, e := range result.Events {
+		if e.Kind == EventAction {
+			actions = append(actions, e.Node+": "+e.Detail)
+		}
+	}
+	log := strings.Join(actions, "\n")
+	for _, want := range []string{"node-4: join", "node-5: join", "node-4: promote proposed", "node-1: removed from the cluster"} {
+		if !strings.Contains(log, want) {
+			t.Errorf("Expected %q among the actions:\n%s", want, log)
+		}
+	}
+}
+
+func TestRunReportsViolations(t *testing.T) {
+	result := mustRun(t, mustParse(t, `
+nodes: 3
+duration: 3s
+workload: {kind: counter, clients: 1}
+timeline:
+  - {at: 0s, action: partition, groups: [[node-1], [node-2], [node-3]]}
+  - {at: 0s, action: wait_for_leader, timeout: 1s}
+assertions:
+  - kind: leader
+  - kind: min_completed
+    min: 1
+`))
+	if result.Passed {
+		t.Fatal("Expected a fully partitioned cluster to fail")
+	}
+
+	kinds := map[string]bool{}
+	for _, v := range result.Violations {
+		kinds[v.Kind] = true
+	}
+	for _, kind := range []string{"wait_for_leader", "leader", "min_completed"} {
+		if !kinds[kind] {
+			t.Errorf("Expected a %s violation, got %v", kind, result.Violations)
+		}
+	}
+	if result.Stats.Completed != 0 || result.Stats.Operations == 0 {
+		t.Errorf("Expected only failed or unknown operations, got %+v", result.Stats)
+	}
+}
+
+func TestRunStreamsEvents(t *testing.T) {
+	s := mustParse(t, `
+duration: 1s
+timeline:
+  - {at: 500ms, action: isolate, nodes: [node-2]}
+`)
+	var streamed []Event
+	result, err := Run(context.Background(), s, Options{OnEvent: func(e Event) { streamed = append(streamed, e) }})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(streamed) != len(result.Events) || len(streamed) == 0 {
+		t.Fatalf("Expected %d streamed events, got %d", len(result.Events), len(streamed))
+	}
+	if streamed[0].At != 500*time.Millisecond || streamed[0].Node != "node-2" {
+		t.Errorf("Unexpected event %v", streamed[0])
+	}
+}
+
+func TestRunUnknownAlgorithm(t *testing.T) {
+	s := mustParse(t, "algorithm: zab\n")
+	if _, err := Run(context.Background(), s, Options{}); err == nil {
+		t.Error("Expected error for an unknown algorithm")
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Run(ctx, mustParse(t, "duration: 1s\n"), Options{}); err == nil {
+		t.Error("Expected error for a cancelled run")
+	}
+}
+
+func TestRunTracesMessages(t *testing.T) {
+	s := mustParse(t, "duration: 1s\n")
+	result, err := Run(context.Background(), s, Options{TraceMessages: true})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	delivered := 0
+	for i, e := range result.Events {
+		if e.Seq != i+1 {
+			t.Fatalf("Expected event %d to have sequence number %d, got %d", i, i+1, e.Seq)
+		}
+		if e.Kind == EventDeliver {
+			delivered++
+		}
+	}
+	if int64(delivered) != result.Stats.Network.MessagesSent {
+		t.Errorf("Expected %d delivery events, got %d", result.Stats.Network.MessagesSent, delivered)
+	}
+}
+
+func TestCheckLinearizable(t *testing.T) {
+	one, two := int64(1), int64(2)
+	w := &workload{
+		spec: Workload{Kind: WorkloadRegister},
+		history: []checker.Operation{
+			{ID: 1, Client: 0, Kind: checker.OpWrite, Key: "register", Input: 1, Status: checker.StatusOK, Invoke: 0, Return: 10},
+			{ID: 2, Client: 1, Kind: checker.OpWrite, Key: "register", Input: 2, Status: checker.StatusInfo, Invoke: 5, Return: 50},
+			{ID: 3, Client: 0, Kind: checker.OpRead, Key: "register", Output: &two, Status: checker.StatusOK, Invoke: 60, Return: 70},
+		},
+	}
+	if result := w.checkLinearizable(); !result.Linearizable {
+		t.Errorf("An unknown write may have taken effect, got %v", result)
+	}
+
+	// Reading the older value after the newer one was seen is not
+	w.history = append(w.history, checker.Operation{ID: 4, Client: 1, Kind: checker.OpRead, Key: "register",
+		Output: &one, Status: checker.StatusOK, Invoke: 80, Return: 90})
+	result := w.checkLinearizable()
+	if result.Linearizable {
+		t.Fatal("Expected a linearizability violation")
+	}
+	if len(result.Counterexample) == 0 {
+		t.Error("Expected a counterexample")
+	}
+}
+
+// Raft nodes that all claim to lead term 1 when observed
+type splitBrain struct{}
+
+func (splitBrain) Name() string { return "split-brain" }
+
+func (splitBrain) CreateNode(id string, cfg config.Config) (consensus.Node, error) {
+	node, err := raft.NewNode(id, cfg, consensus.Environment{})
+	return &splitBrainNode{node}, err
+}
+
+type splitBrainNode struct {
+	*raft.Node
+}
+
+func (n *splitBrainNode) Observe() consensus.Observation {
+	observation := n.Node.Observe()
+	observation.State, observation.Term = consensus.StateLeader, 1
+	return observation
+}
+
+func TestRunChecksInvariants(t *testing.T) {
+	registry := consensus.NewRegistry()
+	registry.Register(splitBrain{})
+
+	s := mustParse(t, "algorithm: split-brain\nduration: 1s\n")
+	result, err := Run(context.Background(), s, Options{Registry: registry})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(result.Violations) != 1 || result.Violations[0].Kind != checker.ElectionSafety {
+		t.Fatalf("Expected one election safety violation, got %v", result.Violations)
+	}
+	if v := result.Violations[0]; v.At != 0 || !strings.Contains(v.Message, "node-1 and node-2") {
+		t.Errorf("Expected the violation to be flagged at once, got %v", v)
+	}
+}
@@ -0,0 +1,437 @@
+// Package scenario defines reproducible test scenarios: a cluster, a
+// client workload, a timeline of injected faults and the assertions the
+// run must satisfy. Scenarios are written in YAML.
+package scenario
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/config"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/network"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/storage"
+)
+
+// Defaults applied to fields a scenario leaves empty
+const (
+	DefaultAlgorithm = "raft"
+	DefaultNodes     = 3
+	DefaultDuration  = 10 * time.Second
+)
+
+type Scenario struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+
+	Algorithm string        `yaml:"algorithm"`
+	Nodes     int           `yaml:"nodes"`
+	Seed      uint64        `yaml:"seed"`
+	Duration  time.Duration `yaml:"duration"` // virtual time the run lasts
+
+	// Nodes outside the cluster when it starts, numbered after Nodes,
+	// for membership steps to add
+	Spares int `yaml:"spares,omitempty"`
+
+	Config ConfigOverrides `yaml:"config,omitempty"`
+
+	// Conditions applied to every link when the cluster starts
+	Network *network.NetworkConditions `yaml:"network,omitempty"`
+
+	// Conditions applied to every node's storage when the cluster starts
+	Disk *storage.DiskConditions `yaml:"disk,omitempty"`
+
+	Workload   Workload    `yaml:"workload"`
+	Timeline   []Step      `yaml:"timeline"`
+	Assertions []Assertion `yaml:"assertions"`
+}
+
+// Overrides applied on top of the node configuration. Zero values leave
+// the configuration unchanged.
+type ConfigOverrides struct {
+	ElectionTimeout   time.Duration          `yaml:"election_timeout,omitempty"`
+	HeartbeatInterval time.Duration          `yaml:"heartbeat_interval,omitempty"`
+	DataDir           string                 `yaml:"data_dir,omitempty"`
+	Settings          map[string]interface{} `yaml:"settings,omitempty"`
+}
+
+// Apply returns cfg with the overrides applied
+func (o ConfigOverrides) Apply(cfg config.Config) config.Config {
+	if o.ElectionTimeout > 0 {
+		cfg.ElectionTimeout = o.ElectionTimeout
+	}
+	if o.HeartbeatInterval > 0 {
+		cfg.HeartbeatInterval = o.HeartbeatInterval
+	}
+	if o.DataDir != "" {
+		cfg.DataDir = o.DataDir
+	}
+
+	settings := make(map[string]interface{}, len(cfg.Settings)+len(o.Settings))
+	for key, value := range cfg.Settings {
+		settings[key] = value
+	}
+	for key, value := range o.Settings {
+		settings[key] = value
+	}
+	cfg.Settings = settings
+	return cfg
+}
+
+// Defines the data model clients operate on
+type WorkloadKind string
+
+const (
+	WorkloadNone     WorkloadKind = "none"
+	WorkloadRegister WorkloadKind = "register" // reads and writes of a single register
+	WorkloadKV       WorkloadKind = "kv"       // reads and writes over a set of keys
+	WorkloadCounter  WorkloadKind = "counter"  // reads and increments of a counter
+)
+
+// Describes the client operations issued during a run. Each client has at
+// most one operation outstanding at a time.
+type Workload struct {
+	Kind      WorkloadKind  `yaml:"kind"`
+	Clients   int           `yaml:"clients"`
+	Interval  time.Duration `yaml:"interval"`   // pause between a client's operations
+	Timeout   time.Duration `yaml:"timeout"`    // how long a client waits for a result
+	Keys      int           `yaml:"keys"`       // number of keys, kv only
+	ReadRatio float64       `yaml:"read_ratio"` // 0.0 to 1.0
+	Start     time.Duration `yaml:"start"`      // when clients start
+	Stop      time.Duration `yaml:"stop"`       // when clients stop, 0 = end of run
+}
+
+// Defines a fault or control action on the timeline
+type Action string
+
+const (
+	// Splits the cluster into Groups, or into a generated Topology
+	ActionPartition Action = "partition"
+	// Removes the Partition named, or every partition when empty
+	ActionHeal Action = "heal"
+	// Cuts Nodes off from the rest of the cluster
+	ActionIsolate Action = "isolate"
+	// Cuts the current leader off from the rest of the cluster
+	ActionIsolateLeader Action = "isolate_leader"
+	// Stops messages From one node To another, but not the reverse
+	ActionCutLink Action = "cut_link"
+	// Sets the Conditions of the link From one node To another, or of
+	// every link when both are empty
+	ActionSetLink Action = "set_link"
+	// Applies Clock conditions to Nodes
+	ActionSetClock Action = "set_clock"
+	// Applies Disk conditions to the storage of Nodes. They last across
+	// restarts, like the disk itself.
+	ActionSetDisk Action = "set_disk"
+	// Stops Nodes, losing writes they have not synced when LoseUnsynced
+	// is set
+	ActionCrash Action = "crash"
+	// Starts crashed Nodes again from what they persisted
+	ActionRestart Action = "restart"
+	// Holds the timeline until a leader is elected or Timeout passes
+	ActionWaitForLeader Action = "wait_for_leader"
+
+	// Membership changes, proposed through the leader for the one node
+	// in Nodes. A node added to the cluster is started once the leader
+	// accepts the change; a removed node is stopped once it is committed.
+	ActionAddLearner Action = "add_learner"
+	ActionAddVoter   Action = "add_voter"
+	ActionPromote    Action = "promote"
+	ActionRemoveNode Action = "remove_node"
+)
+
+var actions = map[Action]bool{
+	ActionPartition:     true,
+	ActionHeal:          true,
+	ActionIsolate:       true,
+	ActionIsolateLeader: true,
+	ActionCutLink:       true,
+	ActionSetLink:       true,
+	ActionSetClock:      true,
+	ActionSetDisk:       true,
+	ActionCrash:         true,
+	ActionRestart:       true,
+	ActionWaitForLeader: true,
+	ActionAddLearner:    true,
+	ActionAddVoter:      true,
+	ActionPromote:       true,
+	ActionRemoveNode:    true,
+}
+
+// Partition shapes generated from the node list and the scenario seed
+const (
+	TopologyHalves       = "halves"
+	TopologyBridge       = "bridge"
+	TopologyMajorityRing = "majority_ring"
+	TopologyMinority     = "minority"
+)
+
+// A timed action. Which fields apply depends on the action.
+type Step struct {
+	At     time.Duration `yaml:"at"`
+	Action Action        `yaml:"action"`
+
+	Nodes     []string      `yaml:"nodes,omitempty"`
+	Groups    [][]string    `yaml:"groups,omitempty"`
+	Topology  string        `yaml:"topology,omitempty"`
+	Partition string        `yaml:"partition,omitempty"`
+	From      string        `yaml:"from,omitempty"`
+	To        string        `yaml:"to,omitempty"`
+	Timeout   time.Duration `yaml:"timeout,omitempty"`
+
+	LoseUnsynced bool `yaml:"lose_unsynced,omitempty"`
+
+	Conditions *network.NetworkConditions `yaml:"conditions,omitempty"`
+	Clock      *network.ClockConditions   `yaml:"clock,omitempty"`
+	Disk       *storage.DiskConditions    `yaml:"disk,omitempty"`
+}
+
+// Defines a property checked when the run ends
+type AssertionKind string
+
+const (
+	// A leader is known when the run ends
+	AssertLeader AssertionKind = "leader"
+	// Every running node applied the same commands
+	AssertConverged AssertionKind = "converged"
+	// At least Min client operations completed
+	AssertMinCompleted AssertionKind = "min_completed"
+	// The client history is linearizable for the workload's data model
+	AssertLinearizable AssertionKind = "linearizable"
+)
+
+var assertionKinds = map[AssertionKind]bool{
+	AssertLeader:       true,
+	AssertConverged:    true,
+	AssertMinCompleted: true,
+	AssertLinearizable: true,
+}
+
+type Assertion struct {
+	Kind AssertionKind `yaml:"kind"`
+	Min  int           `yaml:"min,omitempty"`
+}
+
+// Load reads and validates a scenario file
+func Load(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Parse decodes and validates a scenario. Unknown fields are rejected so
+// that typos do not silently disable a fault.
+func Parse(data []byte) (*Scenario, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var s Scenario
+	if err := decoder.Decode(&s); err != nil {
+		return nil, fmt.Errorf("parse scenario: %w", err)
+	}
+	s.applyDefaults()
+
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Marshal encodes the scenario as YAML
+func (s *Scenario) Marshal() ([]byte, error) {
+	return yaml.Marshal(s)
+}
+
+func (s *Scenario) applyDefaults() {
+	if s.Algorithm == "" {
+		s.Algorithm = DefaultAlgorithm
+	}
+	if s.Nodes == 0 {
+		s.Nodes = DefaultNodes
+	}
+	if s.Duration == 0 {
+		s.Duration = DefaultDuration
+	}
+
+	w := &s.Workload
+	if w.Kind == "" {
+		w.Kind = WorkloadNone
+	}
+	if w.Kind != WorkloadNone {
+		if w.Clients == 0 {
+			w.Clients = 1
+		}
+		if w.Interval == 0 {
+			w.Interval = 10 * time.Millisecond
+		}
+		if w.Timeout == 0 {
+			w.Timeout = time.Second
+		}
+		if w.Kind == WorkloadKV && w.Keys == 0 {
+			w.Keys = 1
+		}
+	}
+}
+
+// NodeIDs returns the IDs of the nodes the cluster starts with
+func (s *Scenario) NodeIDs() []string {
+	ids := make([]string, s.Nodes)
+	for i := range ids {
+		ids[i] = NodeID(i + 1)
+	}
+	return ids
+}
+
+// SpareIDs returns the IDs of the spare nodes
+func (s *Scenario) SpareIDs() []string {
+	ids := make([]string, max(s.Spares, 0))
+	for i := range ids {
+		ids[i] = NodeID(s.Nodes + i + 1)
+	}
+	return ids
+}
+
+// NodeID returns the ID of the nth node, counting from 1
+func NodeID(n int) string {
+	return fmt.Sprintf("node-%d", n)
+}
+
+// Validate checks the scenario for mistakes and reports all of them
+func (s *Scenario) Validate() error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if s.Nodes < 1 {
+		fail("nodes must be at least 1, got %d", s.Nodes)
+	}
+	if s.Spares < 0 {
+		fail("spares must not be negative, got %d", s.Spares)
+	}
+	if s.Duration < 0 {
+		fail("duration must not be negative")
+	}
+
+	known := make(map[string]bool)
+	for _, id := range append(s.NodeIDs(), s.SpareIDs()...) {
+		known[id] = true
+	}
+	checkNodes := func(prefix string, nodes ...string) {
+		for _, node := range nodes {
+			if !known[node] {
+				fail("%s: unknown node %q", prefix, node)
+			}
+		}
+	}
+
+	w := s.Workload
+	switch w.Kind {
+	case WorkloadNone, WorkloadRegister, WorkloadKV, WorkloadCounter:
+	default:
+		fail("workload: unknown kind %q", w.Kind)
+	}
+	if w.Clients < 0 || w.Keys < 0 || w.Interval < 0 || w.Timeout < 0 {
+		fail("workload: clients, keys, interval and timeout must not be negative")
+	}
+	if w.ReadRatio < 0 || w.ReadRatio > 1 {
+		fail("workload: read_ratio must be between 0 and 1, got %v", w.ReadRatio)
+	}
+	if w.Stop != 0 && w.Stop < w.Start {
+		fail("workload: stop must not be before start")
+	}
+
+	for i, step := range s.Timeline {
+		prefix := fmt.Sprintf("timeline[%d] (%s)", i, step.Action)
+		if step.At < 0 || step.At > s.Duration {
+			fail("%s: at %v is outside the run", prefix, step.At)
+		}
+		if !actions[step.Action] {
+			fail("timeline[%d]: unknown action %q", i, step.Action)
+			continue
+		}
+		checkNodes(prefix, step.Nodes...)
+		for _, group := range step.Groups {
+			checkNodes(prefix, group...)
+		}
+
+		if step.LoseUnsynced && step.Action != ActionCrash {
+			fail("%s: lose_unsynced only applies to crash", prefix)
+		}
+
+		switch step.Action {
+		case ActionPartition:
+			switch {
+			case len(step.Groups) > 0 && step.Topology != "":
+				fail("%s: set either groups or topology, not both", prefix)
+			case step.Topology != "":
+				switch step.Topology {
+				case TopologyHalves, TopologyBridge, TopologyMajorityRing, TopologyMinority:
+				default:
+					fail("%s: unknown topology %q", prefix, step.Topology)
+				}
+			case len(step.Groups) == 0:
+				fail("%s: groups or topology is required", prefix)
+			}
+		case ActionIsolate, ActionCrash, ActionRestart:
+			if len(step.Nodes) == 0 {
+				fail("%s: nodes is required", prefix)
+			}
+		case ActionSetClock:
+			if len(step.Nodes) == 0 || step.Clock == nil {
+				fail("%s: nodes and clock are required", prefix)
+			}
+		case ActionSetDisk:
+			if len(step.Nodes) == 0 || step.Disk == nil {
+				fail("%s: nodes and disk are required", prefix)
+			}
+		case ActionCutLink:
+			if step.From == "" || step.To == "" {
+				fail("%s: from and to are required", prefix)
+			}
+			checkNodes(prefix, step.From, step.To)
+		case ActionSetLink:
+			if step.Conditions == nil {
+				fail("%s: conditions is required", prefix)
+			}
+			if (step.From == "") != (step.To == "") {
+				fail("%s: set both from and to, or neither", prefix)
+			} else if step.From != "" {
+				checkNodes(prefix, step.From, step.To)
+			}
+		case ActionWaitForLeader:
+			if step.Timeout <= 0 {
+				fail("%s: timeout is required", prefix)
+			}
+		case ActionAddLearner, ActionAddVoter, ActionPromote, ActionRemoveNode:
+			if len(step.Nodes) != 1 {
+				fail("%s: nodes must name exactly one node", prefix)
+			}
+		}
+	}
+
+	for i, assertion := range s.Assertions {
+		if !assertionKinds[assertion.Kind] {
+			fail("assertions[%d]: unknown kind %q", i, assertion.Kind)
+		}
+		if assertion.Kind == AssertMinCompleted && assertion.Min < 1 {
+			fail("assertions[%d] (%s): min must be at least 1", i, assertion.Kind)
+		}
+		if assertion.Kind == AssertLinearizable && w.Kind == WorkloadNone {
+			fail("assertions[%d] (%s): requires a workload", i, assertion.Kind)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,168 @@
+package scenario
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/config"
+)
+
+func TestParseDefaults(t *testing.T) {
+	s, err := Parse([]byte("name: minimal\n"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if s.Algorithm != DefaultAlgorithm || s.Nodes != DefaultNodes || s.Duration != DefaultDuration {
+		t.Errorf("Expected defaults, got algorithm %s, %d nodes, %v", s.Algorithm, s.Nodes, s.Duration)
+	}
+	if s.Workload.Kind != WorkloadNone {
+		t.Errorf("Expected no workload, got %s", s.Workload.Kind)
+	}
+	if ids := s.NodeIDs(); len(ids) != 3 || ids[0] != "node-1" || ids[2] != "node-3" {
+		t.Errorf("Unexpected node IDs %v", ids)
+	}
+}
+
+func TestParseTimeline(t *testing.T) {
+	s, err := Parse([]byte(`
+nodes: 3
+duration: 5s
+network:
+  packet_loss: 0.1
+timeline:
+  - at: 1500ms
+    action: partition
+    groups: [[node-1], [node-2, node-3]]
+  - at: 2s
+    action: set_link
+    from: node-1
+    to: node-2
+    conditions:
+      corruption: 0.5
+      corruption_modes: [bit_flip, term]
+  - at: 3s
+    action: set_clock
+    nodes: [node-2]
+    clock:
+      offset: -200ms
+      jumps:
+        - after: 1s
+          by: 5s
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if s.Network.PacketLoss != 0.1 || s.Network.BaseLatency != time.Millisecond {
+		t.Errorf("Expected packet loss over default conditions, got %+v", s.Network)
+	}
+	if s.Timeline[0].At != 1500*time.Millisecond || len(s.Timeline[0].Groups) != 2 {
+		t.Errorf("Unexpected partition step %+v", s.Timeline[0])
+	}
+	conditions := s.Timeline[1].Conditions
+	if len(conditions.CorruptionModes) != 2 || conditions.CorruptionModes[1].String() != "term" {
+		t.Errorf("Unexpected corruption modes %v", conditions.CorruptionModes)
+	}
+	clock := s.Timeline[2].Clock
+	if clock.Offset != -200*time.Millisecond || clock.Jumps[0].By != 5*time.Second {
+		t.Errorf("Unexpected clock conditions %+v", clock)
+	}
+}
+
+func TestParseRejectsMistakes(t *testing.T) {
+	cases := map[string]string{
+		"unknown field":    "nodez: 3",
+		"unknown action":   "timeline: [{at: 1s, action: explode}]",
+		"unknown node":     "timeline: [{at: 1s, action: crash, nodes: [node-9]}]",
+		"step after end":   "duration: 1s\ntimeline: [{at: 2s, action: heal}]",
+		"missing groups":   "timeline: [{at: 1s, action: partition}]",
+		"bad topology":     "timeline: [{at: 1s, action: partition, topology: star}]",
+		"half a link":      "timeline: [{at: 1s, action: set_link, from: node-1, conditions: {}}]",
+		"no timeout":       "timeline: [{at: 1s, action: wait_for_leader}]",
+		"unknown workload": "workload: {kind: queue}",
+		"read ratio":       "workload: {kind: register, read_ratio: 2}",
+		"bad assertion":    "assertions: [{kind: happy}]",
+		"missing min":      "assertions: [{kind: min_completed}]",
+		"nothing to check": "assertions: [{kind: linearizable}]",
+		"lose on restart":  "timeline: [{at: 1s, action: restart, nodes: [node-1], lose_unsynced: true}]",
+		"no disk":          "timeline: [{at: 1s, action: set_disk, nodes: [node-1]}]",
+		"negative spares":  "spares: -1",
+		"no spare":         "timeline: [{at: 1s, action: add_voter, nodes: [node-4]}]",
+		"two nodes added":  "spares: 2\ntimeline: [{at: 1s, action: add_learner, nodes: [node-4, node-5]}]",
+		"nobody removed":   "timeline: [{at: 1s, action: remove_node}]",
+	}
+	for name, doc := range cases {
+		if _, err := Parse([]byte(doc)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestValidateReportsEveryError(t *testing.T) {
+	_, err := Parse([]byte(`
+timeline:
+  - {at: 1s, action: crash}
+  - {at: 1s, action: isolate, nodes: [node-7]}
+`))
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if !strings.Contains(err.Error(), "timeline[0]") || !strings.Contains(err.Error(), "timeline[1]") {
+		t.Errorf("Expected both steps to be reported, got %v", err)
+	}
+}
+
+func TestConfigOverrides(t *testing.T) {
+	overrides := ConfigOverrides{
+		ElectionTimeout: 300 * time.Millisecond,
+		Settings:        map[string]interface{}{"snapshot_threshold": 100},
+	}
+	base := config.DefaultConfig()
+	cfg := overrides.Apply(base)
+
+	if cfg.ElectionTimeout != 300*time.Millisecond {
+		t.Errorf("Expected election timeout override, got %v", cfg.ElectionTimeout)
+	}
+	if cfg.HeartbeatInterval != base.HeartbeatInterval {
+		t.Errorf("Expected heartbeat interval to be kept, got %v", cfg.HeartbeatInterval)
+	}
+	if cfg.Settings["snapshot_threshold"] != 100 {
+		t.Errorf("Expected settings override, got %v", cfg.Settings)
+	}
+	if len(base.Settings) != 0 {
+		t.Error("Apply should not modify the base configuration")
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	s, err := Load("../algorithms/raft/scenarios/asymmetric_partition.yaml")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	data, err := s.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	again, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse of marshalled scenario failed: %v\n%s", err, data)
+	}
+	if again.Timeline[2].Conditions.PacketLoss != 0.2 || again.Timeline[3].Clock.Drift != 0.05 {
+		t.Errorf("Round trip lost data:\n%s", data)
+	}
+}
+
+func TestCommittedScenariosAreValid(t *testing.T) {
+	paths, _ := filepath.Glob("../algorithms/*/scenarios/*.yaml")
+	if len(paths) == 0 {
+		t.Fatal("Expected scenario files alongside the algorithms")
+	}
+	for _, path := range paths {
+		if _, err := Load(path); err != nil {
+			t.Errorf("%v", err)
+		}
+	}
+}
@@ -0,0 +1,386 @@
+package scenario
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/config"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/network"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/storage"
+)
+
+// Default number of runs a shrink may spend
+const DefaultShrinkRuns = 500
+
+// A run is not shortened to end less than this many election timeouts
+// after its last step, so a failure at the end of a run cannot shrink
+// into a run too short for the cluster to elect a leader at all
+const shrinkSettleTimeouts = 10
+
+type ShrinkOptions struct {
+	MaxRuns int // 0 uses DefaultShrinkRuns
+
+	// Called for every simplification that still reproduces the violation
+	OnProgress func(desc string)
+
+	// Passed to every run
+	Run Options
+}
+
+// The outcome of a shrink
+type ShrinkResult struct {
+	Scenario  *Scenario // smallest scenario found that still fails
+	Violation Violation // the violation it reproduces
+	Runs      int
+	Applied   []string // simplifications kept, in order
+}
+
+// Shrink repeatedly simplifies a failing scenario, keeping every change
+// after which a run still reports a violation of the given kind. It
+// removes timeline steps, shortens the run, thins out the workload and
+// drops network, disk and clock faults until no single simplification keeps
+// the failure or the run budget is spent.
+func Shrink(ctx context.Context, s *Scenario, kind string, opts ShrinkOptions) (*ShrinkResult, error) {
+	budget := opts.MaxRuns
+	if budget == 0 {
+		budget = DefaultShrinkRuns
+	}
+
+	result := &ShrinkResult{}
+	reproduce := func(candidate *Scenario) (Violation, bool, error) {
+		result.Runs++
+		run, err := Run(ctx, candidate, opts.Run)
+		if err != nil {
+			return Violation{}, false, err
+		}
+		for _, v := range run.Violations {
+			if v.Kind == kind {
+				return v, true, nil
+			}
+		}
+		return Violation{}, false, nil
+	}
+
+	current, err := cloneScenario(s)
+	if err != nil {
+		return nil, err
+	}
+	violation, ok, err := reproduce(current)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("scenario does not fail with a %s violation", kind)
+	}
+
+	for progress := true; progress && result.Runs < budget; {
+		progress = false
+		for _, simplification := range simplifications(current, violation.At) {
+			if result.Runs >= budget {
+				break
+			}
+
+			candidate, err := cloneScenario(current)
+			if err != nil {
+				return nil, err
+			}
+			simplification.apply(candidate)
+			if candidate.Validate() != nil {
+				continue
+			}
+
+			v, ok, err := reproduce(candidate)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+
+			current, violation = candidate, v
+			result.Applied = append(result.Applied, simplification.desc)
+			if opts.OnProgress != nil {
+				opts.OnProgress(simplification.desc)
+			}
+			// Simplifications are generated from the scenario, so start
+			// over with the smaller one
+			progress = true
+			break
+		}
+	}
+
+	result.Scenario = current
+	result.Violation = violation
+	return result, nil
+}
+
+// A change that makes a scenario simpler
+type simplification struct {
+	desc  string
+	apply func(s *Scenario)
+}
+
+// Lists the simplifications of s, the most drastic first
+func simplifications(s *Scenario, violationAt time.Duration) []simplification {
+	var list []simplification
+	add := func(desc string, apply func(s *Scenario)) {
+		list = append(list, simplification{desc: desc, apply: apply})
+	}
+
+	// Remove timeline steps in shrinking chunks
+	for size := len(s.Timeline); size >= 1; size /= 2 {
+		for start := 0; start+size <= len(s.Timeline); start += size {
+			start, end := start, start+size
+			desc := fmt.Sprintf("remove timeline steps %d-%d", start, end-1)
+			if size == 1 {
+				desc = fmt.Sprintf("remove timeline step %d (%s at %v)", start, s.Timeline[start].Action, s.Timeline[start].At)
+			}
+			add(desc, func(s *Scenario) {
+				s.Timeline = append(s.Timeline[:start:start], s.Timeline[end:]...)
+			})
+		}
+	}
+
+	// End the run soon after a violation found during it, or halve it
+	// while the cluster still has time to settle after the last step
+	var durations []time.Duration
+	if violationAt < s.Duration {
+		durations = append(durations, violationAt.Truncate(100*time.Millisecond)+100*time.Millisecond)
+	}
+	settle := shrinkSettleTimeouts * s.Config.Apply(config.DefaultConfig()).ElectionTimeout
+	var last time.Duration
+	for _, step := range s.Timeline {
+		if step.At > last {
+			last = step.At
+		}
+	}
+	if s.Duration/2 >= last+settle {
+		durations = append(durations, s.Duration/2)
+	}
+	for _, d := range durations {
+		d := d
+		if d >= s.Duration {
+			continue
+		}
+		add(fmt.Sprintf("shorten the run to %v", d), func(s *Scenario) {
+			s.setDuration(d)
+		})
+	}
+
+	// Thin out the workload
+	w := s.Workload
+	if w.Kind != WorkloadNone {
+		add("remove the workload", func(s *Scenario) {
+			s.Workload = Workload{Kind: WorkloadNone}
+		})
+		if w.Clients > 1 {
+			add(fmt.Sprintf("use %d clients", w.Clients/2), func(s *Scenario) {
+				s.Workload.Clients /= 2
+			})
+		}
+		if w.Keys > 1 {
+			add(fmt.Sprintf("use %d keys", w.Keys/2), func(s *Scenario) {
+				s.Workload.Keys /= 2
+			})
+		}
+		if w.ReadRatio > 0 {
+			add("remove reads", func(s *Scenario) {
+				s.Workload.ReadRatio = 0
+			})
+		}
+		if w.Interval < s.Duration/2 {
+			add(fmt.Sprintf("issue operations every %v", w.Interval*2), func(s *Scenario) {
+				s.Workload.Interval *= 2
+			})
+		}
+	}
+
+	// Drop network noise, everywhere and then per field
+	if s.Network != nil {
+		add("use default network conditions", func(s *Scenario) {
+			s.Network = nil
+		})
+		for _, c := range simplifyConditions(*s.Network) {
+			c := c
+			add(c.desc+" on every link", func(s *Scenario) {
+				c.apply(s.Network)
+			})
+		}
+	}
+
+	// Drop disk faults
+	if s.Disk != nil {
+		add("use a reliable disk", func(s *Scenario) {
+			s.Disk = nil
+		})
+		for _, c := range simplifyDisk(*s.Disk) {
+			c := c
+			add(c.desc+" on every disk", func(s *Scenario) {
+				c.apply(s.Disk)
+			})
+		}
+	}
+	for i, step := range s.Timeline {
+		i := i
+		if step.Disk != nil {
+			for _, c := range simplifyDisk(*step.Disk) {
+				c := c
+				add(fmt.Sprintf("%s in timeline step %d", c.desc, i), func(s *Scenario) {
+					c.apply(s.Timeline[i].Disk)
+				})
+			}
+		}
+		if step.Conditions != nil {
+			for _, c := range simplifyConditions(*step.Conditions) {
+				c := c
+				add(fmt.Sprintf("%s in timeline step %d", c.desc, i), func(s *Scenario) {
+					c.apply(s.Timeline[i].Conditions)
+				})
+			}
+		}
+		if step.Clock != nil {
+			clock := *step.Clock
+			if len(clock.Jumps) > 0 {
+				add(fmt.Sprintf("remove clock jumps in timeline step %d", i), func(s *Scenario) {
+					s.Timeline[i].Clock.Jumps = nil
+				})
+			}
+			if clock.Offset != 0 {
+				add(fmt.Sprintf("remove clock offset in timeline step %d", i), func(s *Scenario) {
+					s.Timeline[i].Clock.Offset = 0
+				})
+			}
+			if clock.Drift != 0 {
+				add(fmt.Sprintf("remove clock drift in timeline step %d", i), func(s *Scenario) {
+					s.Timeline[i].Clock.Drift = 0
+				})
+			}
+		}
+		if step.LoseUnsynced {
+			add(fmt.Sprintf("keep unsynced writes in timeline step %d", i), func(s *Scenario) {
+				s.Timeline[i].LoseUnsynced = false
+			})
+		}
+		if len(step.Nodes) > 1 {
+			for j, node := range step.Nodes {
+				j := j
+				add(fmt.Sprintf("drop %s from timeline step %d", node, i), func(s *Scenario) {
+					nodes := s.Timeline[i].Nodes
+					s.Timeline[i].Nodes = append(nodes[:j:j], nodes[j+1:]...)
+				})
+			}
+		}
+	}
+
+	// Use fewer nodes; this only validates if no step names the last node
+	if s.Nodes > 1 {
+		add(fmt.Sprintf("use %d nodes", s.Nodes-1), func(s *Scenario) {
+			s.Nodes--
+		})
+	}
+	return list
+}
+
+// A change that removes one kind of noise from link conditions
+type conditionsChange struct {
+	desc  string
+	apply func(c *network.NetworkConditions)
+}
+
+// Lists the noise c can be rid of
+func simplifyConditions(c network.NetworkConditions) []conditionsChange {
+	var list []conditionsChange
+	add := func(enabled bool, desc string, apply func(c *network.NetworkConditions)) {
+		if enabled {
+			list = append(list, conditionsChange{desc: desc, apply: apply})
+		}
+	}
+
+	defaults := network.DefaultNetworkConditions()
+	add(c.PacketLoss > 0, "remove packet loss", func(c *network.NetworkConditions) {
+		c.PacketLoss = 0
+	})
+	add(c.Duplication > 0, "remove duplication", func(c *network.NetworkConditions) {
+		c.Duplication = 0
+	})
+	add(c.Reorder > 0 && c.ReorderWindow > 0, "remove reordering", func(c *network.NetworkConditions) {
+		c.Reorder, c.ReorderWindow = 0, 0
+	})
+	add(c.Corruption > 0, "remove corruption", func(c *network.NetworkConditions) {
+		c.Corruption, c.CorruptionModes = 0, nil
+	})
+	add(c.LatencyJitter > 0, "remove latency jitter", func(c *network.NetworkConditions) {
+		c.LatencyJitter = 0
+	})
+	add(c.Bandwidth > 0, "remove the bandwidth limit", func(c *network.NetworkConditions) {
+		c.Bandwidth, c.BandwidthBurst, c.QueueLimit = 0, 0, 0
+	})
+	add(c.BaseLatency != defaults.BaseLatency, "use the default latency", func(c *network.NetworkConditions) {
+		c.BaseLatency = defaults.BaseLatency
+	})
+	return list
+}
+
+// A change that removes one kind of fault from disk conditions
+type diskChange struct {
+	desc  string
+	apply func(c *storage.DiskConditions)
+}
+
+// Lists the faults c can be rid of
+func simplifyDisk(c storage.DiskConditions) []diskChange {
+	var list []diskChange
+	add := func(enabled bool, desc string, apply func(c *storage.DiskConditions)) {
+		if enabled {
+			list = append(list, diskChange{desc: desc, apply: apply})
+		}
+	}
+
+	add(c.SyncFailure > 0, "remove sync failures", func(c *storage.DiskConditions) {
+		c.SyncFailure = 0
+	})
+	add(c.TornWrite > 0, "remove torn writes", func(c *storage.DiskConditions) {
+		c.TornWrite = 0
+	})
+	add(c.BitRot > 0, "remove bit rot", func(c *storage.DiskConditions) {
+		c.BitRot = 0
+	})
+	add(c.Latency > 0 || c.LatencyJitter > 0, "remove disk latency", func(c *storage.DiskConditions) {
+		c.Latency, c.LatencyJitter = 0, 0
+	})
+	return list
+}
+
+// Ends the run at d, dropping the steps that would no longer run
+func (s *Scenario) setDuration(d time.Duration) {
+	s.Duration = d
+	timeline := s.Timeline[:0]
+	for _, step := range s.Timeline {
+		if step.At <= d {
+			timeline = append(timeline, step)
+		}
+	}
+	s.Timeline = timeline
+	if s.Workload.Start > d {
+		s.Workload = Workload{Kind: WorkloadNone}
+	}
+	if s.Workload.Stop > d {
+		s.Workload.Stop = 0
+	}
+}
+
+// Returns a deep copy of s
+func cloneScenario(s *Scenario) (*Scenario, error) {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	var clone Scenario
+	if err := yaml.Unmarshal(data, &clone); err != nil {
+		return nil, err
+	}
+	return &clone, nil
+}
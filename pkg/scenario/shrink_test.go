@@ -0,0 +1,91 @@
+package scenario
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestShrink(t *testing.T) {
+	s := mustParse(t, `
+seed: 11
+duration: 4s
+network: {base_latency: 5ms, packet_loss: 0.05}
+workload: {kind: kv, keys: 4, clients: 3, read_ratio: 0.5}
+timeline:
+  - {at: 0s, action: partition, groups: [[node-1], [node-2], [node-3]]}
+  - {at: 200ms, action: set_link, conditions: {packet_loss: 0.2, duplication: 0.1}}
+  - {at: 300ms, action: set_clock, nodes: [node-2], clock: {drift: 0.05}}
+  - {at: 500ms, action: isolate, nodes: [node-1, node-3]}
+  - {at: 1s, action: cut_link, from: node-2, to: node-3}
+assertions:
+  - kind: leader
+`)
+
+	var progress []string
+	result, err := Shrink(context.Background(), s, string(AssertLeader), ShrinkOptions{
+		OnProgress: func(desc string) { progress = append(progress, desc) },
+	})
+	if err != nil {
+		t.Fatalf("Shrink failed: %v", err)
+	}
+
+	shrunk := result.Scenario
+	if len(shrunk.Timeline) != 1 || shrunk.Timeline[0].Action != ActionPartition {
+		t.Errorf("Expected only the partition to remain, got %+v", shrunk.Timeline)
+	}
+	if shrunk.Workload.Kind != WorkloadNone {
+		t.Errorf("Expected the workload to be removed, got %+v", shrunk.Workload)
+	}
+	if shrunk.Network != nil {
+		t.Errorf("Expected default network conditions, got %+v", *shrunk.Network)
+	}
+	if shrunk.Duration >= s.Duration {
+		t.Errorf("Expected a shorter run than %v, got %v", s.Duration, shrunk.Duration)
+	}
+	if result.Violation.Kind != string(AssertLeader) {
+		t.Errorf("Expected a leader violation, got %+v", result.Violation)
+	}
+	if len(progress) != len(result.Applied) || len(progress) == 0 {
+		t.Errorf("Expected progress for every kept simplification, got %v and %v", progress, result.Applied)
+	}
+
+	// The shrunk scenario still fails on its own
+	if run := mustRun(t, shrunk); run.Passed {
+		t.Error("Shrunk scenario should still fail")
+	}
+	// The input is left untouched
+	if len(s.Timeline) != 5 || s.Duration != 4*time.Second {
+		t.Error("Shrink should not modify its input")
+	}
+}
+
+func TestShrinkRequiresViolation(t *testing.T) {
+	s := mustParse(t, `
+duration: 1s
+assertions:
+  - kind: leader
+`)
+	if _, err := Shrink(context.Background(), s, string(AssertLeader), ShrinkOptions{}); err == nil {
+		t.Error("Expected an error for a scenario that passes")
+	}
+}
+
+func TestShrinkRespectsBudget(t *testing.T) {
+	s := mustParse(t, `
+duration: 2s
+workload: {kind: register, clients: 4}
+timeline:
+  - {at: 0s, action: partition, groups: [[node-1], [node-2], [node-3]]}
+  - {at: 100ms, action: isolate, nodes: [node-1]}
+assertions:
+  - kind: leader
+`)
+	result, err := Shrink(context.Background(), s, string(AssertLeader), ShrinkOptions{MaxRuns: 2})
+	if err != nil {
+		t.Fatalf("Shrink failed: %v", err)
+	}
+	if result.Runs != 2 {
+		t.Errorf("Expected 2 runs, got %d", result.Runs)
+	}
+}
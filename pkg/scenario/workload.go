@@ -0,0 +1,200 @@
+package scenario
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/checker"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/clock"
+	"github.com/francisco-teixeirax86/consensusforge/pkg/consensus"
+)
+
+// An operation a client is waiting on
+type pendingOp struct {
+	index   int // position in the history
+	client  int
+	timeout clock.Timer
+}
+
+// Drives the clients of a run. Clients propose commands to the node they
+// believe is leader and wait until any node applies them.
+type workload struct {
+	r       *runner
+	spec    Workload
+	rng     *rand.Rand
+	nextID  int64
+	pending map[int64]*pendingOp
+	history []checker.Operation
+	stopped bool
+}
+
+func newWorkload(r *runner, spec Workload, rng *rand.Rand) *workload {
+	return &workload{
+		r:       r,
+		spec:    spec,
+		rng:     rng,
+		pending: make(map[int64]*pendingOp),
+	}
+}
+
+// Schedules every client's first operation
+func (w *workload) start() {
+	if w.spec.Kind == WorkloadNone {
+		return
+	}
+	for client := 0; client < w.spec.Clients; client++ {
+		client := client
+		w.r.sim.Schedule(w.spec.Start, func() { w.issue(client) })
+	}
+}
+
+// Issues the next operation of a client
+func (w *workload) issue(client int) {
+	if w.stopped || (w.spec.Stop > 0 && w.r.elapsed() >= w.spec.Stop) {
+		return
+	}
+
+	w.nextID++
+	cmd := w.generate(w.nextID)
+	target := w.target()
+	op := checker.Operation{
+		ID:     cmd.ID,
+		Client: client,
+		Node:   target,
+		Kind:   cmd.Kind,
+		Key:    cmd.Key,
+		Input:  cmd.Value,
+		Invoke: w.r.elapsed(),
+	}
+	w.pending[op.ID] = &pendingOp{index: len(w.history), client: client}
+	w.history = append(w.history, op)
+	w.r.event(EventInvoke, target, "client %d: %s", client, describe(cmd))
+
+	if target == "" {
+		w.complete(op.ID, checker.StatusFail, nil, "no running node")
+		return
+	}
+
+	data, _ := json.Marshal(cmd)
+	if err := w.r.members[target].node.Propose(data); err != nil {
+		status := checker.StatusInfo
+		if errors.Is(err, consensus.ErrNotLeader) || errors.Is(err, consensus.ErrStopped) {
+			status = checker.StatusFail
+		}
+		w.complete(op.ID, status, nil, err.Error())
+		return
+	}
+
+	// A single-node cluster may already have applied the command
+	if p, exists := w.pending[op.ID]; exists {
+		p.timeout = w.r.sim.Schedule(w.spec.Timeout, func() {
+			w.complete(op.ID, checker.StatusInfo, nil, "timed out")
+		})
+	}
+}
+
+// Builds a random command for the workload's data model
+func (w *workload) generate(id int64) Command {
+	read := w.rng.Float64() < w.spec.ReadRatio
+	switch w.spec.Kind {
+	case WorkloadKV:
+		key := fmt.Sprintf("k%d", w.rng.IntN(w.spec.Keys))
+		if read {
+			return Command{ID: id, Kind: checker.OpRead, Key: key}
+		}
+		return Command{ID: id, Kind: checker.OpWrite, Key: key, Value: id}
+	case WorkloadCounter:
+		if read {
+			return Command{ID: id, Kind: checker.OpRead, Key: "counter"}
+		}
+		return Command{ID: id, Kind: checker.OpAdd, Key: "counter", Value: 1 + w.rng.Int64N(5)}
+	default:
+		if read {
+			return Command{ID: id, Kind: checker.OpRead, Key: "register"}
+		}
+		return Command{ID: id, Kind: checker.OpWrite, Key: "register", Value: id}
+	}
+}
+
+// Picks the node to propose to: the leader if there is one, otherwise a
+// random running node. Returns "" if no node is running.
+func (w *workload) target() string {
+	if leader := w.r.leader(); leader != "" {
+		return leader
+	}
+	running := w.r.running()
+	if len(running) == 0 {
+		return ""
+	}
+	return running[w.rng.IntN(len(running))]
+}
+
+// Called by every node's state machine. The first node to apply a
+// command completes the operation; the command is committed by then.
+// Nodes apply commands while holding their locks, so completion runs as
+// a separate event at the same instant.
+func (w *workload) applied(cmd Command, output *int64) {
+	w.r.sim.Schedule(0, func() { w.complete(cmd.ID, checker.StatusOK, output, "") })
+}
+
+// Records the outcome of a pending operation and schedules the client's next one
+func (w *workload) complete(id int64, status checker.Status, output *int64, reason string) {
+	p, exists := w.pending[id]
+	if !exists {
+		return
+	}
+	delete(w.pending, id)
+	if p.timeout != nil {
+		p.timeout.Stop()
+	}
+
+	op := &w.history[p.index]
+	op.Status = status
+	op.Output = output
+	op.Error = reason
+	op.Return = w.r.elapsed()
+	w.r.event(EventComplete, op.Node, "%s", op.String())
+
+	if !w.stopped {
+		w.r.sim.Schedule(w.spec.Interval, func() { w.issue(p.client) })
+	}
+}
+
+// Stops issuing operations. Operations still pending end as unknown.
+func (w *workload) finish() {
+	w.stopped = true
+
+	ids := make([]int64, 0, len(w.pending))
+	for id := range w.pending {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		w.complete(id, checker.StatusInfo, nil, "run ended")
+	}
+}
+
+// Checks the history against the sequential model of the workload
+func (w *workload) checkLinearizable() *checker.Result {
+	var model checker.Model
+	switch w.spec.Kind {
+	case WorkloadKV:
+		model = checker.KV{}
+	case WorkloadCounter:
+		model = checker.Counter{}
+	default:
+		model = checker.Register{}
+	}
+
+	return checker.Check(model, w.history)
+}
+
+func describe(cmd Command) string {
+	if cmd.Kind == checker.OpRead {
+		return fmt.Sprintf("read(%s)", cmd.Key)
+	}
+	return fmt.Sprintf("%s(%s, %d)", cmd.Kind, cmd.Key, cmd.Value)
+}
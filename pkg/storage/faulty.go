@@ -0,0 +1,357 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/consensus"
+)
+
+// Returned by Sync when DiskConditions fail it
+var ErrSyncFailed = errors.New("sync failed")
+
+// Describes the faults injected into a node's storage
+type DiskConditions struct {
+	// How long each sync takes
+	Latency       time.Duration `yaml:"latency"`
+	LatencyJitter time.Duration `yaml:"latency_jitter"`
+
+	// Chance that a sync fails. The writes it covered stay unsynced.
+	SyncFailure float64 `yaml:"sync_failure"` // 0.0 to 1.0
+
+	// Chance that a crash tears the writes not yet synced: instead of
+	// losing them all, the disk keeps some and part of the next one
+	TornWrite float64 `yaml:"torn_write"` // 0.0 to 1.0
+
+	// Chance that an entry is damaged on its way to the disk, a bit of
+	// its command flipped. The node keeps the intact copy until it
+	// restarts and reads the damaged one back.
+	BitRot float64 `yaml:"bit_rot"` // 0.0 to 1.0
+}
+
+// DefaultDiskConditions returns a fast and reliable disk
+func DefaultDiskConditions() DiskConditions {
+	return DiskConditions{
+		Latency:       0,
+		LatencyJitter: 0,
+		SyncFailure:   0.0,
+		TornWrite:     0.0,
+		BitRot:        0.0,
+	}
+}
+
+// A write held until the next sync
+type pendingWrite struct {
+	state    *consensus.HardState
+	snapshot *consensus.Snapshot
+	truncate int64 // entries from this index on are removed
+	entries  []consensus.Entry
+}
+
+// Wraps a consensus.Storage with the faults described by DiskConditions.
+// Writes are held in memory and only reach the wrapped storage when
+// synced, so a crash loses them, or tears them, whatever the wrapped
+// storage does. All randomness comes from the source it is given.
+type Faulty struct {
+	mu         sync.Mutex
+	inner      consensus.Storage
+	conditions DiskConditions
+	rng        *rand.Rand
+	view       contents // what the node sees, unsynced writes included
+	pending    []pendingWrite
+	delay      time.Duration // of the last sync
+	observer   func(fault string)
+	closed     bool
+}
+
+// Wraps inner, which must not be written to directly afterwards
+func NewFaulty(inner consensus.Storage, rng *rand.Rand) (*Faulty, error) {
+	hard, err := inner.GetHardState()
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := inner.GetSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	first, last := inner.FirstIndex(), inner.LastIndex()
+	entries, err := inner.Entries(first, last+1)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &Faulty{
+		inner:      inner,
+		conditions: DefaultDiskConditions(),
+		rng:        rng,
+		view:       contents{hard: hard, snapshot: snapshot, first: first},
+	}
+	f.view.entries = append(f.view.entries, entries...)
+	return f, nil
+}
+
+// Sets the faults injected from now on
+func (f *Faulty) SetConditions(conditions DiskConditions) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.conditions = conditions
+}
+
+// Returns the faults currently injected
+func (f *Faulty) GetConditions() DiskConditions {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.conditions
+}
+
+// Sets a function called with a description of every fault injected
+func (f *Faulty) SetObserver(observer func(fault string)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.observer = observer
+}
+
+func (f *Faulty) notify(format string, args ...interface{}) {
+	if f.observer != nil {
+		f.observer(fmt.Sprintf(format, args...))
+	}
+}
+
+func (f *Faulty) chance(p float64) bool {
+	return p > 0 && f.rng.Float64() < p
+}
+
+// FirstIndex Implements consensus.LogStore
+func (f *Faulty) FirstIndex() int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.view.first
+}
+
+// LastIndex Implements consensus.LogStore
+func (f *Faulty) LastIndex() int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.view.lastIndex()
+}
+
+// Entries Implements consensus.LogStore
+func (f *Faulty) Entries(lo, hi int64) ([]consensus.Entry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.view.slice(lo, hi)
+}
+
+// Append Implements consensus.LogStore
+func (f *Faulty) Append(entries []consensus.Entry) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return ErrClosed
+	}
+	if err := f.view.append(entries); err != nil {
+		return err
+	}
+	f.pending = append(f.pending, pendingWrite{entries: entries})
+	return nil
+}
+
+// Truncate Implements consensus.LogStore
+func (f *Faulty) Truncate(index int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return ErrClosed
+	}
+	if index > f.view.lastIndex() {
+		return nil
+	}
+	if err := f.view.truncate(index); err != nil {
+		return err
+	}
+	f.pending = append(f.pending, pendingWrite{truncate: index})
+	return nil
+}
+
+// GetHardState Implements consensus.StableStore
+func (f *Faulty) GetHardState() (consensus.HardState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.view.hard, nil
+}
+
+// SetHardState Implements consensus.StableStore
+func (f *Faulty) SetHardState(state consensus.HardState) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return ErrClosed
+	}
+	f.view.hard = state
+	f.pending = append(f.pending, pendingWrite{state: &state})
+	return nil
+}
+
+// GetSnapshot Implements consensus.SnapshotStore
+func (f *Faulty) GetSnapshot() (consensus.Snapshot, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.view.snapshot, nil
+}
+
+// SetSnapshot Implements consensus.SnapshotStore
+func (f *Faulty) SetSnapshot(snapshot consensus.Snapshot) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return ErrClosed
+	}
+	if err := f.view.compact(snapshot); err != nil {
+		return err
+	}
+	f.pending = append(f.pending, pendingWrite{snapshot: &snapshot})
+	return nil
+}
+
+// Sync Implements consensus.LogStore, consensus.StableStore and
+// consensus.SnapshotStore
+func (f *Faulty) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return ErrClosed
+	}
+	f.delay = f.conditions.Latency
+	if jitter := f.conditions.LatencyJitter; jitter > 0 {
+		f.delay += time.Duration(f.rng.Int64N(int64(jitter)))
+	}
+	if len(f.pending) == 0 {
+		return nil
+	}
+	if f.chance(f.conditions.SyncFailure) {
+		f.notify("sync of %d writes failed", len(f.pending))
+		return ErrSyncFailed
+	}
+	return f.flush(f.pending)
+}
+
+// SyncDelay Implements consensus.SlowStorage
+func (f *Faulty) SyncDelay() time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.delay
+}
+
+// Writes pending to the wrapped storage and syncs it
+func (f *Faulty) flush(pending []pendingWrite) error {
+	f.pending = nil
+	for _, w := range pending {
+		var err error
+		switch {
+		case w.state != nil:
+			err = f.inner.SetHardState(*w.state)
+		case w.snapshot != nil:
+			err = f.inner.SetSnapshot(*w.snapshot)
+		case w.truncate > 0:
+			err = f.inner.Truncate(w.truncate)
+		default:
+			err = f.inner.Append(f.rot(w.entries))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return f.inner.Sync()
+}
+
+// Returns entries as the disk stores them, with bits flipped at random
+func (f *Faulty) rot(entries []consensus.Entry) []consensus.Entry {
+	if f.conditions.BitRot <= 0 {
+		return entries
+	}
+	stored := entries
+	copied := false
+	for i, entry := range entries {
+		if len(entry.Command) == 0 || !f.chance(f.conditions.BitRot) {
+			continue
+		}
+		if !copied {
+			stored = append([]consensus.Entry(nil), entries...)
+			copied = true
+		}
+		bit := f.rng.IntN(8 * len(entry.Command))
+		command := append([]byte(nil), entry.Command...)
+		command[bit/8] ^= 1 << (bit % 8)
+		stored[i].Command = command
+		f.notify("bit %d of entry %d flipped", bit, entry.Index)
+	}
+	return stored
+}
+
+// Close Implements consensus.Storage. Pending writes are synced first.
+func (f *Faulty) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	err := f.flush(f.pending)
+	if closeErr := f.inner.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Crash Implements consensus.Crashable. Pending writes are lost, unless
+// the crash tears them and the disk keeps a part.
+func (f *Faulty) Crash() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+
+	var err error
+	if len(f.pending) > 0 && f.chance(f.conditions.TornWrite) {
+		kept := f.rng.IntN(len(f.pending))
+		torn := f.pending[: kept+1 : kept+1]
+		last := torn[kept]
+		if len(last.entries) > 0 {
+			last.entries = last.entries[:f.rng.IntN(len(last.entries))]
+			torn[kept] = last
+		} else {
+			torn = torn[:kept]
+		}
+		f.notify("crash tore %d pending writes, %d kept", len(f.pending), len(torn))
+		err = f.flush(torn)
+	} else if len(f.pending) > 0 {
+		f.notify("crash lost %d pending writes", len(f.pending))
+	}
+	f.pending = nil
+
+	if closeErr := f.inner.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/consensus"
+)
+
+func newFaulty(t *testing.T, inner consensus.Storage, conditions DiskConditions) *Faulty {
+	t.Helper()
+	f, err := NewFaulty(inner, rand.New(rand.NewPCG(1, 2)))
+	if err != nil {
+		t.Fatalf("NewFaulty failed: %v", err)
+	}
+	f.SetConditions(conditions)
+	return f
+}
+
+func TestFaulty(t *testing.T) {
+	testStorage(t, newFaulty(t, NewMemory(), DefaultDiskConditions()))
+
+	// Snapshots reach the disk when synced
+	inner := NewMemory()
+	testSnapshots(t, newFaulty(t, inner, DefaultDiskConditions()))
+	if snapshot, _ := inner.GetSnapshot(); snapshot.Index != 9 || inner.LastIndex() != 11 {
+		t.Errorf("Expected the synced snapshot and log on disk, got snapshot %d and last index %d", snapshot.Index, inner.LastIndex())
+	}
+	f := newFaulty(t, inner, DefaultDiskConditions())
+	if f.FirstIndex() != 10 || f.LastIndex() != 11 {
+		t.Errorf("Expected the log [10, 11] to be loaded, got [%d, %d]", f.FirstIndex(), f.LastIndex())
+	}
+}
+
+func TestFaultyCrashLosesPendingWrites(t *testing.T) {
+	inner := NewMemory()
+	f := newFaulty(t, inner, DefaultDiskConditions())
+	f.Append(entries(1, 1, 2))
+	f.Sync()
+	f.Append(entries(1, 3, 4))
+	f.SetHardState(consensus.HardState{Term: 1})
+	if inner.LastIndex() != 2 {
+		t.Errorf("Unsynced writes should not reach the disk, got last index %d", inner.LastIndex())
+	}
+	if f.LastIndex() != 4 {
+		t.Errorf("Unsynced writes should be visible, got last index %d", f.LastIndex())
+	}
+
+	if err := f.Crash(); err != nil {
+		t.Fatalf("Crash failed: %v", err)
+	}
+	if state, _ := inner.GetHardState(); inner.LastIndex() != 2 || state.Term != 0 {
+		t.Errorf("Expected only synced writes to survive, got last index %d and term %d", inner.LastIndex(), state.Term)
+	}
+	if err := f.Append(entries(1, 3, 3)); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed after a crash, got %v", err)
+	}
+}
+
+func TestFaultySyncFailure(t *testing.T) {
+	inner := NewMemory()
+	f := newFaulty(t, inner, DiskConditions{SyncFailure: 1})
+	var faults []string
+	f.SetObserver(func(fault string) { faults = append(faults, fault) })
+
+	f.Append(entries(1, 1, 2))
+	if err := f.Sync(); !errors.Is(err, ErrSyncFailed) {
+		t.Fatalf("Expected ErrSyncFailed, got %v", err)
+	}
+	if inner.LastIndex() != 0 || len(faults) != 1 {
+		t.Errorf("Expected a failed sync to write nothing and be reported, got last index %d and %v", inner.LastIndex(), faults)
+	}
+
+	f.SetConditions(DefaultDiskConditions())
+	if err := f.Sync(); err != nil || inner.LastIndex() != 2 {
+		t.Errorf("Expected the next sync to write the entries, got last index %d (%v)", inner.LastIndex(), err)
+	}
+}
+
+func TestFaultyTornWrite(t *testing.T) {
+	inner := NewMemory()
+	f := newFaulty(t, inner, DiskConditions{TornWrite: 1})
+	for i := int64(0); i < 10; i++ {
+		f.Append(entries(1, 3*i+1, 3*i+3))
+	}
+	f.Crash()
+
+	kept := inner.LastIndex()
+	if kept >= 30 {
+		t.Errorf("Expected the crash to lose part of the pending writes, %d entries kept", kept)
+	}
+	got, _ := inner.Entries(1, kept+1)
+	for i, entry := range got {
+		if entry.Index != int64(i+1) {
+			t.Fatalf("Expected a prefix of the writes, got %+v", got)
+		}
+	}
+}
+
+func TestFaultyBitRot(t *testing.T) {
+	inner := NewMemory()
+	f := newFaulty(t, inner, DiskConditions{BitRot: 1})
+	written := entries(1, 1, 3)
+	f.Append(written)
+	f.Sync()
+
+	seen, _ := f.Entries(1, 4)
+	stored, _ := inner.Entries(1, 4)
+	for i := range written {
+		if !bytes.Equal(seen[i].Command, written[i].Command) {
+			t.Errorf("Entry %d should stay intact in memory", i+1)
+		}
+		diff := 0
+		for j := range stored[i].Command {
+			for b := stored[i].Command[j] ^ written[i].Command[j]; b != 0; b &= b - 1 {
+				diff++
+			}
+		}
+		if diff != 1 {
+			t.Errorf("Expected one flipped bit in stored entry %d, got %d", i+1, diff)
+		}
+	}
+}
+
+func TestFaultyLatency(t *testing.T) {
+	f := newFaulty(t, NewMemory(), DiskConditions{Latency: 5 * time.Millisecond, LatencyJitter: time.Millisecond})
+	for i := int64(1); i <= 20; i++ {
+		f.Append(entries(1, i, i))
+		f.Sync()
+		if d := f.SyncDelay(); d < 5*time.Millisecond || d >= 6*time.Millisecond {
+			t.Fatalf("Sync delay %v outside the latency range", d)
+		}
+	}
+}
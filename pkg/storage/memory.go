@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"sync"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/consensus"
+)
+
+// Implements consensus.Storage in memory. Like a disk, it outlives the
+// nodes that use it: Close keeps everything for the next node, while
+// Crash reverts to the last sync.
+type Memory struct {
+	mu      sync.Mutex
+	current contents
+	synced  contents
+}
+
+func NewMemory() *Memory {
+	return &Memory{current: newContents(), synced: newContents()}
+}
+
+// FirstIndex Implements consensus.LogStore
+func (m *Memory) FirstIndex() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.current.first
+}
+
+// LastIndex Implements consensus.LogStore
+func (m *Memory) LastIndex() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.current.lastIndex()
+}
+
+// Entries Implements consensus.LogStore
+func (m *Memory) Entries(lo, hi int64) ([]consensus.Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.current.slice(lo, hi)
+}
+
+// Append Implements consensus.LogStore
+func (m *Memory) Append(entries []consensus.Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.current.append(entries)
+}
+
+// Truncate Implements consensus.LogStore
+func (m *Memory) Truncate(index int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.current.truncate(index)
+}
+
+// GetHardState Implements consensus.StableStore
+func (m *Memory) GetHardState() (consensus.HardState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.current.hard, nil
+}
+
+// SetHardState Implements consensus.StableStore
+func (m *Memory) SetHardState(state consensus.HardState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.current.hard = state
+	return nil
+}
+
+// GetSnapshot Implements consensus.SnapshotStore
+func (m *Memory) GetSnapshot() (consensus.Snapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.current.snapshot, nil
+}
+
+// SetSnapshot Implements consensus.SnapshotStore
+func (m *Memory) SetSnapshot(snapshot consensus.Snapshot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.current.compact(snapshot)
+}
+
+// Sync Implements consensus.LogStore, consensus.StableStore and
+// consensus.SnapshotStore
+func (m *Memory) Sync() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.synced = m.current.freeze()
+	return nil
+}
+
+// Close Implements consensus.Storage. Unsynced writes are kept.
+func (m *Memory) Close() error {
+	return m.Sync()
+}
+
+// Crash Implements consensus.Crashable
+func (m *Memory) Crash() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.current = m.synced.freeze()
+	return nil
+}
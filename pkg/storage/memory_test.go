@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/consensus"
+)
+
+func TestMemory(t *testing.T) {
+	testStorage(t, NewMemory())
+	testSnapshots(t, NewMemory())
+}
+
+func TestMemoryCrash(t *testing.T) {
+	store := NewMemory()
+	store.Append(entries(1, 1, 3))
+	store.SetHardState(consensus.HardState{Term: 1})
+	store.Sync()
+	held, _ := store.Entries(1, 4)
+
+	store.Truncate(2)
+	store.Append(entries(2, 2, 4))
+	store.SetHardState(consensus.HardState{Term: 2})
+	if err := store.Crash(); err != nil {
+		t.Fatalf("Crash failed: %v", err)
+	}
+
+	if state, _ := store.GetHardState(); state.Term != 1 {
+		t.Errorf("Expected the synced term 1, got %d", state.Term)
+	}
+	got, _ := store.Entries(1, store.LastIndex()+1)
+	if len(got) != 3 || got[1].Term != 1 {
+		t.Errorf("Expected the synced entries, got %+v", got)
+	}
+	if held[1].Term != 1 {
+		t.Error("Entries handed out before the truncation should not change")
+	}
+
+	// Closing keeps unsynced writes for the next user
+	store.Append(entries(1, 4, 4))
+	store.Close()
+	if store.LastIndex() != 4 {
+		t.Errorf("Expected the log to survive Close, got last index %d", store.LastIndex())
+	}
+}
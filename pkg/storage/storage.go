@@ -0,0 +1,87 @@
+// Package storage implements consensus.Storage: an in-memory store for
+// tests and simulations, and a write-ahead log on disk.
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/consensus"
+)
+
+// Returned when using a storage after it was closed
+var ErrClosed = errors.New("storage is closed")
+
+// The log, hard state and snapshot held by a storage. Entries are never
+// modified in place, so slices of them can be handed out and shared.
+type contents struct {
+	hard     consensus.HardState
+	snapshot consensus.Snapshot
+	entries  []consensus.Entry
+	first    int64 // index of entries[0], right after the snapshot
+}
+
+func newContents() contents {
+	return contents{first: 1}
+}
+
+func (c *contents) lastIndex() int64 {
+	return c.first + int64(len(c.entries)) - 1
+}
+
+func (c *contents) slice(lo, hi int64) ([]consensus.Entry, error) {
+	if lo < c.first || hi > c.lastIndex()+1 || lo > hi {
+		return nil, fmt.Errorf("entries [%d, %d) are outside the log [%d, %d]", lo, hi, c.first, c.lastIndex())
+	}
+	from, to := lo-c.first, hi-c.first
+	return c.entries[from:to:to], nil
+}
+
+func (c *contents) append(entries []consensus.Entry) error {
+	next := c.lastIndex() + 1
+	for i, entry := range entries {
+		if entry.Index != next+int64(i) {
+			return fmt.Errorf("entry %d appended at index %d", entry.Index, next+int64(i))
+		}
+	}
+	c.entries = append(c.entries, entries...)
+	return nil
+}
+
+// Capping the kept entries makes the next append copy them, leaving
+// slices handed out earlier untouched
+func (c *contents) truncate(index int64) error {
+	if index < c.first {
+		return fmt.Errorf("cannot truncate at index %d before the first entry %d", index, c.first)
+	}
+	if index > c.lastIndex() {
+		return nil
+	}
+	keep := index - c.first
+	c.entries = c.entries[:keep:keep]
+	return nil
+}
+
+// Replaces the snapshot, dropping the entries it covers and keeping those
+// after it
+func (c *contents) compact(snapshot consensus.Snapshot) error {
+	if snapshot.Index < c.snapshot.Index {
+		return fmt.Errorf("snapshot at index %d is older than the one at %d", snapshot.Index, c.snapshot.Index)
+	}
+	if snapshot.Index >= c.lastIndex() {
+		c.entries = nil
+	} else {
+		from := snapshot.Index - c.first + 1
+		c.entries = c.entries[from:len(c.entries):len(c.entries)]
+	}
+	c.first = snapshot.Index + 1
+	c.snapshot = snapshot
+	return nil
+}
+
+// Returns a copy that later changes to c do not affect
+func (c *contents) freeze() contents {
+	frozen := *c
+	frozen.entries = c.entries[:len(c.entries):len(c.entries)]
+	return frozen
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/consensus"
+)
+
+func entries(term int64, from, to int64) []consensus.Entry {
+	var list []consensus.Entry
+	for index := from; index <= to; index++ {
+		list = append(list, consensus.Entry{Index: index, Term: term, Command: []byte(fmt.Sprint(index))})
+	}
+	return list
+}
+
+// Checks the behaviour every consensus.Storage shares
+func testStorage(t *testing.T, store consensus.Storage) {
+	t.Helper()
+
+	if store.FirstIndex() != 1 || store.LastIndex() != 0 {
+		t.Fatalf("Expected an empty log, got [%d, %d]", store.FirstIndex(), store.LastIndex())
+	}
+	if err := store.Append(entries(1, 1, 5)); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	if err := store.Append(entries(1, 7, 7)); err == nil {
+		t.Error("Expected error for a gap in the log")
+	}
+	if err := store.Truncate(4); err != nil {
+		t.Fatalf("Truncate failed: %v", err)
+	}
+	if err := store.Append(entries(2, 4, 6)); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	if store.LastIndex() != 6 {
+		t.Errorf("Expected last index 6, got %d", store.LastIndex())
+	}
+
+	got, err := store.Entries(3, 5)
+	if err != nil {
+		t.Fatalf("Entries failed: %v", err)
+	}
+	if len(got) != 2 || got[0].Term != 1 || got[1].Term != 2 || got[1].Index != 4 {
+		t.Errorf("Unexpected entries %+v", got)
+	}
+	if _, err := store.Entries(5, 8); err == nil {
+		t.Error("Expected error for entries past the end of the log")
+	}
+
+	want := consensus.HardState{Term: 2, VotedFor: "node-2"}
+	if err := store.SetHardState(want); err != nil {
+		t.Fatalf("SetHardState failed: %v", err)
+	}
+	if state, err := store.GetHardState(); err != nil || state != want {
+		t.Errorf("Expected %+v, got %+v (%v)", want, state, err)
+	}
+	if err := store.Sync(); err != nil {
+		t.Errorf("Sync failed: %v", err)
+	}
+}
+
+// Checks how every consensus.Storage compacts its log into a snapshot
+func testSnapshots(t *testing.T, store consensus.Storage) {
+	t.Helper()
+
+	if snapshot, err := store.GetSnapshot(); err != nil || snapshot.Index != 0 {
+		t.Fatalf("Expected no snapshot, got %+v (%v)", snapshot, err)
+	}
+	store.Append(entries(1, 1, 6))
+	want := consensus.Snapshot{Index: 4, Term: 1, Data: []byte("state")}
+	if err := store.SetSnapshot(want); err != nil {
+		t.Fatalf("SetSnapshot failed: %v", err)
+	}
+	if store.FirstIndex() != 5 || store.LastIndex() != 6 {
+		t.Errorf("Expected the log [5, 6] after the snapshot, got [%d, %d]", store.FirstIndex(), store.LastIndex())
+	}
+	if snapshot, err := store.GetSnapshot(); err != nil || snapshot.Index != 4 || string(snapshot.Data) != "state" {
+		t.Errorf("Expected %+v, got %+v (%v)", want, snapshot, err)
+	}
+	if _, err := store.Entries(4, 6); err == nil {
+		t.Error("Expected error for entries covered by the snapshot")
+	}
+	if err := store.Truncate(4); err == nil {
+		t.Error("Expected error for a truncation inside the snapshot")
+	}
+	if err := store.SetSnapshot(consensus.Snapshot{Index: 3, Term: 1}); err == nil {
+		t.Error("Expected error for a snapshot older than the current one")
+	}
+
+	// A snapshot past the end of the log leaves it empty
+	if err := store.SetSnapshot(consensus.Snapshot{Index: 9, Term: 2}); err != nil {
+		t.Fatalf("SetSnapshot failed: %v", err)
+	}
+	if store.FirstIndex() != 10 || store.LastIndex() != 9 {
+		t.Errorf("Expected an empty log after index 9, got [%d, %d]", store.FirstIndex(), store.LastIndex())
+	}
+	if err := store.Append(entries(2, 10, 11)); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	if err := store.Sync(); err != nil {
+		t.Errorf("Sync failed: %v", err)
+	}
+}
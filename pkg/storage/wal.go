@@ -0,0 +1,339 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/consensus"
+)
+
+// Name of the log file in the directory a WAL is opened in
+const walFile = "wal.log"
+
+// One change to the storage, applied in field order
+type walRecord struct {
+	State    *consensus.HardState `json:"state,omitempty"`
+	Snapshot *consensus.Snapshot  `json:"snapshot,omitempty"`
+	Truncate int64                `json:"truncate,omitempty"` // entries from this index on are removed
+	Entries  []consensus.Entry    `json:"entries,omitempty"`
+}
+
+// Implements consensus.Storage as a write-ahead log: an append-only file
+// of checksummed JSON records, replayed into memory when opened. Writes
+// reach the file at once but only survive a crash once synced. Storing a
+// snapshot rewrites the file without the entries it covers.
+type WAL struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	data   contents
+	size   int64 // bytes written
+	synced int64 // bytes known to be on disk
+}
+
+// Opens the write-ahead log in dir, creating both if needed. A record cut
+// short by a crash at the end of the log is dropped; a damaged record
+// anywhere else fails with consensus.ErrCorrupt.
+func OpenWAL(dir string) (*WAL, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, walFile)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &WAL{path: path, file: file, data: newContents()}
+	if err := w.replay(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *WAL) replay() error {
+	var valid int64
+	reader := bufio.NewReader(w.file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break // a partial last line is a torn write
+		}
+		if err != nil {
+			return err
+		}
+
+		rec, err := decodeRecord(line)
+		if err == nil {
+			err = w.apply(rec)
+		}
+		if err != nil {
+			if _, peekErr := reader.Peek(1); peekErr == io.EOF {
+				break // the last record was torn
+			}
+			return fmt.Errorf("%w: record at offset %d: %v", consensus.ErrCorrupt, valid, err)
+		}
+		valid += int64(len(line))
+	}
+
+	if err := w.file.Truncate(valid); err != nil {
+		return err
+	}
+	if _, err := w.file.Seek(valid, io.SeekStart); err != nil {
+		return err
+	}
+	w.size, w.synced = valid, valid
+	return nil
+}
+
+func (w *WAL) apply(rec walRecord) error {
+	if rec.State != nil {
+		w.data.hard = *rec.State
+	}
+	if rec.Snapshot != nil {
+		if err := w.data.compact(*rec.Snapshot); err != nil {
+			return err
+		}
+	}
+	if rec.Truncate > 0 {
+		if err := w.data.truncate(rec.Truncate); err != nil {
+			return err
+		}
+	}
+	return w.data.append(rec.Entries)
+}
+
+// Encodes a record as a line holding its checksum and JSON
+func encodeRecord(rec walRecord) ([]byte, error) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	line := fmt.Appendf(nil, "%08x ", crc32.ChecksumIEEE(data))
+	line = append(line, data...)
+	return append(line, '\n'), nil
+}
+
+func decodeRecord(line []byte) (walRecord, error) {
+	var rec walRecord
+	sum, data, found := bytes.Cut(bytes.TrimSuffix(line, []byte("\n")), []byte(" "))
+	if !found {
+		return rec, fmt.Errorf("missing checksum")
+	}
+	if fmt.Sprintf("%08x", crc32.ChecksumIEEE(data)) != string(sum) {
+		return rec, fmt.Errorf("checksum mismatch")
+	}
+	err := json.Unmarshal(data, &rec)
+	return rec, err
+}
+
+// Applies a record and writes it without syncing
+func (w *WAL) write(rec walRecord) error {
+	if w.file == nil {
+		return ErrClosed
+	}
+	line, err := encodeRecord(rec)
+	if err != nil {
+		return err
+	}
+	if err := w.apply(rec); err != nil {
+		return err
+	}
+	n, err := w.file.Write(line)
+	w.size += int64(n)
+	return err
+}
+
+// FirstIndex Implements consensus.LogStore
+func (w *WAL) FirstIndex() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.data.first
+}
+
+// LastIndex Implements consensus.LogStore
+func (w *WAL) LastIndex() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.data.lastIndex()
+}
+
+// Entries Implements consensus.LogStore
+func (w *WAL) Entries(lo, hi int64) ([]consensus.Entry, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.data.slice(lo, hi)
+}
+
+// Append Implements consensus.LogStore
+func (w *WAL) Append(entries []consensus.Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.write(walRecord{Entries: entries})
+}
+
+// Truncate Implements consensus.LogStore
+func (w *WAL) Truncate(index int64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if index > w.data.lastIndex() {
+		return nil
+	}
+	return w.write(walRecord{Truncate: index})
+}
+
+// GetHardState Implements consensus.StableStore
+func (w *WAL) GetHardState() (consensus.HardState, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.data.hard, nil
+}
+
+// SetHardState Implements consensus.StableStore
+func (w *WAL) SetHardState(state consensus.HardState) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.write(walRecord{State: &state})
+}
+
+// GetSnapshot Implements consensus.SnapshotStore
+func (w *WAL) GetSnapshot() (consensus.Snapshot, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.data.snapshot, nil
+}
+
+// SetSnapshot Implements consensus.SnapshotStore. The log is rewritten as
+// a single record of the snapshot, the hard state and the entries after
+// it, which also syncs the writes before it.
+func (w *WAL) SetSnapshot(snapshot consensus.Snapshot) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return ErrClosed
+	}
+	data := w.data
+	if err := data.compact(snapshot); err != nil {
+		return err
+	}
+	return w.rewrite(data)
+}
+
+// Writes data to a new file and renames it over the log, so a crash
+// leaves either the old log or the new one
+func (w *WAL) rewrite(data contents) error {
+	line, err := encodeRecord(walRecord{State: &data.hard, Snapshot: &data.snapshot, Entries: data.entries})
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(w.path+".tmp", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(line); err == nil {
+		err = file.Sync()
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), w.path)
+	}
+	if err == nil {
+		err = syncDir(filepath.Dir(w.path))
+	}
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	w.file.Close()
+	w.file = file
+	w.data = data
+	w.size, w.synced = int64(len(line)), int64(len(line))
+	return nil
+}
+
+// Makes a rename in dir durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// Sync Implements consensus.LogStore, consensus.StableStore and
+// consensus.SnapshotStore
+func (w *WAL) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.sync()
+}
+
+func (w *WAL) sync() error {
+	if w.file == nil {
+		return ErrClosed
+	}
+	if w.synced == w.size {
+		return nil
+	}
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	w.synced = w.size
+	return nil
+}
+
+// Close Implements consensus.Storage. Pending writes are synced first.
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.sync()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	w.file = nil
+	return err
+}
+
+// Crash Implements consensus.Crashable. The file is closed as a power
+// failure would leave it, without the writes that were not synced.
+func (w *WAL) Crash() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Truncate(w.synced)
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	w.file = nil
+	return err
+}
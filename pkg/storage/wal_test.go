@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/francisco-teixeirax86/consensusforge/pkg/consensus"
+)
+
+func mustOpen(t *testing.T, dir string) *WAL {
+	t.Helper()
+	w, err := OpenWAL(dir)
+	if err != nil {
+		t.Fatalf("OpenWAL failed: %v", err)
+	}
+	return w
+}
+
+func TestWAL(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "node-1")
+	w := mustOpen(t, dir)
+	testStorage(t, w)
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := w.Append(entries(2, 7, 7)); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+
+	// Reopening replays the same state
+	w = mustOpen(t, dir)
+	defer w.Close()
+	if state, _ := w.GetHardState(); state.Term != 2 || state.VotedFor != "node-2" {
+		t.Errorf("Expected the hard state to be replayed, got %+v", state)
+	}
+	got, _ := w.Entries(1, w.LastIndex()+1)
+	if len(got) != 6 || got[2].Term != 1 || got[3].Term != 2 {
+		t.Errorf("Expected the truncated log to be replayed, got %+v", got)
+	}
+}
+
+func TestWALTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	w := mustOpen(t, dir)
+	w.Append(entries(1, 1, 2))
+	w.Close()
+
+	// A write cut short, with or without its newline, is dropped
+	for _, torn := range []string{`0badc0de {"entr`, "0badc0de {}\n"} {
+		file, err := os.OpenFile(filepath.Join(dir, walFile), os.O_APPEND|os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.WriteString(torn)
+		file.Close()
+
+		w = mustOpen(t, dir)
+		if w.LastIndex() != 2 {
+			t.Errorf("Expected the torn record %q to be dropped, got last index %d", torn, w.LastIndex())
+		}
+		w.Append(entries(1, 3, 3))
+		w.Truncate(3)
+		w.Close()
+	}
+}
+
+func TestWALCorruptRecord(t *testing.T) {
+	dir := t.TempDir()
+	w := mustOpen(t, dir)
+	w.Append(entries(1, 1, 1))
+	w.Append(entries(1, 2, 2))
+	w.Close()
+
+	path := filepath.Join(dir, walFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[12] ^= 0x01
+	os.WriteFile(path, data, 0o644)
+
+	if _, err := OpenWAL(dir); !errors.Is(err, consensus.ErrCorrupt) {
+		t.Errorf("Expected ErrCorrupt for a damaged record, got %v", err)
+	}
+}
+
+func TestWALCrash(t *testing.T) {
+	dir := t.TempDir()
+	w := mustOpen(t, dir)
+	w.SetHardState(consensus.HardState{Term: 1})
+	w.Sync()
+	w.SetHardState(consensus.HardState{Term: 2})
+	w.Append(entries(2, 1, 1))
+	if err := w.Crash(); err != nil {
+		t.Fatalf("Crash failed: %v", err)
+	}
+
+	w = mustOpen(t, dir)
+	defer w.Close()
+	if state, _ := w.GetHardState(); state.Term != 1 || w.LastIndex() != 0 {
+		t.Errorf("Expected only synced writes to survive, got term %d and last index %d", state.Term, w.LastIndex())
+	}
+}
+
+func TestWALSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	w := mustOpen(t, dir)
+	testSnapshots(t, w)
+	w.SetHardState(consensus.HardState{Term: 2})
+	w.Close()
+
+	w = mustOpen(t, dir)
+	snapshot, _ := w.GetSnapshot()
+	state, _ := w.GetHardState()
+	if snapshot.Index != 9 || snapshot.Term != 2 || state.Term != 2 {
+		t.Errorf("Expected the snapshot and hard state to be replayed, got %+v and %+v", snapshot, state)
+	}
+	if w.FirstIndex() != 10 || w.LastIndex() != 11 {
+		t.Errorf("Expected the log [10, 11] to be replayed, got [%d, %d]", w.FirstIndex(), w.LastIndex())
+	}
+
+	// The snapshot rewrote the log, so a crash keeps everything before it
+	w.Append(entries(2, 12, 12))
+	w.SetSnapshot(consensus.Snapshot{Index: 10, Term: 2, Data: []byte("state")})
+	w.Append(entries(2, 13, 13))
+	if err := w.Crash(); err != nil {
+		t.Fatalf("Crash failed: %v", err)
+	}
+	w = mustOpen(t, dir)
+	defer w.Close()
+	if snapshot, _ := w.GetSnapshot(); snapshot.Index != 10 || w.FirstIndex() != 11 || w.LastIndex() != 12 {
+		t.Errorf("Expected snapshot 10 and the log [11, 12], got snapshot %d and [%d, %d]",
+			snapshot.Index, w.FirstIndex(), w.LastIndex())
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, walFile))
+	if lines := bytes.Count(data, []byte("\n")); lines != 1 {
+		t.Errorf("Expected the compacted log to hold a single record, got %d", lines)
+	}
+}